@@ -0,0 +1,151 @@
+// Command validate checks a workflow YAML file for structural problems.
+//
+// Usage:
+//
+//	validate [-strict] [-manifest registry.yaml] workflow.yaml
+//
+// With -strict, transitions listing an empty or repeated action or condition
+// name, and states without transitions that are not marked isFinal, are also
+// reported.
+//
+// The optional manifest lists the action, condition and payload validator names
+// known to the application and enables a strict check that every name referenced
+// by the workflow is registered:
+//
+//	actions:
+//	  - chargePayment
+//	conditions:
+//	  - isPaymentSuccess
+//	validators:
+//	  - isValidAmount
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rahulpahuja/go-machina/machina"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists the action, condition and payload validator names registered by an application
+type Manifest struct {
+	Actions    []string `yaml:"actions"`
+	Conditions []string `yaml:"conditions"`
+	Validators []string `yaml:"validators"`
+}
+
+func main() {
+	strict := flag.Bool("strict", false, "also reject empty and repeated action and condition names and non-final dead-end states")
+	manifestPath := flag.String("manifest", "", "path to a YAML manifest of registered action, condition and validator names")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-strict] [-manifest registry.yaml] workflow.yaml\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if !run(flag.Arg(0), *manifestPath, *strict) {
+		os.Exit(1)
+	}
+}
+
+// run validates the workflow at path and prints a diagnostic for every problem found.
+// It returns true if the workflow is valid.
+func run(path, manifestPath string, strict bool) bool {
+	definition, err := machina.LoadWorkflowDefinition(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return false
+	}
+
+	valid := true
+	report := func(err error) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		valid = false
+	}
+
+	validate := definition.Validate
+	if strict {
+		validate = definition.ValidateStrict
+	}
+	if err := validate(); err != nil {
+		report(err)
+	}
+
+	if err := definition.ValidateTargets(); err != nil {
+		report(err)
+	}
+
+	for _, name := range definition.UnreachableStates() {
+		report(fmt.Errorf("state %s is unreachable from initialState %s", name, definition.InitialState))
+	}
+
+	if manifestPath != "" {
+		registry, err := loadManifest(manifestPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", manifestPath, err)
+			return false
+		}
+		if err := definition.ValidateRegistry(registry); err != nil {
+			report(err)
+		}
+	}
+
+	if valid {
+		fmt.Printf("%s: OK\n", path)
+	}
+
+	return valid
+}
+
+// loadManifest reads a manifest file and builds a registry with placeholder
+// implementations for every listed name
+func loadManifest(path string) (*machina.Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+
+	registry := machina.NewRegistry()
+	for _, name := range manifest.Actions {
+		if err := registry.RegisterAction(name, noOpAction); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range manifest.Conditions {
+		if err := registry.RegisterCondition(name, noOpCondition); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range manifest.Validators {
+		if err := registry.RegisterPayloadValidator(name, noOpValidator); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+func noOpAction(ctx context.Context, data map[string]any) (map[string]any, error) {
+	return nil, nil
+}
+
+func noOpCondition(ctx context.Context, data map[string]any) (bool, error) {
+	return true, nil
+}
+
+func noOpValidator(ctx context.Context, data map[string]any) error {
+	return nil
+}
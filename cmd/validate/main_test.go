@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes content to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_ManifestValidators(t *testing.T) {
+	dir := t.TempDir()
+	workflow := writeFile(t, dir, "workflow.yaml", `
+initialState: start
+states:
+  start:
+    name: start
+    transitions:
+      - event: pay
+        target: paid
+        actions: [chargePayment]
+        conditions: [isPaymentSuccess]
+        validators: [isValidAmount]
+  paid:
+    name: paid
+    isFinal: true
+`)
+
+	tests := []struct {
+		name     string
+		manifest string
+		valid    bool
+	}{
+		{
+			name:     "ValidatorListed",
+			manifest: "actions: [chargePayment]\nconditions: [isPaymentSuccess]\nvalidators: [isValidAmount]\n",
+			valid:    true,
+		},
+		{
+			name:     "ValidatorMissing",
+			manifest: "actions: [chargePayment]\nconditions: [isPaymentSuccess]\n",
+			valid:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := writeFile(t, dir, tt.name+".yaml", tt.manifest)
+			if got := run(workflow, manifest, true); got != tt.valid {
+				t.Errorf("Expected valid to be %v, got %v", tt.valid, got)
+			}
+		})
+	}
+}
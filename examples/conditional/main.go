@@ -62,7 +62,7 @@ func main() {
 
 	// Execute the workflow with an even number (Branch 1: A -> B -> D -> E)
 	ctx := context.Background()
-	currentState := "A"
+	currentState := definition.InitialState
 	data := map[string]any{"number": 4, "state": currentState}
 
 	fmt.Println("Starting conditional workflow with even number (4): A -> B -> D -> E")
@@ -99,7 +99,7 @@ func main() {
 
 	// Execute the workflow with an odd number (Branch 2: A -> C -> E)
 	fmt.Println("\n==================================================")
-	currentState = "A"
+	currentState = definition.InitialState
 	data = map[string]any{"number": 7, "state": currentState}
 
 	fmt.Println("Starting conditional workflow with odd number (7): A -> C -> E")
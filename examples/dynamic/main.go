@@ -61,7 +61,7 @@ func main() {
 
 	// Execute the workflow
 	ctx := context.Background()
-	currentState := "A"
+	currentState := definition.InitialState
 	data := map[string]any{"state": currentState}
 
 	fmt.Println("Starting dynamic workflow with side quests")
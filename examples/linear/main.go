@@ -32,15 +32,15 @@ func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
 	// Create state machine
-	fsm := machina.NewStateMachine(definition, registry, logger)
-	if fsm == nil {
-		fmt.Println("Failed to create state machine")
+	fsm, err := machina.NewStateMachineE(definition, registry, logger)
+	if err != nil {
+		fmt.Printf("Failed to create state machine: %v\n", err)
 		return
 	}
 
 	// Execute the workflow
 	ctx := context.Background()
-	currentState := "A"
+	currentState := definition.InitialState
 
 	fmt.Println("Starting simple linear workflow: A -> B -> C")
 	
@@ -61,7 +61,7 @@ func main() {
 
 	// Execute the workflow normally first
 	ctx := context.Background()
-	currentState := "A"
+	currentState := definition.InitialState
 	data := map[string]any{"state": currentState}
 
 	fmt.Println("Starting timeout workflow with auto-reset")
@@ -90,15 +90,22 @@ func main() {
 
 	fmt.Printf("Current state: %s (timer started, but not expired yet)\n", currentState)
 
-	// Simulate timeout - transition to A with timeout event
-	fmt.Println("\n--- Simulating timeout ---")
-	result, err = fsm.Trigger(ctx, currentState, "timeout", data)
+	// Wait for the timeout declared on the state, then trigger its timeout event
+	fmt.Println("\n--- Waiting for timeout ---")
+	timeouts, err := fsm.WatchTimeout(ctx, currentState, data)
+	if err != nil {
+		fmt.Printf("Error watching timeout in %s: %v\n", currentState, err)
+		return
+	}
+	timeoutEvent := <-timeouts
+	result, err = fsm.Trigger(ctx, currentState, timeoutEvent, data)
 	if err != nil {
 		fmt.Printf("Error transitioning from %s: %v\n", currentState, err)
 		return
 	}
 	currentState = result.NewState
-	data = result.PersistenceData
+	// Start over with the business data only, dropping the engine's bookkeeping keys
+	data = machina.ResetData(result.PersistenceData)
 	data["state"] = currentState
 
 	fmt.Printf("After timeout, workflow reset to state: %s\n", currentState)
@@ -48,7 +48,7 @@ func main() {
 
 	// Execute the workflow normally first
 	ctx := context.Background()
-	currentState := "A"
+	currentState := definition.InitialState
 	data := map[string]any{"state": currentState}
 
 	fmt.Println("Starting timeout workflow demonstration")
@@ -83,7 +83,7 @@ func main() {
 	fmt.Println("Retry path should be: A -> C -> D (skipping B)")
 
 	// Reset to A
-	currentState = "A"
+	currentState = definition.InitialState
 	data = map[string]any{"state": currentState, "retry": true}
 
 	// Transition from A to B again (but this time it's a retry)
@@ -0,0 +1,50 @@
+package machina
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ErrAbortTransition can be returned (or wrapped) by any transition, OnLeave or OnEnter
+// action to veto the transition. Trigger stops cleanly, leaves the state unchanged and
+// returns an *ErrTransitionAborted rather than treating it as a failure. A transition's
+// OnError actions still run when one of its actions aborts, so the work of the actions
+// before it can be undone.
+var ErrAbortTransition = errors.New("transition aborted")
+
+// ErrTransitionAborted is returned by Trigger when an action vetoed the transition with
+// ErrAbortTransition. The workflow remains in State.
+type ErrTransitionAborted struct {
+	State  string
+	Event  string
+	Action string
+	// Reason is the message of the error returned by the action
+	Reason string
+
+	err error
+}
+
+// Error implements the error interface
+func (e *ErrTransitionAborted) Error() string {
+	return fmt.Sprintf("transition from %s on event %s aborted by action %s: %s", e.State, e.Event, e.Action, e.Reason)
+}
+
+// Unwrap returns the error returned by the action, so errors.Is(err, ErrAbortTransition) holds
+func (e *ErrTransitionAborted) Unwrap() error {
+	return e.err
+}
+
+// abortTransition records a vetoed transition and returns the error reported to the caller
+func (sm *StateMachine) abortTransition(logger *slog.Logger, currentState, event, actionName string, err error) error {
+	aborted := &ErrTransitionAborted{
+		State:  currentState,
+		Event:  event,
+		Action: actionName,
+		Reason: err.Error(),
+		err:    err,
+	}
+	logger.Info("Transition aborted", "action", actionName, "reason", aborted.Reason)
+	sm.recordTransitionError(currentState, event, "transition_aborted", aborted)
+	return aborted
+}
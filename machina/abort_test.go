@@ -0,0 +1,97 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestStateMachine_Trigger_AbortTransition(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:   "pay",
+						Target:  "paid",
+						Actions: []string{"updateAction", "fraudCheck"},
+					},
+				},
+			},
+			"paid": {
+				Name:    "paid",
+				OnEnter: []string{"onEnterPaid"},
+			},
+		},
+	}
+
+	var entered bool
+	registry := NewRegistry()
+	registry.RegisterAction("updateAction", MockUpdateAction)
+	registry.RegisterAction("fraudCheck", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, fmt.Errorf("card flagged: %w", ErrAbortTransition)
+	})
+	registry.RegisterAction("onEnterPaid", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		entered = true
+		return nil, nil
+	})
+
+	sm := NewStateMachine(definition, registry, slog.Default(), WithMetrics(reg), WithTracer(noop.NewTracerProvider().Tracer("test")))
+
+	result, err := sm.Trigger(context.Background(), "start", "pay", map[string]any{})
+	if result != nil {
+		t.Errorf("Expected no result, got %v", result)
+	}
+
+	var aborted *ErrTransitionAborted
+	if !errors.As(err, &aborted) {
+		t.Fatalf("Expected *ErrTransitionAborted, got %v", err)
+	}
+
+	if aborted.State != "start" || aborted.Event != "pay" || aborted.Action != "fraudCheck" {
+		t.Errorf("Expected abort from start/pay/fraudCheck, got %s/%s/%s", aborted.State, aborted.Event, aborted.Action)
+	}
+
+	if aborted.Reason != "card flagged: transition aborted" {
+		t.Errorf("Expected reason 'card flagged: transition aborted', got '%s'", aborted.Reason)
+	}
+
+	if !errors.Is(err, ErrAbortTransition) {
+		t.Error("Expected errors.Is(err, ErrAbortTransition) to hold")
+	}
+
+	if entered {
+		t.Error("Expected OnEnter actions not to run after an abort")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	var errorTypes []string
+	for _, family := range families {
+		if family.GetName() != "gomachina_transition_errors_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "error_type" {
+					errorTypes = append(errorTypes, label.GetValue())
+				}
+			}
+		}
+	}
+
+	if len(errorTypes) != 1 || errorTypes[0] != "transition_aborted" {
+		t.Errorf("Expected a single transition_aborted error, got %v", errorTypes)
+	}
+}
@@ -0,0 +1,60 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// AuthorizerFunc decides whether the caller may fire a transition, typically by comparing
+// the identity carried in ctx with the transition's Roles. A non-nil error rejects it.
+type AuthorizerFunc func(ctx context.Context, transition Transition, data map[string]any) error
+
+// ErrUnauthorized is returned by Trigger when the authorizer configured with WithAuthorizer
+// rejected the transition. The workflow remains in State.
+type ErrUnauthorized struct {
+	State string
+	Event string
+	// Roles are the roles the rejected transition declares
+	Roles []string
+	// Err is the error returned by the authorizer
+	Err error
+}
+
+// Error implements the error interface
+func (e *ErrUnauthorized) Error() string {
+	return fmt.Sprintf("not authorized to trigger event %s in state %s: %v", e.Event, e.State, e.Err)
+}
+
+// Unwrap returns the error returned by the authorizer
+func (e *ErrUnauthorized) Unwrap() error {
+	return e.Err
+}
+
+// WithAuthorizer makes Trigger call authorize once the transition for the event has been
+// found and before its payload is validated or its conditions and actions run. When several
+// transitions handle the event, their conditions still pick the one to authorize.
+func WithAuthorizer(authorize AuthorizerFunc) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.authorizer = authorize
+	}
+}
+
+// authorize runs the configured authorizer, if any, against the chosen transition
+func (sm *StateMachine) authorize(ctx context.Context, currentState, event string, transition *Transition, payload map[string]any) error {
+	if sm.authorizer == nil {
+		return nil
+	}
+
+	err := sm.authorizer(ctx, *transition, payload)
+	if err == nil {
+		return nil
+	}
+
+	var unauthorized *ErrUnauthorized
+	if !errors.As(err, &unauthorized) {
+		err = &ErrUnauthorized{State: currentState, Event: event, Roles: transition.Roles, Err: err}
+	}
+	sm.recordTransitionError(currentState, event, "unauthorized", err)
+	return err
+}
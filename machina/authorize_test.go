@@ -0,0 +1,91 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+type roleKey struct{}
+
+// roleAuthorizer allows transitions without roles, and others only if the role in ctx is listed
+func roleAuthorizer(ctx context.Context, transition Transition, data map[string]any) error {
+	if len(transition.Roles) == 0 {
+		return nil
+	}
+	role, _ := ctx.Value(roleKey{}).(string)
+	if !slices.Contains(transition.Roles, role) {
+		return fmt.Errorf("role %q is not allowed", role)
+	}
+	return nil
+}
+
+func TestStateMachine_Trigger_Authorizer(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"paid": {
+				Name: "paid",
+				Transitions: []Transition{
+					{Event: "refund", Target: "refunded", Roles: []string{"admin"}, Actions: []string{"refundPayment"}},
+					{Event: "ship", Target: "shipped"},
+				},
+			},
+			"refunded": {Name: "refunded"},
+			"shipped":  {Name: "shipped"},
+		},
+	}
+
+	refunds := 0
+	registry := NewRegistry()
+	registry.RegisterAction("refundPayment", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		refunds++
+		return nil, nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil, WithAuthorizer(roleAuthorizer))
+	admin := context.WithValue(context.Background(), roleKey{}, "admin")
+	clerk := context.WithValue(context.Background(), roleKey{}, "clerk")
+
+	t.Run("Authorized", func(t *testing.T) {
+		result, err := fsm.Trigger(admin, "paid", "refund", map[string]any{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.NewState != "refunded" || refunds != 1 {
+			t.Errorf("Expected a refund and state 'refunded', got '%s' after %d refunds", result.NewState, refunds)
+		}
+
+		// Transitions without roles are left to the authorizer, which allows them here
+		if _, err := fsm.Trigger(clerk, "paid", "ship", map[string]any{}); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		result, err := fsm.Trigger(clerk, "paid", "refund", map[string]any{})
+		if result != nil {
+			t.Errorf("Expected no result, got %v", result)
+		}
+
+		var unauthorized *ErrUnauthorized
+		if !errors.As(err, &unauthorized) {
+			t.Fatalf("Expected *ErrUnauthorized, got %v", err)
+		}
+		if unauthorized.State != "paid" || unauthorized.Event != "refund" || !slices.Equal(unauthorized.Roles, []string{"admin"}) {
+			t.Errorf("Expected state, event and roles of the refund transition, got %+v", unauthorized)
+		}
+		expected := `not authorized to trigger event refund in state paid: role "clerk" is not allowed`
+		if err.Error() != expected {
+			t.Errorf("Expected error '%s', got '%s'", expected, err.Error())
+		}
+		if refunds != 1 {
+			t.Errorf("Expected the refund action not to run, got %d refunds", refunds)
+		}
+
+		if ok, err := fsm.CanTrigger(clerk, "paid", "refund", map[string]any{}); ok || err != nil {
+			t.Errorf("Expected CanTrigger to report false without error, got %v, %v", ok, err)
+		}
+	})
+}
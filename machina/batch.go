@@ -0,0 +1,65 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+)
+
+// BatchOption configures a TriggerBatch call
+type BatchOption func(*batchConfig)
+
+// batchConfig holds the settings collected from BatchOptions
+type batchConfig struct {
+	continueOnError bool
+}
+
+// ContinueOnError makes TriggerBatch skip events that fail instead of stopping at the
+// first error. A failed event leaves the state and persistence data unchanged.
+func ContinueOnError() BatchOption {
+	return func(c *batchConfig) {
+		c.continueOnError = true
+	}
+}
+
+// TriggerBatch applies externally supplied events to one workflow instance in order,
+// feeding each event the state and persistence data produced by the previous one.
+// By default it stops at the first error. The result reports the final state and data,
+// with History listing every transition applied; the errors are those of the failed
+// events, or nil if all of them succeeded.
+func (sm *StateMachine) TriggerBatch(ctx context.Context, startState string, events []string, payload map[string]any, opts ...BatchOption) (*TransitionResult, []error) {
+	var config batchConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	result := &TransitionResult{
+		NewState:        startState,
+		PersistenceData: make(map[string]any, len(payload)),
+	}
+	for k, v := range payload {
+		result.PersistenceData[k] = v
+	}
+
+	var history []TransitionStep
+	var errs []error
+	for i, event := range events {
+		stepResult, err := sm.Trigger(ctx, result.NewState, event, result.PersistenceData)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("event %d (%s) from state %s failed: %w", i, event, result.NewState, err))
+			if !config.continueOnError {
+				break
+			}
+			continue
+		}
+
+		if len(stepResult.History) > 0 {
+			history = append(history, stepResult.History...)
+		} else {
+			history = append(history, TransitionStep{FromState: result.NewState, Event: event, ToState: stepResult.NewState})
+		}
+		result = stepResult
+	}
+
+	result.History = history
+	return result, errs
+}
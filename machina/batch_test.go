@@ -0,0 +1,125 @@
+package machina
+
+import (
+	"context"
+	"testing"
+)
+
+// batchTestMachine returns a machine for the linear workflow a -> b -> c -> d
+func batchTestMachine() *StateMachine {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"a": {
+				Name: "a",
+				Transitions: []Transition{
+					{Event: "next", Target: "b", Actions: []string{"updateAction"}},
+				},
+			},
+			"b": {
+				Name: "b",
+				Transitions: []Transition{
+					{Event: "next", Target: "c", Actions: []string{"countAction"}},
+				},
+			},
+			"c": {
+				Name: "c",
+				Transitions: []Transition{
+					{Event: "next", Target: "d", Actions: []string{"countAction"}},
+				},
+			},
+			"d": {
+				Name: "d",
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("updateAction", MockUpdateAction)
+	registry.RegisterAction("countAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		count, _ := data["count"].(int)
+		return map[string]any{"count": count + 1}, nil
+	})
+
+	return NewStateMachine(definition, registry, nil)
+}
+
+func TestStateMachine_TriggerBatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		events         []string
+		opts           []BatchOption
+		expectedState  string
+		expectedCount  int
+		expectedSteps  int
+		expectedErrors int
+	}{
+		{
+			name:          "AllEventsApplied",
+			events:        []string{"next", "next", "next"},
+			expectedState: "d",
+			expectedCount: 2,
+			expectedSteps: 3,
+		},
+		{
+			name:           "StopsAtFirstError",
+			events:         []string{"next", "bogus", "next"},
+			expectedState:  "b",
+			expectedCount:  0,
+			expectedSteps:  1,
+			expectedErrors: 1,
+		},
+		{
+			name:           "ContinueOnError",
+			events:         []string{"next", "bogus", "next", "bogus"},
+			opts:           []BatchOption{ContinueOnError()},
+			expectedState:  "c",
+			expectedCount:  1,
+			expectedSteps:  2,
+			expectedErrors: 2,
+		},
+		{
+			name:          "NoEvents",
+			expectedState: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := map[string]any{"orderID": 7}
+			result, errs := batchTestMachine().TriggerBatch(context.Background(), "a", tt.events, payload, tt.opts...)
+
+			if len(errs) != tt.expectedErrors {
+				t.Fatalf("Expected %d errors, got %v", tt.expectedErrors, errs)
+			}
+
+			if result.NewState != tt.expectedState {
+				t.Errorf("Expected state %s, got %s", tt.expectedState, result.NewState)
+			}
+
+			count, _ := result.PersistenceData["count"].(int)
+			if count != tt.expectedCount {
+				t.Errorf("Expected count %d, got %d", tt.expectedCount, count)
+			}
+
+			if result.PersistenceData["orderID"] != 7 {
+				t.Errorf("Expected payload to be threaded through, got %v", result.PersistenceData)
+			}
+
+			if len(result.History) != tt.expectedSteps {
+				t.Errorf("Expected %d steps in history, got %v", tt.expectedSteps, result.History)
+			}
+		})
+	}
+}
+
+func TestStateMachine_TriggerBatch_ErrorMessage(t *testing.T) {
+	_, errs := batchTestMachine().TriggerBatch(context.Background(), "a", []string{"next", "bogus"}, nil)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+
+	expected := "event 1 (bogus) from state b failed: no valid transition found for event bogus in state b: no transition for 'bogus' in state b (did you mean: next?)"
+	if errs[0].Error() != expected {
+		t.Errorf("Expected error '%s', got '%s'", expected, errs[0].Error())
+	}
+}
@@ -0,0 +1,208 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// EventBus carries messages between machines in the same process. See WithEventBus and
+// ConsumeEvents. Unsubscribe ends a subscription returned by Subscribe and closes its channel.
+type EventBus interface {
+	Publish(topic string, data map[string]any)
+	Subscribe(topic string) <-chan map[string]any
+	Unsubscribe(topic string, ch <-chan map[string]any)
+}
+
+// Keys of the messages published by a machine created with WithEventBus
+const (
+	// BusFromKey holds the state the transition left
+	BusFromKey = "from"
+	// BusToKey holds the state the transition entered
+	BusToKey = "to"
+	// BusEventKey holds the event that triggered the transition
+	BusEventKey = "event"
+	// BusDataKey holds a copy of the transition's persistence data
+	BusDataKey = "data"
+)
+
+// WithEventBus makes the machine publish every successful transition to bus under the topic
+// workflow.<name>.<event>, where name identifies the workflow. Messages hold the BusFromKey,
+// BusToKey, BusEventKey and BusDataKey keys. Publishing happens before Trigger returns.
+func WithEventBus(name string, bus EventBus) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.busName = name
+		sm.eventBus = bus
+	}
+}
+
+// BusTopic returns the topic a machine created with WithEventBus(name, ...) publishes
+// transitions for event to
+func BusTopic(name, event string) string {
+	return "workflow." + name + "." + event
+}
+
+// publishTransition publishes a completed transition to the event bus, if one is set
+func (sm *StateMachine) publishTransition(currentState, event, target string, persistenceData map[string]any) {
+	if sm.eventBus == nil {
+		return
+	}
+
+	sm.eventBus.Publish(BusTopic(sm.busName, event), map[string]any{
+		BusFromKey:  currentState,
+		BusToKey:    target,
+		BusEventKey: event,
+		BusDataKey:  maps.Clone(persistenceData),
+	})
+}
+
+// BusRoute maps a message received from the event bus to the state of the instance it
+// concerns and the payload to trigger the event with
+type BusRoute func(msg map[string]any) (state string, payload map[string]any, err error)
+
+// ConsumeEvents subscribes to topic on the machine's event bus and triggers event for every
+// message received, until ctx is done or the bus closes the subscription. The subscription
+// is removed from the bus when ctx is done. route picks the
+// state and payload for each message, and done, if not nil, receives every result, for
+// example to persist the new state. Messages route rejects are passed to done without
+// triggering. It returns once subscribed and processes messages in the background.
+func (sm *StateMachine) ConsumeEvents(ctx context.Context, topic, event string, route BusRoute, done func(*TransitionResult, error)) error {
+	if sm.eventBus == nil {
+		return fmt.Errorf("no event bus configured; create the machine with WithEventBus")
+	}
+	if route == nil {
+		return fmt.Errorf("route must not be nil")
+	}
+
+	messages := sm.eventBus.Subscribe(topic)
+	go func() {
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				result, err := sm.consumeEvent(ctx, topic, event, route, msg)
+				if done != nil {
+					done(result, err)
+				}
+			case <-ctx.Done():
+				sm.eventBus.Unsubscribe(topic, messages)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// consumeEvent triggers event for a single message received on topic
+func (sm *StateMachine) consumeEvent(ctx context.Context, topic, event string, route BusRoute, msg map[string]any) (*TransitionResult, error) {
+	state, payload, err := route(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route message from topic %s: %w", topic, err)
+	}
+
+	sm.logger.Debug("Triggering event from bus", "topic", topic, "state", state, "event", event)
+	return sm.Trigger(ctx, state, event, payload)
+}
+
+// MemoryEventBus is an in-process EventBus. Every subscriber of a topic receives its own
+// copy of each message. Publish never waits for a subscriber: a message is dropped for a
+// subscriber whose buffer is full, and Dropped reports how many were.
+type MemoryEventBus struct {
+	subscribers map[string][]chan map[string]any
+	closed      bool
+	dropped     atomic.Uint64
+	mu          sync.RWMutex
+}
+
+// memoryEventBusBuffer is the number of messages buffered per subscription
+const memoryEventBusBuffer = 16
+
+// NewMemoryEventBus creates an empty in-memory event bus
+func NewMemoryEventBus() *MemoryEventBus {
+	return &MemoryEventBus{
+		subscribers: make(map[string][]chan map[string]any),
+	}
+}
+
+// Publish sends a copy of data to every subscriber of topic, dropping it for subscribers
+// whose buffer is full. It does nothing once the bus is closed.
+func (b *MemoryEventBus) Publish(topic string, data map[string]any) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+	for _, ch := range b.subscribers[topic] {
+		select {
+		case ch <- maps.Clone(data):
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of messages dropped because a subscriber's buffer was full
+func (b *MemoryEventBus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Subscribe returns a channel receiving the messages published to topic from now on.
+// The channel is closed when the bus is closed.
+func (b *MemoryEventBus) Subscribe(topic string) <-chan map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan map[string]any, memoryEventBusBuffer)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers[topic] = append(b.subscribers[topic], ch)
+	return ch
+}
+
+// Unsubscribe removes a subscription to topic and closes its channel. Unknown
+// subscriptions are ignored.
+func (b *MemoryEventBus) Unsubscribe(topic string, ch <-chan map[string]any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscribers := b.subscribers[topic]
+	for i, subscriber := range subscribers {
+		if subscriber != ch {
+			continue
+		}
+		close(subscriber)
+		if len(subscribers) == 1 {
+			delete(b.subscribers, topic)
+		} else {
+			b.subscribers[topic] = slices.Delete(subscribers, i, i+1)
+		}
+		return
+	}
+}
+
+// Close closes every subscription. Later messages are dropped.
+func (b *MemoryEventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, subscribers := range b.subscribers {
+		for _, ch := range subscribers {
+			close(ch)
+		}
+	}
+	// The channels are closed, so a later Unsubscribe must not find them
+	clear(b.subscribers)
+}
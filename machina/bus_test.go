@@ -0,0 +1,179 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestStateMachine_EventBus(t *testing.T) {
+	orders := &WorkflowDefinition{
+		States: map[string]State{
+			"placed": {
+				Name:        "placed",
+				Transitions: []Transition{{Event: "ship", Target: "shipped", Actions: []string{"updateAction"}}},
+			},
+			"shipped": {Name: "shipped"},
+		},
+	}
+	billing := &WorkflowDefinition{
+		States: map[string]State{
+			"waiting": {
+				Name:        "waiting",
+				Transitions: []Transition{{Event: "invoice", Target: "invoiced", RequiredData: []string{"orderID"}}},
+			},
+			"invoiced": {Name: "invoiced"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("updateAction", MockUpdateAction)
+
+	bus := NewMemoryEventBus()
+	defer bus.Close()
+
+	machineA := NewStateMachine(orders, registry, slog.Default(), WithEventBus("orders", bus))
+	machineB := NewStateMachine(billing, registry, slog.Default(), WithEventBus("billing", bus))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type outcome struct {
+		result *TransitionResult
+		err    error
+	}
+	outcomes := make(chan outcome, 1)
+	route := func(msg map[string]any) (string, map[string]any, error) {
+		data, ok := msg[BusDataKey].(map[string]any)
+		if !ok || msg[BusToKey] != "shipped" {
+			return "", nil, fmt.Errorf("unexpected message %v", msg)
+		}
+		return "waiting", data, nil
+	}
+	err := machineB.ConsumeEvents(ctx, BusTopic("orders", "ship"), "invoice", route, func(result *TransitionResult, err error) {
+		outcomes <- outcome{result, err}
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	invoices := bus.Subscribe(BusTopic("billing", "invoice"))
+
+	if _, err := machineA.Trigger(ctx, "placed", "ship", map[string]any{"orderID": "42"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	select {
+	case got := <-outcomes:
+		if got.err != nil {
+			t.Fatalf("Expected machine B to trigger without error, got %v", got.err)
+		}
+		if got.result.NewState != "invoiced" {
+			t.Errorf("Expected machine B to move to invoiced, got %s", got.result.NewState)
+		}
+		if got.result.PersistenceData["updated"] != true {
+			t.Errorf("Expected machine B to receive machine A's data, got %v", got.result.PersistenceData)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected machine B to consume the event")
+	}
+
+	select {
+	case msg := <-invoices:
+		if msg[BusFromKey] != "waiting" || msg[BusToKey] != "invoiced" || msg[BusEventKey] != "invoice" {
+			t.Errorf("Expected machine B to publish its transition, got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected machine B to publish its transition")
+	}
+}
+
+func TestStateMachine_ConsumeEvents_NoBus(t *testing.T) {
+	sm := NewStateMachine(routingDefinition(), NewRegistry(), slog.Default())
+
+	route := func(msg map[string]any) (string, map[string]any, error) { return "start", msg, nil }
+	err := sm.ConsumeEvents(context.Background(), "workflow.orders.ship", "route", route, nil)
+	if err == nil || err.Error() != "no event bus configured; create the machine with WithEventBus" {
+		t.Errorf("Expected a missing event bus error, got %v", err)
+	}
+}
+
+func TestMemoryEventBus_FullSubscriber(t *testing.T) {
+	bus := NewMemoryEventBus()
+	slow := bus.Subscribe("orders")
+	fast := bus.Subscribe("orders")
+
+	// The slow subscriber never reads, so its buffer fills up and later messages are dropped
+	// for it instead of blocking Publish
+	published := memoryEventBusBuffer + 4
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < published; i++ {
+			bus.Publish("orders", map[string]any{"n": i})
+			<-fast
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Publish not to block on a full subscriber")
+	}
+
+	if got := bus.Dropped(); got != 4 {
+		t.Errorf("Expected 4 dropped messages, got %d", got)
+	}
+	if got := len(slow); got != memoryEventBusBuffer {
+		t.Errorf("Expected the slow subscriber to hold %d messages, got %d", memoryEventBusBuffer, got)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close not to block")
+	}
+}
+
+func TestStateMachine_ConsumeEvents_Unsubscribes(t *testing.T) {
+	bus := NewMemoryEventBus()
+	defer bus.Close()
+
+	sm := NewStateMachine(routingDefinition(), NewRegistry(), slog.Default(), WithEventBus("orders", bus))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	route := func(msg map[string]any) (string, map[string]any, error) { return "start", msg, nil }
+	if err := sm.ConsumeEvents(ctx, "workflow.other.done", "route", route, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		bus.mu.RLock()
+		subscribers := len(bus.subscribers["workflow.other.done"])
+		bus.mu.RUnlock()
+		if subscribers == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the subscription to be removed once the context is done")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Unsubscribing twice is harmless
+	ch := bus.Subscribe("orders")
+	bus.Unsubscribe("orders", ch)
+	bus.Unsubscribe("orders", ch)
+	if _, ok := <-ch; ok {
+		t.Error("Expected the channel to be closed by Unsubscribe")
+	}
+}
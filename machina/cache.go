@@ -0,0 +1,81 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WithoutConditionCache makes every condition run each time it is referenced. By default a
+// condition runs at most once per transition for the same arguments, which assumes that
+// conditions are side-effect free and only depend on the payload and context.
+func WithoutConditionCache() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.conditionCacheDisabled = true
+	}
+}
+
+// conditionCacheEntryKey identifies a condition evaluation that can be reused
+type conditionCacheEntryKey struct {
+	name string
+	// args is the formatted condition arguments; fmt prints maps in key order
+	args string
+	// from, event and target are only set for transition conditions, whose result depends
+	// on the TransitionContext, so one query over several events cannot mix their results
+	from   string
+	event  string
+	target string
+}
+
+// conditionCacheEntry is the outcome of a condition evaluation
+type conditionCacheEntry struct {
+	ok  bool
+	err error
+}
+
+// conditionCache holds the condition results of one transition or query
+type conditionCache struct {
+	mu      sync.Mutex
+	results map[conditionCacheEntryKey]conditionCacheEntry
+}
+
+// withConditionCache returns a context carrying a new condition cache, unless caching is disabled
+func (sm *StateMachine) withConditionCache(ctx context.Context) context.Context {
+	if sm.conditionCacheDisabled {
+		return ctx
+	}
+	return context.WithValue(ctx, conditionCacheKey, &conditionCache{results: make(map[conditionCacheEntryKey]conditionCacheEntry)})
+}
+
+// cachedCondition evaluates the condition through the context's cache, if any
+func (sm *StateMachine) cachedCondition(ctx context.Context, conditionName string, condition conditionFunc, transition TransitionContext, payload map[string]any, args map[string]any) (bool, error) {
+	cache, ok := ctx.Value(conditionCacheKey).(*conditionCache)
+	if !ok {
+		return sm.evaluateCondition(ctx, conditionName, condition, transition, payload, args)
+	}
+
+	key := conditionCacheEntryKey{name: conditionName}
+	if len(args) > 0 {
+		key.args = fmt.Sprint(args)
+	}
+	if sm.registry.isTransitionCondition(conditionName) {
+		key.from = transition.From
+		key.event = transition.Event
+		key.target = transition.Target
+	}
+
+	cache.mu.Lock()
+	entry, hit := cache.results[key]
+	cache.mu.Unlock()
+	if hit {
+		return entry.ok, entry.err
+	}
+
+	ok, err := sm.evaluateCondition(ctx, conditionName, condition, transition, payload, args)
+
+	cache.mu.Lock()
+	cache.results[key] = conditionCacheEntry{ok: ok, err: err}
+	cache.mu.Unlock()
+
+	return ok, err
+}
@@ -0,0 +1,121 @@
+package machina
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestStateMachine_ConditionCache(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"payment": {
+				Name: "payment",
+				Transitions: []Transition{
+					{Event: "settle", Target: "refunded", Conditions: []string{"isPaymentSuccess", "isRefundRequested"}},
+					{Event: "settle", Target: "shipped", Conditions: []string{"isPaymentSuccess"}},
+					{Event: "ship", Target: "shipped", Conditions: []string{"isPaymentSuccess"}},
+				},
+			},
+			"refunded": {Name: "refunded"},
+			"shipped":  {Name: "shipped"},
+		},
+	}
+
+	calls := 0
+	registry := NewRegistry()
+	registry.RegisterCondition("isPaymentSuccess", func(ctx context.Context, data map[string]any) (bool, error) {
+		calls++
+		return true, nil
+	})
+	registry.RegisterCondition("isRefundRequested", MockFalseCondition)
+
+	tests := []struct {
+		name          string
+		opts          []StateMachineOption
+		query         func(fsm *StateMachine) error
+		expectedCalls int
+	}{
+		{
+			name: "TriggerEvaluatesOnce",
+			query: func(fsm *StateMachine) error {
+				_, err := fsm.Trigger(context.Background(), "payment", "settle", map[string]any{})
+				return err
+			},
+			expectedCalls: 1,
+		},
+		{
+			name: "AvailableEventsEvaluatesOnce",
+			query: func(fsm *StateMachine) error {
+				_, err := fsm.AvailableEvents(context.Background(), "payment", map[string]any{})
+				return err
+			},
+			expectedCalls: 1,
+		},
+		{
+			name: "OptOut",
+			opts: []StateMachineOption{WithoutConditionCache()},
+			query: func(fsm *StateMachine) error {
+				_, err := fsm.Trigger(context.Background(), "payment", "settle", map[string]any{})
+				return err
+			},
+			// Once for each settle transition during selection, and again for the chosen one
+			expectedCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			fsm := NewStateMachine(definition, registry, nil, tt.opts...)
+			if err := tt.query(fsm); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("Expected %d condition calls, got %d", tt.expectedCalls, calls)
+			}
+		})
+	}
+
+	// The cache lasts for a single call
+	calls = 0
+	fsm := NewStateMachine(definition, registry, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := fsm.Trigger(context.Background(), "payment", "ship", map[string]any{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 condition calls across two triggers, got %d", calls)
+	}
+}
+
+func TestStateMachine_ConditionCache_TransitionConditionPerEvent(t *testing.T) {
+	// Both transitions share a target, so only the event tells the guard's evaluations apart
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"payment": {
+				Name: "payment",
+				Transitions: []Transition{
+					{Event: "ship", Target: "shipped", Conditions: []string{"allowedEvent"}},
+					{Event: "expedite", Target: "shipped", Conditions: []string{"allowedEvent"}},
+				},
+			},
+			"shipped": {Name: "shipped"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterTransitionCondition("allowedEvent", func(ctx context.Context, transition TransitionContext, data map[string]any) (bool, error) {
+		return transition.Event == "expedite", nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	events, err := fsm.AvailableEvents(context.Background(), "payment", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !slices.Equal(events, []string{"expedite"}) {
+		t.Errorf("Expected [expedite], got %v", events)
+	}
+}
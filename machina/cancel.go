@@ -0,0 +1,159 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+// CancelEvent is the event Cancel reports to actions through EventFromContext and in
+// logs and metrics
+const CancelEvent = "__cancel__"
+
+// ErrWorkflowCanceled is returned by Trigger when a condition or action requested
+// cancellation with CancelWorkflow. Unlike a context timeout, it is an application-level
+// decision. The TransitionResult returned alongside it reports the last known good state,
+// which is State unless the cancellation came from an OnEnter action and rolling back
+// failed as well.
+type ErrWorkflowCanceled struct {
+	State string
+	Event string
+	// Phase is the phase after which the cancellation was noticed: conditions, transition,
+	// OnLeave or OnEnter
+	Phase string
+	// Reason is the reason passed to CancelWorkflow
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ErrWorkflowCanceled) Error() string {
+	return fmt.Sprintf("workflow canceled during %s of transition from %s on event %s: %s", e.Phase, e.State, e.Event, e.Reason)
+}
+
+// cancelHolder collects the cancellation requested by conditions and actions. It is
+// guarded by mu since parallel action groups may cancel concurrently.
+type cancelHolder struct {
+	mu        sync.Mutex
+	requested bool
+	reason    string
+}
+
+// CancelWorkflow asks Trigger to stop the transition currently being processed and keep
+// the workflow in its last known good state. The caller should return normally: Trigger
+// checks for the request once the running phase has finished and then returns an
+// *ErrWorkflowCanceled. It may only be called from a condition or action executed by Trigger.
+func CancelWorkflow(ctx context.Context, reason string) error {
+	holder, ok := ctx.Value(cancelKey).(*cancelHolder)
+	if !ok {
+		return fmt.Errorf("workflow can only be canceled from within a transition")
+	}
+
+	if reason == "" {
+		return fmt.Errorf("cancellation reason must not be empty")
+	}
+
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+
+	// The first reason wins, so a later phase cannot mask why the workflow stopped
+	if !holder.requested {
+		holder.requested = true
+		holder.reason = reason
+	}
+	return nil
+}
+
+// withCancelHolder returns a context carrying a new holder for cancellation requests
+func withCancelHolder(ctx context.Context) (context.Context, *cancelHolder) {
+	holder := &cancelHolder{}
+	return context.WithValue(ctx, cancelKey, holder), holder
+}
+
+// checkWorkflowCanceled returns an *ErrWorkflowCanceled if a cancellation was requested
+// during phase
+func (sm *StateMachine) checkWorkflowCanceled(logger *slog.Logger, holder *cancelHolder, currentState, event, phase string) error {
+	holder.mu.Lock()
+	requested, reason := holder.requested, holder.reason
+	holder.mu.Unlock()
+
+	if !requested {
+		return nil
+	}
+
+	err := &ErrWorkflowCanceled{State: currentState, Event: event, Phase: phase, Reason: reason}
+	logger.Info("Workflow canceled", "phase", phase, "reason", reason)
+	sm.recordTransitionError(currentState, event, "workflow_canceled", err)
+	return err
+}
+
+// canceledResult returns the result reported with an *ErrWorkflowCanceled: the workflow
+// stays in currentState with data that is safe to trigger from it again
+func canceledResult(currentState string, payload map[string]any) *TransitionResult {
+	data := make(map[string]any, len(payload))
+	for k, v := range payload {
+		data[k] = v
+	}
+	return &TransitionResult{NewState: currentState, PersistenceData: data}
+}
+
+// Cancel moves a workflow from currentState to targetState, typically a terminal
+// "cancelled" state, without a declared transition. It runs the OnLeave actions of
+// currentState and then the OnEnter actions of targetState, merging their results into
+// a copy of payload. No conditions, transition actions or auto events are involved.
+// Actions see CancelEvent as the event.
+func (sm *StateMachine) Cancel(ctx context.Context, currentState, targetState string, payload map[string]any) (*TransitionResult, error) {
+	startTime := time.Now()
+	ctx, transitionID := withTransitionID(ctx)
+
+	logger := sm.logger.With("from", currentState, "event", CancelEvent, "txn_id", transitionID)
+	if workflowID, ok := WorkflowIDFromContext(ctx); ok {
+		logger = logger.With("workflow_id", workflowID)
+	}
+
+	ctx = context.WithValue(ctx, sourceStateKey, currentState)
+	ctx = context.WithValue(ctx, eventKey, CancelEvent)
+	ctx = sm.withClock(ctx)
+
+	stateDef, err := sm.getStateDefinition(currentState)
+	if err != nil {
+		err = withKind(fmt.Errorf("cannot cancel from state %s: %w", currentState, err), ErrStateNotFound)
+		sm.recordTransitionError(currentState, CancelEvent, "state_not_found", err)
+		return nil, err
+	}
+
+	targetStateDef, err := sm.getStateDefinition(targetState)
+	if err != nil {
+		err = withKind(fmt.Errorf("cannot cancel to state %s: %w", targetState, err), ErrStateNotFound)
+		sm.recordTransitionError(currentState, CancelEvent, "target_state_not_found", err)
+		return nil, err
+	}
+
+	persistenceData := maps.Clone(payload)
+	if persistenceData == nil {
+		persistenceData = make(map[string]any)
+	}
+	actionData := maps.Clone(persistenceData)
+
+	if err := sm.executeOnLeaveActions(ctx, logger, currentState, CancelEvent, stateDef.OnLeave, actionData, persistenceData); err != nil {
+		return nil, err
+	}
+
+	if err := sm.executeOnEnterActions(ctx, logger, currentState, CancelEvent, targetState, targetStateDef.OnEnter, actionData, persistenceData); err != nil {
+		return nil, err
+	}
+
+	duration := time.Since(startTime).Seconds()
+	if sm.metrics != nil {
+		sm.metrics.TransitionsTotal.WithLabelValues(currentState, targetState, CancelEvent).Inc()
+		sm.metrics.TransitionDuration.WithLabelValues(currentState, targetState, CancelEvent).Observe(duration)
+		sm.metrics.StatesCurrent.WithLabelValues(currentState).Dec()
+		sm.metrics.StatesCurrent.WithLabelValues(targetState).Inc()
+	}
+
+	logger.Info("Cancel completed", "to", targetState, "duration_seconds", duration)
+
+	return &TransitionResult{NewState: targetState, PersistenceData: persistenceData}, nil
+}
@@ -0,0 +1,173 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestStateMachine_Trigger_CancelWorkflow(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:    "start",
+				OnLeave: []string{"checkRecall"},
+				Transitions: []Transition{
+					{
+						Event:   "ship",
+						Target:  "shipped",
+						Actions: []string{"updateAction"},
+					},
+				},
+			},
+			"shipped": {
+				Name:    "shipped",
+				OnEnter: []string{"onEnterShipped"},
+			},
+		},
+	}
+
+	var entered bool
+	registry := NewRegistry()
+	registry.RegisterAction("updateAction", MockUpdateAction)
+	registry.RegisterAction("checkRecall", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		if err := CancelWorkflow(ctx, "product recalled"); err != nil {
+			return nil, err
+		}
+		return map[string]any{"recallChecked": true}, nil
+	})
+	registry.RegisterAction("onEnterShipped", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		entered = true
+		return nil, nil
+	})
+
+	sm := NewStateMachine(definition, registry, slog.Default())
+
+	result, err := sm.Trigger(context.Background(), "start", "ship", map[string]any{"orderID": "42"})
+
+	var canceled *ErrWorkflowCanceled
+	if !errors.As(err, &canceled) {
+		t.Fatalf("Expected *ErrWorkflowCanceled, got %v", err)
+	}
+
+	if canceled.State != "start" || canceled.Event != "ship" || canceled.Phase != "OnLeave" || canceled.Reason != "product recalled" {
+		t.Errorf("Expected cancel from start/ship/OnLeave with reason 'product recalled', got %+v", canceled)
+	}
+
+	if entered {
+		t.Error("Expected OnEnter actions not to run after a cancellation")
+	}
+
+	if result == nil || result.NewState != "start" {
+		t.Fatalf("Expected result for last good state start, got %v", result)
+	}
+
+	if result.PersistenceData["orderID"] != "42" {
+		t.Errorf("Expected payload to be preserved, got %v", result.PersistenceData)
+	}
+
+	for _, key := range []string{"updated", "recallChecked"} {
+		if _, ok := result.PersistenceData[key]; ok {
+			t.Errorf("Expected %s from the canceled transition to be discarded", key)
+		}
+	}
+}
+
+func TestStateMachine_Trigger_CancelWorkflowInAutoEventChain(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"pending": {
+				Name:        "pending",
+				Transitions: []Transition{{Event: "approve", Target: "approved", AutoEvent: "ship"}},
+			},
+			"approved": {
+				Name:        "approved",
+				Transitions: []Transition{{Event: "ship", Target: "shipped", Actions: []string{"cancelShipping"}}},
+			},
+			"shipped": {Name: "shipped"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("cancelShipping", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, CancelWorkflow(ctx, "address invalid")
+	})
+
+	sm := NewStateMachine(definition, registry, slog.Default(), WithAutoEventChaining(5))
+
+	result, err := sm.Trigger(context.Background(), "pending", "approve", map[string]any{})
+
+	var canceled *ErrWorkflowCanceled
+	if !errors.As(err, &canceled) || canceled.Phase != "transition" {
+		t.Fatalf("Expected *ErrWorkflowCanceled in phase transition, got %v", err)
+	}
+
+	if result == nil || result.NewState != "approved" {
+		t.Fatalf("Expected chain to stop in approved, got %v", result)
+	}
+
+	if len(result.History) != 1 || result.History[0].ToState != "approved" {
+		t.Errorf("Expected history up to approved, got %v", result.History)
+	}
+}
+
+func TestCancelWorkflow_OutsideTransition(t *testing.T) {
+	if err := CancelWorkflow(context.Background(), "stop"); err == nil {
+		t.Error("Expected error outside a transition, got nil")
+	}
+}
+
+func TestStateMachine_Cancel(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"shipping": {
+				Name:    "shipping",
+				OnLeave: []string{"releaseCourier"},
+				Transitions: []Transition{
+					{Event: "deliver", Target: "delivered"},
+				},
+			},
+			"delivered": {Name: "delivered", IsFinal: true},
+			"cancelled": {Name: "cancelled", IsFinal: true, OnEnter: []string{"refund"}},
+		},
+	}
+
+	var calls []string
+	registry := NewRegistry()
+	registry.RegisterAction("releaseCourier", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		event, _ := EventFromContext(ctx)
+		calls = append(calls, "releaseCourier:"+event)
+		return map[string]any{"courierReleased": true}, nil
+	})
+	registry.RegisterAction("refund", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		calls = append(calls, "refund")
+		return map[string]any{"refunded": data["amount"]}, nil
+	})
+
+	sm := NewStateMachine(definition, registry, slog.Default())
+	payload := map[string]any{"amount": 30}
+
+	result, err := sm.Cancel(context.Background(), "shipping", "cancelled", payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedCalls := []string{"releaseCourier:" + CancelEvent, "refund"}
+	if len(calls) != len(expectedCalls) || calls[0] != expectedCalls[0] || calls[1] != expectedCalls[1] {
+		t.Errorf("Expected calls %v, got %v", expectedCalls, calls)
+	}
+	if result.NewState != "cancelled" {
+		t.Errorf("Expected state 'cancelled', got '%s'", result.NewState)
+	}
+	if result.PersistenceData["courierReleased"] != true || result.PersistenceData["refunded"] != 30 {
+		t.Errorf("Expected OnLeave and OnEnter results in data, got %v", result.PersistenceData)
+	}
+	if _, modified := payload["refunded"]; modified {
+		t.Error("Expected the payload to be left unmodified")
+	}
+
+	if _, err := sm.Cancel(context.Background(), "shipping", "missing", payload); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound for an unknown target, got %v", err)
+	}
+}
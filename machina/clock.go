@@ -0,0 +1,121 @@
+package machina
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Conditions and actions that depend on time should read it
+// from ClockFromContext rather than calling time.Now, so tests can control it.
+type Clock interface {
+	Now() time.Time
+	// After sends the current time on the returned channel once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock backed by time.Now
+type realClock struct{}
+
+// Now implements Clock
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock sets the clock exposed to conditions and actions through ClockFromContext and
+// used to measure and wait for timeouts. Defaults to the system clock.
+func WithClock(clock Clock) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.clock = clock
+	}
+}
+
+// ClockFromContext returns the clock of the machine running the current condition or
+// action, or the system clock if there is none
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey).(Clock); ok {
+		return clock
+	}
+	return realClock{}
+}
+
+// withClock returns a context carrying the machine's clock
+func (sm *StateMachine) withClock(ctx context.Context) context.Context {
+	return context.WithValue(ctx, clockKey, sm.clock)
+}
+
+// FakeClock is a Clock that only moves when told to, for testing time-dependent workflows
+// deterministically. It is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+// fakeWaiter is a channel returned by FakeClock.After that has not fired yet
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock creates a fake clock reading now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements Clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After implements Clock. The channel fires once Advance or Set moves the clock to or past
+// d from now, or immediately if d is not positive.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	waiter := fakeWaiter{at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		waiter.ch <- c.now
+		return waiter.ch
+	}
+	c.waiters = append(c.waiters, waiter)
+	return waiter.ch
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Set moves the clock to now
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	c.fire()
+}
+
+// fire sends on the channels of waiters that are due. The channels are buffered, so it
+// never blocks while holding the lock.
+func (c *FakeClock) fire() {
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.at.After(c.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+		waiter.ch <- c.now
+	}
+	c.waiters = pending
+}
@@ -0,0 +1,105 @@
+package machina
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// notExpired passes while the clock is before the payload's expiresAt
+func notExpired(ctx context.Context, data map[string]any) (bool, error) {
+	expiresAt, _ := data["expiresAt"].(time.Time)
+	return ClockFromContext(ctx).Now().Before(expiresAt), nil
+}
+
+func TestStateMachine_Trigger_FakeClock(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"offered": {
+				Name: "offered",
+				Transitions: []Transition{
+					{Event: "accept", Target: "accepted", Conditions: []string{"notExpired"}},
+				},
+			},
+			"accepted": {Name: "accepted"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("notExpired", notExpired)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	fsm := NewStateMachine(definition, registry, nil, WithClock(clock))
+	ctx := context.Background()
+	payload := map[string]any{"expiresAt": start.Add(time.Hour)}
+
+	if ok, err := fsm.CanTrigger(ctx, "offered", "accept", payload); !ok || err != nil {
+		t.Errorf("Expected the offer to be acceptable before it expires, got %v, %v", ok, err)
+	}
+	if _, err := fsm.Trigger(ctx, "offered", "accept", payload); err != nil {
+		t.Errorf("Expected no error before expiry, got %v", err)
+	}
+
+	// Advancing past expiresAt flips the condition without waiting
+	clock.Advance(time.Hour)
+	if ok, err := fsm.CanTrigger(ctx, "offered", "accept", payload); ok || err != nil {
+		t.Errorf("Expected the offer not to be acceptable once expired, got %v, %v", ok, err)
+	}
+	if _, err := fsm.Trigger(ctx, "offered", "accept", payload); err == nil || err.Error() != "condition notExpired evaluated to false" {
+		t.Errorf("Expected the expiry condition to fail, got %v", err)
+	}
+
+	// Outside a machine the system clock is used
+	if ok, _ := notExpired(ctx, map[string]any{"expiresAt": time.Now().Add(time.Hour)}); !ok {
+		t.Error("Expected the system clock to be used without a machine")
+	}
+}
+
+func TestStateMachine_WatchTimeout_FakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start.Add(2 * time.Hour))
+	fsm := timeoutTestMachine(time.Hour, WithClock(clock))
+
+	// By the fake clock the timeout has already elapsed, so it fires immediately
+	events, err := fsm.WatchTimeout(context.Background(), "waiting", map[string]any{TimeoutStartKey: start})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event != "timeout" {
+			t.Errorf("Expected timeout event, got %q", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the timeout measured by the fake clock to fire immediately")
+	}
+}
+
+func TestStateMachine_WatchTimeout_FakeClockAdvance(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	fsm := timeoutTestMachine(time.Hour, WithClock(clock))
+
+	events, err := fsm.WatchTimeout(context.Background(), "waiting", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	clock.Advance(30 * time.Minute)
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no event before the timeout elapsed, got %q", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(30 * time.Minute)
+	select {
+	case event := <-events:
+		if event != "timeout" {
+			t.Errorf("Expected timeout event, got %q", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the timeout to fire once the fake clock was advanced past it")
+	}
+}
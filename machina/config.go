@@ -0,0 +1,93 @@
+package machina
+
+import "fmt"
+
+// defaultTracerName is the name of the tracer used unless WithTracer is applied
+const defaultTracerName = "gomachina"
+
+// MachineConfig is a read-only view of the options a StateMachine was created with, so a
+// deployment's configuration can be checked at runtime. Changing it has no effect on the machine.
+type MachineConfig struct {
+	// MetricsEnabled reports whether WithMetrics supplied a registerer
+	MetricsEnabled      bool   `json:"metricsEnabled"`
+	MetricsWorkflowName string `json:"metricsWorkflowName,omitempty"`
+	// CustomTracer reports whether WithTracer was applied. TracerName is the name of the
+	// default tracer, or the Go type of a custom one, since tracers don't expose their name.
+	CustomTracer bool   `json:"customTracer"`
+	TracerName   string `json:"tracerName"`
+	// LogLevel is the level set with WithLogLevel, or empty if the logger's own level applies
+	LogLevel string `json:"logLevel,omitempty"`
+	// Middleware is the number of middleware wrapping each transition
+	Middleware int `json:"middleware"`
+
+	AutoEventMaxDepth           int      `json:"autoEventMaxDepth"`
+	MaxStackDepth               int      `json:"maxStackDepth"`
+	MaxTransitionDepth          int      `json:"maxTransitionDepth"`
+	StrictSideQuests            bool     `json:"strictSideQuests"`
+	PredefinedActions           bool     `json:"predefinedActions"`
+	ProtectedKeys               []string `json:"protectedKeys,omitempty"`
+	RedactedKeys                []string `json:"redactedKeys,omitempty"`
+	ConditionCache              bool     `json:"conditionCache"`
+	ConditionFailureAsNonError  bool     `json:"conditionFailureAsNonError"`
+	CollectAllConditionFailures bool     `json:"collectAllConditionFailures"`
+	ParallelConditions          bool     `json:"parallelConditions"`
+	SelectionTrace              bool     `json:"selectionTrace"`
+	VisitCounting               bool     `json:"visitCounting"`
+	Authorizer                  bool     `json:"authorizer"`
+	Resolver                    bool     `json:"resolver"`
+	SubWorkflows                bool     `json:"subWorkflows"`
+	// IdempotencyKey is the payload key set with WithIdempotency, or empty if disabled
+	IdempotencyKey       string               `json:"idempotencyKey,omitempty"`
+	WebhookFailurePolicy WebhookFailurePolicy `json:"webhookFailurePolicy"`
+	// EventBus is the workflow name transitions are published under, or empty without WithEventBus
+	EventBus string `json:"eventBus,omitempty"`
+}
+
+// Config returns the options the StateMachine was created with
+func (sm *StateMachine) Config() MachineConfig {
+	config := MachineConfig{
+		MetricsEnabled:              sm.metricsRegisterer != nil,
+		MetricsWorkflowName:         sm.metricsConfig.WorkflowName,
+		CustomTracer:                sm.customTracer,
+		TracerName:                  defaultTracerName,
+		Middleware:                  len(sm.middleware),
+		AutoEventMaxDepth:           sm.autoEventMaxDepth,
+		MaxStackDepth:               sm.maxStackDepth,
+		MaxTransitionDepth:          sm.maxTransitionDepth,
+		StrictSideQuests:            sm.strictSideQuests,
+		PredefinedActions:           !sm.predefinedActionsDisabled,
+		ProtectedKeys:               sortedSet(sm.protectedKeys),
+		RedactedKeys:                sortedSet(sm.redactedKeys),
+		ConditionCache:              !sm.conditionCacheDisabled,
+		ConditionFailureAsNonError:  sm.conditionFailureAsNonError,
+		CollectAllConditionFailures: sm.collectAllConditionFailures,
+		ParallelConditions:          sm.parallelConditions,
+		SelectionTrace:              sm.selectionTrace,
+		VisitCounting:               sm.visitCounting,
+		Authorizer:                  sm.authorizer != nil,
+		Resolver:                    sm.resolver != nil,
+		SubWorkflows:                sm.subWorkflows != nil,
+		WebhookFailurePolicy:        sm.webhookFailurePolicy,
+	}
+	if sm.customTracer {
+		config.TracerName = fmt.Sprintf("%T", sm.tracer)
+	}
+	if sm.logLevel != nil {
+		config.LogLevel = sm.logLevel.Level().String()
+	}
+	if sm.idempotencyStore != nil {
+		config.IdempotencyKey = sm.idempotencyKey
+	}
+	if sm.eventBus != nil {
+		config.EventBus = sm.busName
+	}
+	return config
+}
+
+// sortedSet returns the keys of set in order, or nil if it is empty
+func sortedSet(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	return sortedKeys(set)
+}
@@ -0,0 +1,78 @@
+package machina
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestStateMachine_Config(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {Name: "start", Transitions: []Transition{{Event: "proceed", Target: "end"}}},
+			"end":   {Name: "end"},
+		},
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		config := NewStateMachine(definition, NewRegistry(), nil).Config()
+
+		if config.MetricsEnabled {
+			t.Error("Expected metrics to be disabled")
+		}
+		if config.CustomTracer {
+			t.Error("Expected the default tracer")
+		}
+		if config.TracerName != "gomachina" {
+			t.Errorf("Expected tracer name gomachina, got %s", config.TracerName)
+		}
+		if !config.PredefinedActions || !config.ConditionCache {
+			t.Errorf("Expected predefined actions and the condition cache to be enabled, got %+v", config)
+		}
+		if config.MaxStackDepth != 0 || config.ProtectedKeys != nil {
+			t.Errorf("Expected no stack limit or protected keys, got %+v", config)
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		provider := sdktrace.NewTracerProvider()
+		fsm := NewStateMachine(definition, NewRegistry(), nil,
+			WithMetrics(prometheus.NewRegistry()),
+			WithMetricsWorkflowName("orders"),
+			WithTracer(provider.Tracer("test")),
+			WithMaxStackDepth(5),
+			WithProtectedKeys("b", "a"),
+			WithMiddleware(func(next TriggerFunc) TriggerFunc { return next }),
+		)
+		config := fsm.Config()
+
+		if !config.MetricsEnabled {
+			t.Error("Expected metrics to be enabled")
+		}
+		if config.MetricsWorkflowName != "orders" {
+			t.Errorf("Expected metrics workflow name orders, got %s", config.MetricsWorkflowName)
+		}
+		if !config.CustomTracer {
+			t.Error("Expected a custom tracer")
+		}
+		if config.TracerName == "gomachina" {
+			t.Errorf("Expected the custom tracer's type, got %s", config.TracerName)
+		}
+		if config.MaxStackDepth != 5 {
+			t.Errorf("Expected max stack depth 5, got %d", config.MaxStackDepth)
+		}
+		if !slices.Equal(config.ProtectedKeys, []string{"a", "b"}) {
+			t.Errorf("Expected protected keys [a b], got %v", config.ProtectedKeys)
+		}
+		if config.Middleware != 1 {
+			t.Errorf("Expected 1 middleware, got %d", config.Middleware)
+		}
+
+		config.ProtectedKeys[0] = "changed"
+		if fsm.Config().ProtectedKeys[0] != "a" {
+			t.Error("Expected the returned config not to affect the machine")
+		}
+	})
+}
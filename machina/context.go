@@ -0,0 +1,150 @@
+package machina
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// contextKey is the type of the keys used to store values in a context
+type contextKey int
+
+// Context keys used by the state machine. Values are only accessible through the
+// exported helpers in this file so that they cannot collide with keys set by callers.
+const (
+	// nextStateKey holds the *nextStateHolder for the transition currently being processed
+	nextStateKey contextKey = iota
+	// workflowIDKey holds the caller-supplied workflow/correlation ID, set with WithWorkflowID
+	workflowIDKey
+	// transitionIDKey holds the unique ID Trigger generates for every transition
+	transitionIDKey
+	// sourceStateKey holds the state the transition currently being processed started from
+	sourceStateKey
+	// maxStackDepthKey holds the limit set by WithMaxStackDepth, if any
+	maxStackDepthKey
+	// transitionErrorKey holds the error that caused OnError actions to run
+	transitionErrorKey
+	// compensationKey holds the *compensationHolder for the transition currently being processed
+	compensationKey
+	// conditionCacheKey holds the *conditionCache for the transition currently being processed
+	conditionCacheKey
+	// eventKey holds the event that triggered the transition currently being processed
+	eventKey
+	// cancelKey holds the *cancelHolder for the transition currently being processed
+	cancelKey
+	// clockKey holds the Clock of the machine processing the transition
+	clockKey
+	// parallelGroupKey is set while a parallel group runs, as its results are merged later
+	parallelGroupKey
+	// transitionPathKey holds the "state:event" of each transition enclosing the current code
+	transitionPathKey
+)
+
+// TransitionErrorFromContext returns the transition action error that triggered the
+// OnError action currently running, or nil outside of OnError actions
+func TransitionErrorFromContext(ctx context.Context) error {
+	err, _ := ctx.Value(transitionErrorKey).(error)
+	return err
+}
+
+// FromStateFromContext returns the state the transition currently being processed started
+// from. It is available to all conditions and actions called by Trigger.
+func FromStateFromContext(ctx context.Context) (string, bool) {
+	state, ok := ctx.Value(sourceStateKey).(string)
+	return state, ok
+}
+
+// EventFromContext returns the event that triggered the transition currently being processed,
+// so actions such as OnEnter can tell how the state was entered. It is available to all
+// conditions and actions called by Trigger.
+func EventFromContext(ctx context.Context) (string, bool) {
+	event, ok := ctx.Value(eventKey).(string)
+	return event, ok
+}
+
+// WithWorkflowID returns a copy of ctx carrying the given workflow ID.
+// Trigger records it on spans and metrics, and actions and conditions can read it
+// back with WorkflowIDFromContext instead of looking it up in the payload.
+func WithWorkflowID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, workflowIDKey, id)
+}
+
+// WorkflowIDFromContext returns the workflow ID stored in ctx by WithWorkflowID
+func WorkflowIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(workflowIDKey).(string)
+	return id, ok
+}
+
+// TransitionIDFromContext returns the unique ID Trigger assigned to the transition
+// currently being processed. It is available to all conditions and actions.
+func TransitionIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(transitionIDKey).(string)
+	return id, ok
+}
+
+// TransitionDepthFromContext returns how many transitions enclose the current code: 1
+// inside a condition or action of a transition, 2 if that transition was triggered from an
+// action of another one, such as by a sub-workflow, and 0 outside any transition
+func TransitionDepthFromContext(ctx context.Context) int {
+	path, _ := ctx.Value(transitionPathKey).([]string)
+	return len(path)
+}
+
+// TransitionPathFromContext returns the transitions enclosing the current code, outermost
+// first, each as "state:event"
+func TransitionPathFromContext(ctx context.Context) []string {
+	path, _ := ctx.Value(transitionPathKey).([]string)
+	return slices.Clone(path)
+}
+
+// withTransitionPath returns a context whose path ends with the given transition, along
+// with the new depth
+func withTransitionPath(ctx context.Context, currentState, event string) (context.Context, int) {
+	path, _ := ctx.Value(transitionPathKey).([]string)
+	path = append(slices.Clip(path), currentState+":"+event)
+	return context.WithValue(ctx, transitionPathKey, path), len(path)
+}
+
+// withTransitionID returns a context carrying a freshly generated transition ID
+func withTransitionID(ctx context.Context) (context.Context, string) {
+	var b [16]byte
+	// crypto/rand.Read never returns an error on supported platforms
+	_, _ = rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	return context.WithValue(ctx, transitionIDKey, id), id
+}
+
+// nextStateHolder collects the dynamic target requested by transition actions
+type nextStateHolder struct {
+	state  string
+	sealed bool
+}
+
+// SetNextState routes the transition currently being processed to the given state,
+// overriding the target declared in the workflow definition.
+// It may only be called from a transition action executed by Trigger.
+func SetNextState(ctx context.Context, state string) error {
+	holder, ok := ctx.Value(nextStateKey).(*nextStateHolder)
+	if !ok {
+		return fmt.Errorf("next state can only be set from within a transition")
+	}
+
+	if holder.sealed {
+		return fmt.Errorf("next state can only be set by transition actions")
+	}
+
+	if state == "" {
+		return fmt.Errorf("next state must not be empty")
+	}
+
+	holder.state = state
+	return nil
+}
+
+// withNextStateHolder returns a context carrying a new holder for the dynamic transition target
+func withNextStateHolder(ctx context.Context) (context.Context, *nextStateHolder) {
+	holder := &nextStateHolder{}
+	return context.WithValue(ctx, nextStateKey, holder), holder
+}
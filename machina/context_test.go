@@ -0,0 +1,309 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSetNextState(t *testing.T) {
+	t.Run("OutsideTransition", func(t *testing.T) {
+		err := SetNextState(context.Background(), "end")
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+		if err.Error() != "next state can only be set from within a transition" {
+			t.Errorf("Unexpected error message '%s'", err.Error())
+		}
+	})
+
+	t.Run("EmptyState", func(t *testing.T) {
+		ctx, _ := withNextStateHolder(context.Background())
+		if err := SetNextState(ctx, ""); err == nil {
+			t.Error("Expected error for empty state, got nil")
+		}
+	})
+
+	t.Run("Sealed", func(t *testing.T) {
+		ctx, holder := withNextStateHolder(context.Background())
+		holder.sealed = true
+		if err := SetNextState(ctx, "end"); err == nil {
+			t.Error("Expected error after holder is sealed, got nil")
+		}
+	})
+
+	t.Run("RecordsState", func(t *testing.T) {
+		ctx, holder := withNextStateHolder(context.Background())
+		if err := SetNextState(ctx, "end"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if holder.state != "end" {
+			t.Errorf("Expected holder state to be 'end', got '%s'", holder.state)
+		}
+	})
+}
+
+func TestSetNextState_FromOnEnterFails(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "proceed",
+						Target: "end",
+					},
+				},
+			},
+			"end": {
+				Name:    "end",
+				OnEnter: []string{"routeAction"},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("routeAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, SetNextState(ctx, "start")
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	_, err := fsm.Trigger(context.Background(), "start", "proceed", map[string]any{})
+	if err == nil {
+		t.Error("Expected error when setting next state from OnEnter, got nil")
+	}
+}
+
+func TestWorkflowIDFromContext(t *testing.T) {
+	if _, ok := WorkflowIDFromContext(context.Background()); ok {
+		t.Error("Expected no workflow ID in empty context")
+	}
+
+	ctx := WithWorkflowID(context.Background(), "order-42")
+	id, ok := WorkflowIDFromContext(ctx)
+	if !ok || id != "order-42" {
+		t.Errorf("Expected workflow ID 'order-42', got '%s' (ok=%v)", id, ok)
+	}
+}
+
+func TestTransitionErrorFromContext(t *testing.T) {
+	if err := TransitionErrorFromContext(context.Background()); err != nil {
+		t.Errorf("Expected no transition error in empty context, got %v", err)
+	}
+
+	cause := errors.New("charge failed")
+	ctx := context.WithValue(context.Background(), transitionErrorKey, cause)
+	if err := TransitionErrorFromContext(ctx); err != cause {
+		t.Errorf("Expected %v, got %v", cause, err)
+	}
+}
+
+func TestTrigger_PropagatesIDsToActions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:      "proceed",
+						Target:     "end",
+						Conditions: []string{"checkIDs"},
+						Actions:    []string{"captureIDs"},
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	var conditionTransitionID, actionTransitionID, actionWorkflowID string
+	registry := NewRegistry()
+	registry.RegisterCondition("checkIDs", func(ctx context.Context, data map[string]any) (bool, error) {
+		conditionTransitionID, _ = TransitionIDFromContext(ctx)
+		return true, nil
+	})
+	registry.RegisterAction("captureIDs", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		actionTransitionID, _ = TransitionIDFromContext(ctx)
+		actionWorkflowID, _ = WorkflowIDFromContext(ctx)
+		return nil, nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	ctx := WithWorkflowID(context.Background(), "order-42")
+	if _, err := fsm.Trigger(ctx, "start", "proceed", map[string]any{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if actionWorkflowID != "order-42" {
+		t.Errorf("Expected workflow ID 'order-42' in action, got '%s'", actionWorkflowID)
+	}
+	if actionTransitionID == "" {
+		t.Error("Expected a transition ID in action context")
+	}
+	if conditionTransitionID != actionTransitionID {
+		t.Errorf("Expected condition and action to share transition ID, got '%s' and '%s'", conditionTransitionID, actionTransitionID)
+	}
+
+	// Each Trigger call gets its own transition ID
+	previous := actionTransitionID
+	if _, err := fsm.Trigger(ctx, "start", "proceed", map[string]any{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if actionTransitionID == previous {
+		t.Error("Expected a new transition ID for each Trigger call")
+	}
+}
+
+func TestFromStateFromContext(t *testing.T) {
+	if _, ok := FromStateFromContext(context.Background()); ok {
+		t.Error("Expected no source state outside a transition")
+	}
+
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:   "proceed",
+						Target:  "end",
+						Actions: []string{"captureSource"},
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	var sourceState string
+	registry := NewRegistry()
+	registry.RegisterAction("captureSource", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		sourceState, _ = FromStateFromContext(ctx)
+		return nil, nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	if _, err := fsm.Trigger(context.Background(), "start", "proceed", map[string]any{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if sourceState != "start" {
+		t.Errorf("Expected source state 'start', got '%s'", sourceState)
+	}
+}
+
+func TestEventFromContext(t *testing.T) {
+	if _, ok := EventFromContext(context.Background()); ok {
+		t.Error("Expected no event outside a transition")
+	}
+
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end"},
+					{Event: "skip", Target: "end"},
+				},
+			},
+			"end": {
+				Name:    "end",
+				OnEnter: []string{"captureEntry"},
+			},
+		},
+	}
+
+	var event, fromState string
+	registry := NewRegistry()
+	registry.RegisterAction("captureEntry", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		event, _ = EventFromContext(ctx)
+		fromState, _ = FromStateFromContext(ctx)
+		return nil, nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	for _, trigger := range []string{"proceed", "skip"} {
+		if _, err := fsm.Trigger(context.Background(), "start", trigger, map[string]any{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if event != trigger {
+			t.Errorf("Expected OnEnter to see event '%s', got '%s'", trigger, event)
+		}
+		if fromState != "start" {
+			t.Errorf("Expected OnEnter to see from-state 'start', got '%s'", fromState)
+		}
+	}
+}
+
+func TestTransitionDepthFromContext(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:        "start",
+				Transitions: []Transition{{Event: "recurse", Target: "start", Internal: true, Actions: []string{"recurse"}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		maxDepth      int
+		stopAt        int
+		expectedDepth []int
+		expectedError string
+	}{
+		{name: "NestedTriggerIncreasesDepth", stopAt: 3, expectedDepth: []int{1, 2, 3}},
+		{
+			name:          "MaxDepthRejectsRecursion",
+			maxDepth:      2,
+			stopAt:        10,
+			expectedDepth: []int{1, 2},
+			expectedError: "transition depth 3 exceeds the limit of 2: start:recurse > start:recurse > start:recurse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if depth := TransitionDepthFromContext(context.Background()); depth != 0 {
+				t.Errorf("Expected depth 0 outside a transition, got %d", depth)
+			}
+
+			var depths []int
+			var paths [][]string
+			var fsm *StateMachine
+			registry := NewRegistry()
+			registry.RegisterAction("recurse", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+				depths = append(depths, TransitionDepthFromContext(ctx))
+				paths = append(paths, TransitionPathFromContext(ctx))
+				if len(depths) == tt.stopAt {
+					return nil, nil
+				}
+				_, err := fsm.Trigger(ctx, "start", "recurse", data)
+				return nil, err
+			})
+			fsm = NewStateMachine(definition, registry, nil, WithMaxTransitionDepth(tt.maxDepth))
+
+			_, err := fsm.Trigger(context.Background(), "start", "recurse", map[string]any{})
+			if tt.expectedError == "" && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tt.expectedError != "" && (err == nil || !strings.HasSuffix(err.Error(), tt.expectedError)) {
+				t.Fatalf("Expected error ending in %q, got %v", tt.expectedError, err)
+			}
+
+			if !slices.Equal(depths, tt.expectedDepth) {
+				t.Errorf("Expected depths %v, got %v", tt.expectedDepth, depths)
+			}
+			if expected := []string{"start:recurse", "start:recurse"}; !slices.Equal(paths[1], expected) {
+				t.Errorf("Expected path %v, got %v", expected, paths[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package machina
+
+import (
+	"fmt"
+	"slices"
+)
+
+// DeferredEventsKey is the persistence data key holding the events deferred by states
+// listing them in DeferredEvents, as a []string in the order they were triggered
+const DeferredEventsKey = "__deferred"
+
+// DeferredEvents returns the events queued under DeferredEventsKey in data, oldest first
+func DeferredEvents(data map[string]any) []string {
+	events, _ := data[DeferredEventsKey].([]string)
+	return events
+}
+
+// validateDeferredEvents checks that the state only defers events it has no transition for,
+// as a transition always takes precedence over deferring
+func (s *State) validateDeferredEvents() error {
+	for _, event := range s.DeferredEvents {
+		if event == "" {
+			return fmt.Errorf("deferred events must not be empty")
+		}
+		if s.acceptsEvent(event) {
+			return fmt.Errorf("deferred event %s has a matching transition", event)
+		}
+	}
+	return nil
+}
+
+// deferEvent appends event to the queue in data. The queue is copied so that the caller's
+// payload is never modified.
+func deferEvent(data map[string]any, event string) {
+	events := slices.Clone(DeferredEvents(data))
+	data[DeferredEventsKey] = append(events, event)
+}
+
+// takeDeferredEvent removes and returns the oldest deferred event in data that state has a
+// transition for, or returns "" if there is none. Events the state cannot handle stay queued.
+func (sm *StateMachine) takeDeferredEvent(data map[string]any, state *State) string {
+	events := DeferredEvents(data)
+	for i, event := range events {
+		if len(state.matchingTransitions(event, sm.eventPatterns)) == 0 {
+			continue
+		}
+		remaining := slices.Delete(slices.Clone(events), i, i+1)
+		if len(remaining) == 0 {
+			delete(data, DeferredEventsKey)
+		} else {
+			data[DeferredEventsKey] = remaining
+		}
+		return event
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package machina
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// deferredDefinition returns an order workflow whose "paying" state defers "ship" and
+// "notify" until the order is paid
+func deferredDefinition() *WorkflowDefinition {
+	return &WorkflowDefinition{
+		States: map[string]State{
+			"paying": {
+				Name:           "paying",
+				DeferredEvents: []string{"ship", "notify"},
+				Transitions:    []Transition{{Event: "paid", Target: "paid"}},
+			},
+			"paid": {
+				Name: "paid",
+				Transitions: []Transition{
+					{Event: "ship", Target: "shipped"},
+					{Event: "notify", Target: "paid", Internal: true},
+				},
+			},
+			"shipped": {Name: "shipped", IsFinal: true},
+		},
+	}
+}
+
+func TestStateMachine_Trigger_DeferredEvents(t *testing.T) {
+	fsm := NewStateMachine(deferredDefinition(), NewRegistry(), nil)
+	ctx := context.Background()
+
+	payload := map[string]any{"orderID": "42"}
+	result, err := fsm.Trigger(ctx, "paying", "notify", payload)
+	if err != nil {
+		t.Fatalf("Expected the event to be deferred, got %v", err)
+	}
+	if result.NewState != "paying" {
+		t.Errorf("Expected to stay in paying, got %s", result.NewState)
+	}
+	if _, ok := payload[DeferredEventsKey]; ok {
+		t.Error("Expected the caller's payload not to be modified")
+	}
+
+	result, err = fsm.Trigger(ctx, "paying", "ship", result.PersistenceData)
+	if err != nil {
+		t.Fatalf("Expected the event to be deferred, got %v", err)
+	}
+	if expected := []string{"notify", "ship"}; !slices.Equal(DeferredEvents(result.PersistenceData), expected) {
+		t.Errorf("Expected deferred events %v, got %v", expected, DeferredEvents(result.PersistenceData))
+	}
+
+	// Entering paid surfaces the oldest deferred event as the auto event
+	result, err = fsm.Trigger(ctx, "paying", "paid", result.PersistenceData)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "paid" || result.AutoEvent != "notify" {
+		t.Errorf("Expected paid with auto event notify, got %s with %q", result.NewState, result.AutoEvent)
+	}
+	if expected := []string{"ship"}; !slices.Equal(DeferredEvents(result.PersistenceData), expected) {
+		t.Errorf("Expected deferred events %v, got %v", expected, DeferredEvents(result.PersistenceData))
+	}
+	if result.PersistenceData["orderID"] != "42" {
+		t.Errorf("Expected business data to be kept, got %v", result.PersistenceData)
+	}
+}
+
+func TestStateMachine_Resume_DeferredEvents(t *testing.T) {
+	fsm := NewStateMachine(deferredDefinition(), NewRegistry(), nil)
+
+	result, err := fsm.Trigger(context.Background(), "paying", "ship", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected the event to be deferred, got %v", err)
+	}
+
+	encoded, err := json.Marshal(SnapshotFrom(result.NewState, result.PersistenceData))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var snapshot MachineSnapshot
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	result, err = fsm.Resume(context.Background(), snapshot, "paid")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.AutoEvent != "ship" {
+		t.Errorf("Expected the restored deferred event as auto event, got %q", result.AutoEvent)
+	}
+}
+
+func TestStateMachine_Trigger_DeferredEventsChained(t *testing.T) {
+	fsm := NewStateMachine(deferredDefinition(), NewRegistry(), nil, WithAutoEventChaining(5))
+	ctx := context.Background()
+
+	result, err := fsm.Trigger(ctx, "paying", "ship", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected the event to be deferred, got %v", err)
+	}
+
+	result, err = fsm.Trigger(ctx, "paying", "paid", result.PersistenceData)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "shipped" {
+		t.Errorf("Expected the deferred ship event to be consumed on entering paid, got state %s", result.NewState)
+	}
+	if _, ok := result.PersistenceData[DeferredEventsKey]; ok {
+		t.Errorf("Expected the queue to be emptied, got %v", result.PersistenceData[DeferredEventsKey])
+	}
+}
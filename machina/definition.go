@@ -1,25 +1,189 @@
 package machina
 
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 // State represents a state in the state machine configuration
 type State struct {
-	IsSideQuest bool         `yaml:"isSideQuest" json:"isSideQuest"` // New field
+	IsSideQuest bool         `yaml:"isSideQuest,omitempty" json:"isSideQuest,omitempty"` // Temporary diversion that may return to the previous state
+	IsFinal     bool         `yaml:"isFinal,omitempty" json:"isFinal,omitempty"`         // Terminal state with no outgoing transitions
 	Name        string       `yaml:"name" json:"name"`
 	OnEnter     []string     `yaml:"onEnter,omitempty" json:"onEnter,omitempty"`
 	OnLeave     []string     `yaml:"onLeave,omitempty" json:"onLeave,omitempty"`
+	OnReenter   []string     `yaml:"onReenter,omitempty" json:"onReenter,omitempty"` // Restore the state when entering the target fails; defaults to OnEnter
 	Transitions []Transition `yaml:"transitions,omitempty" json:"transitions,omitempty"`
+	// Timeout is how long an instance may stay in the state before TimeoutEvent should be
+	// triggered. StateMachine.WatchTimeout reports when it elapses.
+	Timeout      time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	TimeoutEvent string        `yaml:"timeoutEvent,omitempty" json:"timeoutEvent,omitempty"`
+	// MaxDwell is the longest an instance should stay parked in the state. Unlike Timeout it
+	// isn't watched; StateMachine.CheckDwell reports TimeoutEvent once it is exceeded.
+	MaxDwell time.Duration `yaml:"maxDwell,omitempty" json:"maxDwell,omitempty"`
+	// DeferredEvents are events the state has no transition for that are queued under
+	// DeferredEventsKey instead of failing. Entering a state that handles one fires it as
+	// the transition's AutoEvent, oldest first.
+	DeferredEvents []string `yaml:"deferredEvents,omitempty" json:"deferredEvents,omitempty"`
+	// SubWorkflow, if set, runs a child workflow to completion each time the state is entered
+	SubWorkflow *SubWorkflow `yaml:"subWorkflow,omitempty" json:"subWorkflow,omitempty"`
+	// Description and Metadata document the state for tooling and are ignored by execution
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Metadata    map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
 }
 
 // Transition represents a transition definition in the configuration
 type Transition struct {
-	Event      string   `yaml:"event" json:"event"`
-	Target     string   `yaml:"target" json:"target"`
-	Conditions []string `yaml:"conditions,omitempty" json:"conditions,omitempty"`
-	Actions    []string `yaml:"actions,omitempty" json:"actions,omitempty"`
-	AutoEvent  string   `yaml:"autoEvent,omitempty" json:"autoEvent,omitempty"` // Event to automatically fire after transition
+	Event string `yaml:"event" json:"event"`
+	// Match selects how Event is compared with a triggered event: exactly (the default), as
+	// a prefix, or as a regular expression. See MatchMode.
+	Match      MatchMode `yaml:"match,omitempty" json:"match,omitempty"`
+	Target     string    `yaml:"target" json:"target"`
+	Conditions []string  `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+	// Preconditions are enforced once Conditions have selected the transition. Unlike a
+	// condition, a precondition that evaluates to false fails the event with an
+	// *ErrPreconditionFailed instead of letting another transition apply.
+	Preconditions []string `yaml:"preconditions,omitempty" json:"preconditions,omitempty"`
+	// RequiredData lists payload keys that must be present. They are checked once the
+	// transition is selected and before its conditions are enforced, but when several
+	// transitions share the event, the conditions that select among them run first and
+	// may see a payload without these keys.
+	RequiredData []string `yaml:"requiredData,omitempty" json:"requiredData,omitempty"`
+	// Validators names payload validators that check the payload after RequiredData
+	Validators []string `yaml:"validators,omitempty" json:"validators,omitempty"`
+	// ConditionArgs holds static arguments for conditions, keyed by condition name
+	ConditionArgs map[string]map[string]any `yaml:"conditionArgs,omitempty" json:"conditionArgs,omitempty"`
+	Actions       []string                  `yaml:"actions,omitempty" json:"actions,omitempty"`
+	// Parallel lists groups of actions that run concurrently once Actions have succeeded.
+	// Each group runs its actions in order, and all groups must finish before the target is
+	// entered. The first failure cancels the other groups and fails the transition.
+	Parallel [][]string `yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	// OnError actions run to compensate when one of the transition's actions fails or
+	// aborts the transition with ErrAbortTransition
+	OnError   []string `yaml:"onError,omitempty" json:"onError,omitempty"`
+	AutoEvent string   `yaml:"autoEvent,omitempty" json:"autoEvent,omitempty"` // Event to automatically fire after transition
+	// AutoEventConditions are evaluated against the data the transition produced. AutoEvent
+	// is only reported if they all pass. Their arguments come from ConditionArgs.
+	AutoEventConditions []string `yaml:"autoEventConditions,omitempty" json:"autoEventConditions,omitempty"`
+	// Roles lists the roles allowed to fire the transition. The engine doesn't interpret them;
+	// they are passed to the authorizer configured with WithAuthorizer.
+	Roles []string `yaml:"roles,omitempty" json:"roles,omitempty"`
+	// Webhooks are URLs that receive the resulting persistence data as a JSON POST once the
+	// transition has succeeded. See WithHTTPClient and WithWebhookFailurePolicy.
+	Webhooks []string `yaml:"webhooks,omitempty" json:"webhooks,omitempty"`
+	// RateLimit, if set, caps how often the transition may fire for the same workflow, as
+	// identified by WithWorkflowID. Exceeding it fails the event with an *ErrRateLimited.
+	RateLimit *RateLimit `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+	// Internal transitions stay in their own state and only run Actions, skipping the state's
+	// OnLeave and OnEnter actions. Other transitions to the same state leave and re-enter it.
+	Internal bool `yaml:"internal,omitempty" json:"internal,omitempty"`
+	// Weight makes the machine pick randomly among the same-event transitions whose conditions
+	// pass, in proportion to their weights. Unweighted transitions only apply if no weighted one does.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+	// Description and Metadata document the transition for tooling and are ignored by execution.
+	// Exporters use Description as the edge label when it is set.
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Metadata    map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
 }
 
 // WorkflowDefinition represents the entire workflow configuration
 type WorkflowDefinition struct {
-	InitialState string           `yaml:"initialState,omitempty" json:"initialState,omitempty"`
-	States       map[string]State `yaml:"states" json:"states"`
+	// Version identifies the schema the definition was written for. The loader can check it
+	// against LoadOptions.SupportedVersions and migrate older definitions.
+	Version      string `yaml:"version,omitempty" json:"version,omitempty"`
+	InitialState string `yaml:"initialState,omitempty" json:"initialState,omitempty"`
+	// ErrorState, if set, is the state Trigger moves to when a condition or action fails,
+	// instead of returning the error. The error is recorded under ErrorKey.
+	ErrorState string           `yaml:"errorState,omitempty" json:"errorState,omitempty"`
+	States     map[string]State `yaml:"states" json:"states"`
+}
+
+// conditionRef is a single entry of a transition's conditions list in YAML.
+// It is either a plain condition name or a mapping with a name and static arguments.
+type conditionRef struct {
+	Name string         `yaml:"name"`
+	Args map[string]any `yaml:"args,omitempty"`
+}
+
+// UnmarshalYAML decodes a condition reference from a scalar name or a {name, args} mapping
+func (c *conditionRef) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&c.Name)
+	}
+
+	type plain conditionRef
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Name == "" {
+		return fmt.Errorf("line %d: condition must have a name", value.Line)
+	}
+
+	return nil
+}
+
+// UnmarshalYAML decodes a transition, accepting conditions and preconditions written either
+// as plain names or as {name, args} mappings. Arguments are collected into ConditionArgs.
+func (t *Transition) UnmarshalYAML(value *yaml.Node) error {
+	type plain Transition
+
+	var conditionsNode, preconditionsNode *yaml.Node
+	node := *value
+	if value.Kind == yaml.MappingNode {
+		node.Content = nil
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			switch value.Content[i].Value {
+			case "conditions":
+				conditionsNode = value.Content[i+1]
+			case "preconditions":
+				preconditionsNode = value.Content[i+1]
+			default:
+				node.Content = append(node.Content, value.Content[i], value.Content[i+1])
+			}
+		}
+	}
+
+	if err := node.Decode((*plain)(t)); err != nil {
+		return err
+	}
+
+	var err error
+	if t.Conditions, err = t.decodeConditionRefs(conditionsNode); err != nil {
+		return err
+	}
+	t.Preconditions, err = t.decodeConditionRefs(preconditionsNode)
+	return err
+}
+
+// decodeConditionRefs decodes a list of condition references into their names, collecting
+// their arguments into ConditionArgs. A nil node decodes to nil.
+func (t *Transition) decodeConditionRefs(node *yaml.Node) ([]string, error) {
+	if node == nil {
+		return nil, nil
+	}
+
+	var refs []conditionRef
+	if err := node.Decode(&refs); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+		if ref.Args == nil {
+			continue
+		}
+		if t.ConditionArgs == nil {
+			t.ConditionArgs = make(map[string]map[string]any)
+		}
+		if _, exists := t.ConditionArgs[ref.Name]; exists {
+			return nil, fmt.Errorf("line %d: arguments for condition %s declared more than once", node.Line, ref.Name)
+		}
+		t.ConditionArgs[ref.Name] = ref.Args
+	}
+
+	return names, nil
 }
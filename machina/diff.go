@@ -0,0 +1,161 @@
+package machina
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// TransitionKey identifies a transition within a state by its event and target
+type TransitionKey struct {
+	Event  string
+	Target string
+}
+
+// StateDiff describes how a state present in both workflows changed
+type StateDiff struct {
+	Name string
+	// ChangedFields lists the state-level fields that differ, such as "onEnter" or "isFinal"
+	ChangedFields      []string
+	AddedTransitions   []TransitionKey
+	RemovedTransitions []TransitionKey
+	// ChangedTransitions have the same event and target but differ in conditions, actions or other fields
+	ChangedTransitions []TransitionKey
+}
+
+// WorkflowDiff describes the differences between two workflow definitions
+type WorkflowDiff struct {
+	VersionChanged      bool
+	InitialStateChanged bool
+	ErrorStateChanged   bool
+	AddedStates         []string
+	RemovedStates       []string
+	// ModifiedStates only lists states that changed, sorted by name
+	ModifiedStates []StateDiff
+}
+
+// IsEmpty reports whether the two workflows are equivalent
+func (d WorkflowDiff) IsEmpty() bool {
+	return !d.VersionChanged && !d.InitialStateChanged && !d.ErrorStateChanged && len(d.AddedStates) == 0 && len(d.RemovedStates) == 0 && len(d.ModifiedStates) == 0
+}
+
+// DiffWorkflow compares workflow a with workflow b, reporting what b adds, removes or changes.
+// Transitions are matched by event and target; if a state declares several transitions with
+// the same event and target, they are matched in declaration order.
+func DiffWorkflow(a, b *WorkflowDefinition) WorkflowDiff {
+	diff := WorkflowDiff{
+		VersionChanged:      a.Version != b.Version,
+		InitialStateChanged: a.InitialState != b.InitialState,
+		ErrorStateChanged:   a.ErrorState != b.ErrorState,
+	}
+
+	for _, name := range b.sortedStateNames() {
+		if _, exists := a.States[name]; !exists {
+			diff.AddedStates = append(diff.AddedStates, name)
+		}
+	}
+
+	for _, name := range a.sortedStateNames() {
+		newState, exists := b.States[name]
+		if !exists {
+			diff.RemovedStates = append(diff.RemovedStates, name)
+			continue
+		}
+
+		if stateDiff := diffState(a.States[name], newState); stateDiff != nil {
+			diff.ModifiedStates = append(diff.ModifiedStates, *stateDiff)
+		}
+	}
+
+	return diff
+}
+
+// diffState compares two versions of a state, returning nil if they are equivalent
+func diffState(a, b State) *StateDiff {
+	diff := &StateDiff{Name: a.Name}
+
+	if a.IsSideQuest != b.IsSideQuest {
+		diff.ChangedFields = append(diff.ChangedFields, "isSideQuest")
+	}
+	if a.IsFinal != b.IsFinal {
+		diff.ChangedFields = append(diff.ChangedFields, "isFinal")
+	}
+	if !slices.Equal(a.OnEnter, b.OnEnter) {
+		diff.ChangedFields = append(diff.ChangedFields, "onEnter")
+	}
+	if !slices.Equal(a.OnLeave, b.OnLeave) {
+		diff.ChangedFields = append(diff.ChangedFields, "onLeave")
+	}
+	if !slices.Equal(a.OnReenter, b.OnReenter) {
+		diff.ChangedFields = append(diff.ChangedFields, "onReenter")
+	}
+	if a.Timeout != b.Timeout || a.TimeoutEvent != b.TimeoutEvent {
+		diff.ChangedFields = append(diff.ChangedFields, "timeout")
+	}
+	if a.MaxDwell != b.MaxDwell {
+		diff.ChangedFields = append(diff.ChangedFields, "maxDwell")
+	}
+	if !slices.Equal(a.DeferredEvents, b.DeferredEvents) {
+		diff.ChangedFields = append(diff.ChangedFields, "deferredEvents")
+	}
+	if !reflect.DeepEqual(a.SubWorkflow, b.SubWorkflow) {
+		diff.ChangedFields = append(diff.ChangedFields, "subWorkflow")
+	}
+	if a.Description != b.Description {
+		diff.ChangedFields = append(diff.ChangedFields, "description")
+	}
+	if !maps.Equal(a.Metadata, b.Metadata) {
+		diff.ChangedFields = append(diff.ChangedFields, "metadata")
+	}
+
+	oldTransitions := groupTransitions(a.Transitions)
+	newTransitions := groupTransitions(b.Transitions)
+
+	for key, olds := range oldTransitions {
+		news := newTransitions[key]
+		for i, old := range olds {
+			if i >= len(news) {
+				diff.RemovedTransitions = append(diff.RemovedTransitions, key)
+			} else if !reflect.DeepEqual(old, news[i]) {
+				diff.ChangedTransitions = append(diff.ChangedTransitions, key)
+			}
+		}
+	}
+
+	for key, news := range newTransitions {
+		for i := len(oldTransitions[key]); i < len(news); i++ {
+			diff.AddedTransitions = append(diff.AddedTransitions, key)
+		}
+	}
+
+	if len(diff.ChangedFields) == 0 && len(diff.AddedTransitions) == 0 && len(diff.RemovedTransitions) == 0 && len(diff.ChangedTransitions) == 0 {
+		return nil
+	}
+
+	sortTransitionKeys(diff.AddedTransitions)
+	sortTransitionKeys(diff.RemovedTransitions)
+	sortTransitionKeys(diff.ChangedTransitions)
+
+	return diff
+}
+
+// groupTransitions groups transitions by event and target, preserving declaration order
+func groupTransitions(transitions []Transition) map[TransitionKey][]Transition {
+	groups := make(map[TransitionKey][]Transition)
+	for _, transition := range transitions {
+		key := TransitionKey{Event: transition.Event, Target: transition.Target}
+		groups[key] = append(groups[key], transition)
+	}
+	return groups
+}
+
+// sortTransitionKeys sorts keys by event, then target
+func sortTransitionKeys(keys []TransitionKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Event != keys[j].Event {
+			return keys[i].Event < keys[j].Event
+		}
+		return keys[i].Target < keys[j].Target
+	})
+}
@@ -0,0 +1,196 @@
+package machina
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffWorkflow(t *testing.T) {
+	before := &WorkflowDefinition{
+		InitialState: "start",
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:      "proceed",
+						Target:     "review",
+						Conditions: []string{"isValid"},
+					},
+					{
+						Event:  "cancel",
+						Target: "cancelled",
+					},
+				},
+			},
+			"review": {
+				Name:    "review",
+				OnEnter: []string{"notify"},
+				Transitions: []Transition{
+					{
+						Event:  "approve",
+						Target: "done",
+					},
+				},
+			},
+			"cancelled": {
+				Name: "cancelled",
+			},
+			"done": {
+				Name: "done",
+			},
+		},
+	}
+
+	after := &WorkflowDefinition{
+		InitialState: "start",
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:      "proceed",
+						Target:     "review",
+						Conditions: []string{"isValid", "isFunded"},
+					},
+					{
+						Event:  "escalate",
+						Target: "manual",
+					},
+				},
+			},
+			"review": {
+				Name:    "review",
+				OnEnter: []string{"notify"},
+				Transitions: []Transition{
+					{
+						Event:  "approve",
+						Target: "done",
+					},
+				},
+			},
+			"manual": {
+				Name: "manual",
+			},
+			"done": {
+				Name:    "done",
+				IsFinal: true,
+			},
+		},
+	}
+
+	expected := WorkflowDiff{
+		AddedStates:   []string{"manual"},
+		RemovedStates: []string{"cancelled"},
+		ModifiedStates: []StateDiff{
+			{
+				Name:          "done",
+				ChangedFields: []string{"isFinal"},
+			},
+			{
+				Name:               "start",
+				AddedTransitions:   []TransitionKey{{Event: "escalate", Target: "manual"}},
+				RemovedTransitions: []TransitionKey{{Event: "cancel", Target: "cancelled"}},
+				ChangedTransitions: []TransitionKey{{Event: "proceed", Target: "review"}},
+			},
+		},
+	}
+
+	diff := DiffWorkflow(before, after)
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected diff %+v, got %+v", expected, diff)
+	}
+
+	if diff.IsEmpty() {
+		t.Error("Expected non-empty diff")
+	}
+}
+
+func TestDiffWorkflow_Identical(t *testing.T) {
+	definition := &WorkflowDefinition{
+		InitialState: "start",
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "proceed",
+						Target: "end",
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	if diff := DiffWorkflow(definition, definition); !diff.IsEmpty() {
+		t.Errorf("Expected empty diff, got %+v", diff)
+	}
+}
+
+func TestDiffWorkflow_TimeoutFields(t *testing.T) {
+	waiting := State{Name: "waiting", Timeout: time.Hour, MaxDwell: time.Hour, TimeoutEvent: "expire"}
+
+	tests := []struct {
+		name     string
+		change   func(state *State)
+		expected []string
+	}{
+		{name: "Timeout", change: func(state *State) { state.Timeout = 2 * time.Hour }, expected: []string{"timeout"}},
+		{name: "TimeoutEvent", change: func(state *State) { state.TimeoutEvent = "escalate" }, expected: []string{"timeout"}},
+		{name: "MaxDwell", change: func(state *State) { state.MaxDwell = 2 * time.Hour }, expected: []string{"maxDwell"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := waiting
+			tt.change(&changed)
+
+			diff := DiffWorkflow(
+				&WorkflowDefinition{States: map[string]State{"waiting": waiting}},
+				&WorkflowDefinition{States: map[string]State{"waiting": changed}},
+			)
+			if len(diff.ModifiedStates) != 1 || !reflect.DeepEqual(diff.ModifiedStates[0].ChangedFields, tt.expected) {
+				t.Errorf("Expected changed fields %v, got %+v", tt.expected, diff.ModifiedStates)
+			}
+		})
+	}
+}
+
+func TestDiffWorkflow_DuplicateTransitions(t *testing.T) {
+	before := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "pay", Target: "end", Conditions: []string{"small"}},
+					{Event: "pay", Target: "end", Conditions: []string{"large"}},
+				},
+			},
+		},
+	}
+
+	after := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "pay", Target: "end", Conditions: []string{"small"}},
+				},
+			},
+		},
+	}
+
+	diff := DiffWorkflow(before, after)
+	if len(diff.ModifiedStates) != 1 {
+		t.Fatalf("Expected 1 modified state, got %+v", diff.ModifiedStates)
+	}
+
+	stateDiff := diff.ModifiedStates[0]
+	if len(stateDiff.RemovedTransitions) != 1 || len(stateDiff.ChangedTransitions) != 0 {
+		t.Errorf("Expected the second duplicate to be reported as removed, got %+v", stateDiff)
+	}
+}
@@ -0,0 +1,147 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrStateNotFound is matched by errors.Is when Trigger is called with a state the
+// workflow does not define
+var ErrStateNotFound = errors.New("state not found")
+
+// ErrTransitionNotFound is matched by errors.Is when no transition applies to the event
+// in the current state, either because none is declared or because none of their
+// conditions pass
+var ErrTransitionNotFound = errors.New("transition not found")
+
+// ErrUnknownEvent is returned by Trigger when the state declares no transition for the
+// event at all, which usually means the event name is misspelled. It matches
+// ErrTransitionNotFound through errors.Is.
+type ErrUnknownEvent struct {
+	State string
+	Event string
+	// Valid lists the events that can currently be triggered in the state with the
+	// payload, in declaration order, as AvailableEvents reports them. If they cannot be
+	// evaluated, it lists every event the state declares a transition for instead.
+	// Prefix, regex and wildcard patterns are left out.
+	Valid []string
+}
+
+// Error implements the error interface
+func (e *ErrUnknownEvent) Error() string {
+	msg := fmt.Sprintf("no transition for '%s' in state %s", e.Event, e.State)
+	if len(e.Valid) > 0 {
+		msg += fmt.Sprintf(" (did you mean: %s?)", strings.Join(e.Valid, ", "))
+	}
+	return msg
+}
+
+// errGuardRejected marks errors caused by conditions evaluating to false
+var errGuardRejected = errors.New("guard rejected")
+
+// ErrEnterRolledBack is returned by Trigger when an OnEnter action of the target state
+// failed, or a webhook failed under WebhookFailTransition. The workflow must remain in
+// State: Trigger restores it by running its OnReenter actions (or its OnEnter actions if
+// it declares none) and returns, alongside this error, a TransitionResult whose
+// PersistenceData is safe to trigger from State again.
+type ErrEnterRolledBack struct {
+	State  string
+	Target string
+	// Err is the OnEnter or webhook failure
+	Err error
+	// RestoreErr is set if restoring the original state failed as well
+	RestoreErr error
+}
+
+// Error implements the error interface
+func (e *ErrEnterRolledBack) Error() string {
+	msg := fmt.Sprintf("entering state %s failed, remaining in state %s: %v", e.Target, e.State, e.Err)
+	if e.RestoreErr != nil {
+		msg += fmt.Sprintf("; restoring state %s also failed: %v", e.State, e.RestoreErr)
+	}
+	return msg
+}
+
+// Unwrap returns the OnEnter failure and the restore failure, if any
+func (e *ErrEnterRolledBack) Unwrap() []error {
+	if e.RestoreErr != nil {
+		return []error{e.Err, e.RestoreErr}
+	}
+	return []error{e.Err}
+}
+
+// ErrPreconditionFailed is returned by Trigger when a precondition of the selected
+// transition evaluated to false. Unlike a guard, it is a hard failure: the transition
+// applies but may not proceed. The workflow remains in State.
+type ErrPreconditionFailed struct {
+	State        string
+	Event        string
+	Precondition string
+}
+
+// Error implements the error interface
+func (e *ErrPreconditionFailed) Error() string {
+	return fmt.Sprintf("precondition %s of transition from %s on event %s failed", e.Precondition, e.State, e.Event)
+}
+
+// ConditionFailures is returned by Trigger with WithCollectAllConditionFailures when one
+// or more conditions of the transition failed. Errs holds one error per failed condition,
+// in the order the conditions are listed. Guards that evaluated to false match
+// ErrTransitionNotFound through errors.Is.
+type ConditionFailures struct {
+	Errs []error
+}
+
+// Error implements the error interface
+func (e *ConditionFailures) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("conditions failed: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the failure of each condition
+func (e *ConditionFailures) Unwrap() []error {
+	return e.Errs
+}
+
+// kindError tags an error with sentinels for errors.Is without changing its message
+type kindError struct {
+	err   error
+	kinds []error
+}
+
+// Error implements the error interface
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap exposes both the underlying error and the sentinels
+func (e *kindError) Unwrap() []error {
+	return append([]error{e.err}, e.kinds...)
+}
+
+// withKind tags err with the given sentinels
+func withKind(err error, kinds ...error) error {
+	return &kindError{err: err, kinds: kinds}
+}
+
+// suggestEvents narrows the Valid events of an *ErrUnknownEvent in err to those that can be
+// triggered with the payload, as reported by AvailableEvents. The declared events are kept
+// if they cannot be evaluated. It is called by Trigger rather than getTransitionForEvent,
+// which AvailableEvents itself relies on.
+func (sm *StateMachine) suggestEvents(ctx context.Context, currentState string, payload map[string]any, err error) {
+	var unknown *ErrUnknownEvent
+	if !errors.As(err, &unknown) {
+		return
+	}
+
+	available, availableErr := sm.AvailableEvents(ctx, currentState, payload)
+	if availableErr != nil {
+		return
+	}
+	unknown.Valid = available
+}
@@ -0,0 +1,122 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestStateMachine_Trigger_SentinelErrors(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "guarded", Target: "end", Conditions: []string{"isFalse"}},
+					{Event: "broken", Target: "end", Actions: []string{"errorAction"}},
+				},
+			},
+			"end": {Name: "end"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isFalse", MockFalseCondition)
+	registry.RegisterAction("errorAction", MockErrorAction)
+	fsm := NewStateMachine(definition, registry, nil)
+
+	tests := []struct {
+		name     string
+		state    string
+		event    string
+		expected error
+	}{
+		{name: "UnknownState", state: "missing", event: "guarded", expected: ErrStateNotFound},
+		{name: "UnknownEvent", state: "start", event: "missing", expected: ErrTransitionNotFound},
+		{name: "ConditionFalse", state: "start", event: "guarded", expected: ErrTransitionNotFound},
+		{name: "ActionError", state: "start", event: "broken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fsm.Trigger(context.Background(), tt.state, tt.event, map[string]any{})
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+
+			for _, sentinel := range []error{ErrStateNotFound, ErrTransitionNotFound} {
+				if got := errors.Is(err, sentinel); got != (sentinel == tt.expected) {
+					t.Errorf("Expected errors.Is(err, %v) to be %v for %q", sentinel, !got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_UnknownEventSuggestions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end", Conditions: []string{"isFalse"}},
+					{Event: "cancel", Target: "end"},
+					{Event: "proceed", Target: "end"},
+					{Event: "webhook.", Match: MatchPrefix, Target: "end"},
+					// Events whose conditions currently fail are not suggested
+					{Event: "ship", Target: "end", Conditions: []string{"isFalse"}},
+				},
+			},
+			"end": {Name: "end"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isFalse", MockFalseCondition)
+	fsm := NewStateMachine(definition, registry, nil)
+
+	_, err := fsm.Trigger(context.Background(), "start", "procede", map[string]any{})
+
+	var unknown *ErrUnknownEvent
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Expected *ErrUnknownEvent, got %v", err)
+	}
+	if !errors.Is(err, ErrTransitionNotFound) {
+		t.Errorf("Expected error to match ErrTransitionNotFound, got %v", err)
+	}
+	if expected := []string{"proceed", "cancel"}; !slices.Equal(unknown.Valid, expected) {
+		t.Errorf("Expected suggestions %v, got %v", expected, unknown.Valid)
+	}
+	if expected := "no transition for 'procede' in state start (did you mean: proceed, cancel?)"; unknown.Error() != expected {
+		t.Errorf("Expected error '%s', got '%s'", expected, unknown.Error())
+	}
+}
+
+func TestStateMachine_Trigger_UnknownEventSuggestionsFallBack(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end", Conditions: []string{"unregistered"}},
+					{Event: "cancel", Target: "end"},
+				},
+			},
+			"end": {Name: "end"},
+		},
+	}
+
+	fsm := NewStateMachine(definition, NewRegistry(), nil)
+
+	// The conditions cannot be evaluated, so every declared event is suggested
+	_, err := fsm.Trigger(context.Background(), "start", "procede", map[string]any{})
+
+	var unknown *ErrUnknownEvent
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Expected *ErrUnknownEvent, got %v", err)
+	}
+	if expected := []string{"proceed", "cancel"}; !slices.Equal(unknown.Valid, expected) {
+		t.Errorf("Expected suggestions %v, got %v", expected, unknown.Valid)
+	}
+}
@@ -0,0 +1,65 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// ErrorKey is the persistence data key holding the message of the failure that moved the
+// workflow to the definition's ErrorState
+const ErrorKey = "__error"
+
+// errStepFailed marks errors caused by a condition or action failing, which route the
+// workflow to the definition's ErrorState if it has one
+var errStepFailed = errors.New("step failed")
+
+// triggerWithErrorState runs trigger and, if a condition or action failed, moves the
+// workflow to the definition's ErrorState instead of returning the error. Guards that
+// evaluate to false, rejected payloads, vetoes, cancellations and context errors are still
+// returned as errors.
+func (sm *StateMachine) triggerWithErrorState(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error) {
+	result, err := sm.trigger(ctx, currentState, event, payload)
+	if err == nil || !errors.Is(err, errStepFailed) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return result, err
+	}
+
+	return sm.enterErrorState(ctx, currentState, event, payload, err)
+}
+
+// enterErrorState runs the OnEnter actions of the error state with the failure recorded in
+// a copy of the payload and reports the workflow as moved there
+func (sm *StateMachine) enterErrorState(ctx context.Context, currentState, event string, payload map[string]any, cause error) (*TransitionResult, error) {
+	errorState := sm.definition.ErrorState
+	logger := sm.logger.With("from", currentState, "event", event)
+	if workflowID, ok := WorkflowIDFromContext(ctx); ok {
+		logger = logger.With("workflow_id", workflowID)
+	}
+	logger.Warn("Transition failed, moving to error state", "error_state", errorState, "error", cause)
+
+	ctx = context.WithValue(ctx, sourceStateKey, currentState)
+	ctx = context.WithValue(ctx, eventKey, event)
+	ctx = context.WithValue(ctx, transitionErrorKey, cause)
+	ctx = sm.withClock(ctx)
+
+	persistenceData := maps.Clone(payload)
+	if persistenceData == nil {
+		persistenceData = make(map[string]any)
+	}
+	persistenceData[ErrorKey] = cause.Error()
+	actionData := maps.Clone(persistenceData)
+
+	state := sm.definition.States[errorState]
+	if err := sm.executeOnEnterActions(ctx, logger, currentState, event, errorState, state.OnEnter, actionData, persistenceData); err != nil {
+		return nil, errors.Join(cause, fmt.Errorf("entering error state %s failed: %w", errorState, err))
+	}
+
+	if sm.metrics != nil {
+		sm.metrics.TransitionsTotal.WithLabelValues(currentState, errorState, event).Inc()
+		sm.metrics.StatesCurrent.WithLabelValues(currentState).Dec()
+		sm.metrics.StatesCurrent.WithLabelValues(errorState).Inc()
+	}
+
+	return &TransitionResult{NewState: errorState, PersistenceData: persistenceData}, nil
+}
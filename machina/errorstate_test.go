@@ -0,0 +1,85 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// errorStateDefinition returns a workflow that moves to "failed" when a charge fails
+func errorStateDefinition() *WorkflowDefinition {
+	return &WorkflowDefinition{
+		ErrorState: "failed",
+		States: map[string]State{
+			"pending": {
+				Name: "pending",
+				Transitions: []Transition{
+					{Event: "pay", Target: "paid", Conditions: []string{"hasCard"}, Actions: []string{"charge"}},
+				},
+			},
+			"paid":   {Name: "paid"},
+			"failed": {Name: "failed", OnEnter: []string{"alertOps"}},
+		},
+	}
+}
+
+func TestStateMachine_Trigger_ErrorState(t *testing.T) {
+	var alerted any
+	registry := NewRegistry()
+	registry.RegisterCondition("hasCard", func(ctx context.Context, data map[string]any) (bool, error) {
+		return data["card"] != nil, nil
+	})
+	registry.RegisterAction("charge", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"charged": true}, errors.New("card declined")
+	})
+	registry.RegisterAction("alertOps", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		alerted = data[ErrorKey]
+		return map[string]any{"alerted": true}, nil
+	})
+
+	fsm := NewStateMachine(errorStateDefinition(), registry, nil)
+	ctx := context.Background()
+	payload := map[string]any{"card": "4242"}
+
+	// A failing action routes to the error state instead of returning the error
+	result, err := fsm.Trigger(ctx, "pending", "pay", payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "failed" {
+		t.Errorf("Expected state 'failed', got '%s'", result.NewState)
+	}
+
+	expected := "transition action charge failed: card declined"
+	if result.PersistenceData[ErrorKey] != expected {
+		t.Errorf("Expected %s to be '%s', got %v", ErrorKey, expected, result.PersistenceData[ErrorKey])
+	}
+	if alerted != expected {
+		t.Errorf("Expected the error state's OnEnter to see the error, got %v", alerted)
+	}
+	if result.PersistenceData["alerted"] != true {
+		t.Errorf("Expected OnEnter results in the data, got %v", result.PersistenceData)
+	}
+	if _, leaked := result.PersistenceData["charged"]; leaked {
+		t.Error("Expected the failed action's partial update not to leak")
+	}
+	if _, modified := payload[ErrorKey]; modified {
+		t.Error("Expected the payload to be left unmodified")
+	}
+
+	// A guard saying no is not a failure, so it is still returned
+	_, err = fsm.Trigger(ctx, "pending", "pay", map[string]any{})
+	if !errors.Is(err, ErrTransitionNotFound) {
+		t.Errorf("Expected ErrTransitionNotFound for a rejected guard, got %v", err)
+	}
+}
+
+func TestWorkflowDefinition_Validate_ErrorState(t *testing.T) {
+	definition := errorStateDefinition()
+	definition.ErrorState = "missing"
+
+	expected := "errorState missing not found in states"
+	if err := definition.Validate(); err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got %v", expected, err)
+	}
+}
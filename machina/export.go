@@ -0,0 +1,333 @@
+package machina
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// diagramIdentifier matches state names that can be used directly as Mermaid and PlantUML state IDs
+var diagramIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ToMermaid renders the workflow as a Mermaid state diagram.
+// Edges are labelled with the transition description, or the event if there is none.
+// Transitions with dynamic (empty) targets are omitted since they are resolved at runtime.
+func (wd *WorkflowDefinition) ToMermaid() string {
+	return wd.toMermaid("", nil)
+}
+
+// ToMermaidWithCurrent renders the workflow as a Mermaid state diagram with the
+// given state highlighted. It returns an error if the state does not exist.
+func (wd *WorkflowDefinition) ToMermaidWithCurrent(current string) (string, error) {
+	if _, exists := wd.States[current]; !exists {
+		return "", fmt.Errorf("state %s not found", current)
+	}
+	return wd.toMermaid(current, nil), nil
+}
+
+// ToMermaidWithCounts renders the workflow as a Mermaid state diagram whose edge labels end
+// with the number of times each transition fired, such as "pay (1204)", to show hot paths.
+// The counts are supplied by the caller, typically from gomachina_transitions_total. Edges
+// without a count show 0, and transitions sharing a state and event show the same count.
+// Mermaid state diagrams cannot style individual edges, so the counts are only shown in
+// the labels.
+func (wd *WorkflowDefinition) ToMermaidWithCounts(counts map[StateEvent]int) string {
+	if counts == nil {
+		counts = map[StateEvent]int{}
+	}
+	return wd.toMermaid("", counts)
+}
+
+// ToDOT renders the workflow as a Graphviz DOT digraph.
+// Edges are labelled with the transition description, or the event if there is none.
+// Transitions with dynamic (empty) targets are omitted since they are resolved at runtime.
+func (wd *WorkflowDefinition) ToDOT() string {
+	return wd.toDOT("")
+}
+
+// ToDOTWithCurrent renders the workflow as a Graphviz DOT digraph with the given
+// state highlighted. It returns an error if the state does not exist.
+func (wd *WorkflowDefinition) ToDOTWithCurrent(current string) (string, error) {
+	if _, exists := wd.States[current]; !exists {
+		return "", fmt.Errorf("state %s not found", current)
+	}
+	return wd.toDOT(current), nil
+}
+
+// ToPlantUML renders the workflow as a PlantUML state diagram.
+// Edges are labelled with the event followed by the transition's conditions in brackets.
+// OnEnter and OnLeave actions are listed in the state's description, and side quest and
+// final states are marked with the <<sideQuest>> and <<final>> stereotypes.
+// Transitions with dynamic (empty) targets are omitted since they are resolved at runtime.
+func (wd *WorkflowDefinition) ToPlantUML() string {
+	names := wd.sortedStateNames()
+	ids := diagramStateIDs(names)
+
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+
+	for _, name := range names {
+		state := wd.States[name]
+
+		var stereotypes []string
+		if state.IsSideQuest {
+			stereotypes = append(stereotypes, "<<sideQuest>>")
+		}
+		if state.IsFinal {
+			stereotypes = append(stereotypes, "<<final>>")
+		}
+
+		declaration := ids[name]
+		if ids[name] != name {
+			declaration = fmt.Sprintf("%q as %s", name, ids[name])
+		}
+		if len(stereotypes) > 0 {
+			declaration += " " + strings.Join(stereotypes, " ")
+		}
+		if declaration != name {
+			fmt.Fprintf(&b, "state %s\n", declaration)
+		}
+
+		if len(state.OnEnter) > 0 {
+			fmt.Fprintf(&b, "%s : OnEnter: %s\n", ids[name], strings.Join(state.OnEnter, ", "))
+		}
+		if len(state.OnLeave) > 0 {
+			fmt.Fprintf(&b, "%s : OnLeave: %s\n", ids[name], strings.Join(state.OnLeave, ", "))
+		}
+	}
+
+	if initialID, exists := ids[wd.InitialState]; exists {
+		fmt.Fprintf(&b, "[*] --> %s\n", initialID)
+	}
+
+	for _, name := range names {
+		for _, transition := range wd.exportTransitions(name) {
+			label := transition.Event
+			if len(transition.Conditions) > 0 {
+				label += " [" + strings.Join(transition.Conditions, ", ") + "]"
+			}
+			fmt.Fprintf(&b, "%s --> %s : %s\n", ids[name], ids[transition.Target], mermaidLabel(label))
+		}
+		if wd.States[name].IsFinal {
+			fmt.Fprintf(&b, "%s --> [*]\n", ids[name])
+		}
+	}
+
+	b.WriteString("@enduml\n")
+
+	return b.String()
+}
+
+// TransitionTable returns the workflow as a table with a row per state and a column per
+// distinct event, both sorted. The first row is the header, starting with "state". A cell
+// holds the target the state moves to on the event, or is blank if the event isn't handled.
+// Conditional transitions show their conditions as "target [cond1, cond2]", dynamic targets
+// show as "(dynamic)", and several transitions for the same event are joined with " | ".
+func (wd *WorkflowDefinition) TransitionTable() [][]string {
+	names := wd.sortedStateNames()
+
+	var events []string
+	for _, name := range names {
+		for _, transition := range wd.States[name].Transitions {
+			if !slices.Contains(events, transition.Event) {
+				events = append(events, transition.Event)
+			}
+		}
+	}
+	sort.Strings(events)
+
+	table := [][]string{append([]string{"state"}, events...)}
+	for _, name := range names {
+		row := make([]string, len(events)+1)
+		row[0] = name
+		for _, transition := range wd.States[name].Transitions {
+			column := slices.Index(events, transition.Event) + 1
+			if row[column] != "" {
+				row[column] += " | "
+			}
+			row[column] += transition.tableCell()
+		}
+		table = append(table, row)
+	}
+
+	return table
+}
+
+// WriteTransitionTableCSV writes TransitionTable to w as CSV
+func (wd *WorkflowDefinition) WriteTransitionTableCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(wd.TransitionTable()); err != nil {
+		return fmt.Errorf("failed to write transition table: %w", err)
+	}
+	return nil
+}
+
+// tableCell describes the transition for a TransitionTable cell
+func (t Transition) tableCell() string {
+	target := t.Target
+	if target == "" {
+		target = "(dynamic)"
+	}
+	if len(t.Conditions) > 0 {
+		target += " [" + strings.Join(t.Conditions, ", ") + "]"
+	}
+	return target
+}
+
+// sortedStateNames returns the state names in sorted order so exports are deterministic
+func (wd *WorkflowDefinition) sortedStateNames() []string {
+	names := make([]string, 0, len(wd.States))
+	for name := range wd.States {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toMermaid renders the Mermaid diagram, highlighting current if it is not empty and
+// adding counts to the edge labels if they are not nil
+func (wd *WorkflowDefinition) toMermaid(current string, counts map[StateEvent]int) string {
+	names := wd.sortedStateNames()
+	ids := diagramStateIDs(names)
+
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+
+	for _, name := range names {
+		if ids[name] != name {
+			fmt.Fprintf(&b, "    state %q as %s\n", name, ids[name])
+		}
+	}
+
+	if initialID, exists := ids[wd.InitialState]; exists {
+		fmt.Fprintf(&b, "    [*] --> %s\n", initialID)
+	}
+
+	for _, name := range names {
+		for _, transition := range wd.exportTransitions(name) {
+			label := transition.edgeLabel()
+			if counts != nil {
+				label = fmt.Sprintf("%s (%d)", label, counts[StateEvent{State: name, Event: transition.Event}])
+			}
+			fmt.Fprintf(&b, "    %s --> %s : %s\n", ids[name], ids[transition.Target], mermaidLabel(label))
+		}
+		if wd.States[name].IsFinal {
+			fmt.Fprintf(&b, "    %s --> [*]\n", ids[name])
+		}
+	}
+
+	var sideQuests []string
+	for _, name := range names {
+		if wd.States[name].IsSideQuest {
+			sideQuests = append(sideQuests, ids[name])
+		}
+	}
+	if len(sideQuests) > 0 {
+		b.WriteString("    classDef sideQuest stroke-dasharray: 5 5\n")
+		fmt.Fprintf(&b, "    class %s sideQuest\n", strings.Join(sideQuests, ","))
+	}
+
+	if current != "" {
+		b.WriteString("    classDef current fill:#f96,stroke:#333,stroke-width:2px\n")
+		fmt.Fprintf(&b, "    class %s current\n", ids[current])
+	}
+
+	return b.String()
+}
+
+// toDOT renders the DOT digraph, highlighting current if it is not empty
+func (wd *WorkflowDefinition) toDOT(current string) string {
+	names := wd.sortedStateNames()
+
+	var b strings.Builder
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("    rankdir=LR;\n")
+
+	if _, exists := wd.States[wd.InitialState]; exists {
+		b.WriteString("    __start [shape=point];\n")
+		fmt.Fprintf(&b, "    __start -> %s;\n", dotQuote(wd.InitialState))
+	}
+
+	for _, name := range names {
+		state := wd.States[name]
+
+		var attrs, styles []string
+		if state.IsFinal {
+			attrs = append(attrs, "shape=doublecircle")
+		}
+		if state.IsSideQuest {
+			styles = append(styles, "dashed")
+		}
+		if name == current {
+			styles = append(styles, "filled")
+			attrs = append(attrs, `fillcolor="#ff9966"`, "penwidth=2")
+		}
+		if len(styles) > 0 {
+			attrs = append(attrs, "style="+dotQuote(strings.Join(styles, ",")))
+		}
+
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "    %s [%s];\n", dotQuote(name), strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "    %s;\n", dotQuote(name))
+		}
+	}
+
+	for _, name := range names {
+		for _, transition := range wd.exportTransitions(name) {
+			fmt.Fprintf(&b, "    %s -> %s [label=%s];\n", dotQuote(name), dotQuote(transition.Target), dotQuote(transition.edgeLabel()))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// exportTransitions returns the transitions of the named state that exporters draw as
+// edges, in declaration order. Dynamic and unknown targets are left out.
+func (wd *WorkflowDefinition) exportTransitions(name string) []Transition {
+	var transitions []Transition
+	for _, transition := range wd.States[name].Transitions {
+		if _, exists := wd.States[transition.Target]; exists {
+			transitions = append(transitions, transition)
+		}
+	}
+	return transitions
+}
+
+// diagramStateIDs maps the sorted state names to diagram IDs. Names that aren't valid
+// identifiers are given positional IDs and declared with the name as their label.
+func diagramStateIDs(names []string) map[string]string {
+	ids := make(map[string]string, len(names))
+	for i, name := range names {
+		if diagramIdentifier.MatchString(name) {
+			ids[name] = name
+		} else {
+			ids[name] = fmt.Sprintf("s%d", i)
+		}
+	}
+	return ids
+}
+
+// edgeLabel returns the label exporters show for the transition, preferring its description
+func (t Transition) edgeLabel() string {
+	if t.Description != "" {
+		return t.Description
+	}
+	return t.Event
+}
+
+// mermaidLabel flattens s onto a single line, as Mermaid transition labels end at a newline
+func mermaidLabel(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// dotQuote quotes s as a DOT string literal
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
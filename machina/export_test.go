@@ -0,0 +1,212 @@
+package machina
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// exportTestDefinition returns a small workflow exercising initial, final and side quest states
+func exportTestDefinition() *WorkflowDefinition {
+	return &WorkflowDefinition{
+		InitialState: "start",
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "proceed",
+						Target: "end",
+					},
+					{
+						Event:  "detour",
+						Target: "B#",
+					},
+				},
+			},
+			"B#": {
+				Name:        "B#",
+				IsSideQuest: true,
+				Transitions: []Transition{
+					{
+						Event:   "return",
+						Target:  "",
+						Actions: []string{ReturnToPreviousStateActionName},
+					},
+				},
+			},
+			"end": {
+				Name:    "end",
+				IsFinal: true,
+			},
+		},
+	}
+}
+
+func TestWorkflowDefinition_ToMermaid(t *testing.T) {
+	expected := `stateDiagram-v2
+    state "B#" as s0
+    [*] --> start
+    end --> [*]
+    start --> end : proceed
+    start --> s0 : detour
+    classDef sideQuest stroke-dasharray: 5 5
+    class s0 sideQuest
+`
+
+	if got := exportTestDefinition().ToMermaid(); got != expected {
+		t.Errorf("Unexpected Mermaid output:\n%s", got)
+	}
+}
+
+func TestWorkflowDefinition_ExportDeterministic(t *testing.T) {
+	definition := exportTestDefinition()
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("state%02d", i)
+		definition.States[name] = State{Name: name, Transitions: []Transition{{Event: "next", Target: "end"}}}
+	}
+
+	if first, second := definition.ToMermaid(), definition.ToMermaid(); first != second {
+		t.Errorf("Expected identical Mermaid output, got:\n%s\nand:\n%s", first, second)
+	}
+	if first, second := definition.ToPlantUML(), definition.ToPlantUML(); first != second {
+		t.Errorf("Expected identical PlantUML output, got:\n%s\nand:\n%s", first, second)
+	}
+	if first, second := definition.ToDOT(), definition.ToDOT(); first != second {
+		t.Errorf("Expected identical DOT output, got:\n%s\nand:\n%s", first, second)
+	}
+}
+
+func TestWorkflowDefinition_ToMermaidWithCurrent(t *testing.T) {
+	definition := exportTestDefinition()
+
+	got, err := definition.ToMermaidWithCurrent("B#")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.HasSuffix(got, "    classDef current fill:#f96,stroke:#333,stroke-width:2px\n    class s0 current\n") {
+		t.Errorf("Expected current state to be highlighted, got:\n%s", got)
+	}
+
+	if _, err := definition.ToMermaidWithCurrent("missing"); err == nil {
+		t.Error("Expected error for unknown current state, got nil")
+	}
+}
+
+func TestWorkflowDefinition_ToMermaidWithCounts(t *testing.T) {
+	counts := map[StateEvent]int{
+		{State: "start", Event: "proceed"}: 1204,
+		{State: "end", Event: "proceed"}:   7,
+	}
+
+	got := exportTestDefinition().ToMermaidWithCounts(counts)
+
+	for _, edge := range []string{"    start --> end : proceed (1204)\n", "    start --> s0 : detour (0)\n"} {
+		if !strings.Contains(got, edge) {
+			t.Errorf("Expected edge %q, got:\n%s", edge, got)
+		}
+	}
+}
+
+func TestWorkflowDefinition_ToDOT(t *testing.T) {
+	expected := `digraph workflow {
+    rankdir=LR;
+    __start [shape=point];
+    __start -> "start";
+    "B#" [style="dashed"];
+    "end" [shape=doublecircle];
+    "start";
+    "start" -> "end" [label="proceed"];
+    "start" -> "B#" [label="detour"];
+}
+`
+
+	if got := exportTestDefinition().ToDOT(); got != expected {
+		t.Errorf("Unexpected DOT output:\n%s", got)
+	}
+}
+
+func TestWorkflowDefinition_ToDOTWithCurrent(t *testing.T) {
+	definition := exportTestDefinition()
+
+	got, err := definition.ToDOTWithCurrent("B#")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.Contains(got, `"B#" [fillcolor="#ff9966", penwidth=2, style="dashed,filled"];`) {
+		t.Errorf("Expected current state to be highlighted, got:\n%s", got)
+	}
+
+	if _, err := definition.ToDOTWithCurrent("missing"); err == nil {
+		t.Error("Expected error for unknown current state, got nil")
+	}
+}
+
+func TestWorkflowDefinition_ExportDescriptionLabels(t *testing.T) {
+	definition := exportTestDefinition()
+	start := definition.States["start"]
+	start.Transitions[0].Description = "Order\nshipped"
+	definition.States["start"] = start
+
+	if got := definition.ToMermaid(); !strings.Contains(got, "    start --> end : Order shipped\n") {
+		t.Errorf("Expected description as Mermaid edge label, got:\n%s", got)
+	}
+
+	if got := definition.ToDOT(); !strings.Contains(got, "    \"start\" -> \"end\" [label=\"Order\nshipped\"];\n") {
+		t.Errorf("Expected description as DOT edge label, got:\n%s", got)
+	}
+}
+
+func TestWorkflowDefinition_ToPlantUML(t *testing.T) {
+	definition := exportTestDefinition()
+	start := definition.States["start"]
+	start.OnEnter = []string{"logEnter"}
+	start.OnLeave = []string{"logLeave", "audit"}
+	start.Transitions[0].Conditions = []string{"isPaid", "inStock"}
+	definition.States["start"] = start
+
+	expected := `@startuml
+state "B#" as s0 <<sideQuest>>
+state end <<final>>
+start : OnEnter: logEnter
+start : OnLeave: logLeave, audit
+[*] --> start
+end --> [*]
+start --> end : proceed [isPaid, inStock]
+start --> s0 : detour
+@enduml
+`
+
+	if got := definition.ToPlantUML(); got != expected {
+		t.Errorf("Unexpected PlantUML output:\n%s", got)
+	}
+}
+
+func TestWorkflowDefinition_TransitionTable(t *testing.T) {
+	definition := exportTestDefinition()
+	start := definition.States["start"]
+	start.Transitions = append(start.Transitions,
+		Transition{Event: "proceed", Target: "B#", Conditions: []string{"needsReview", "isLarge"}},
+	)
+	definition.States["start"] = start
+
+	var b strings.Builder
+	if err := definition.WriteTransitionTableCSV(&b); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `state,detour,proceed,return
+B#,,,(dynamic)
+end,,,
+start,B#,"end | B# [needsReview, isLarge]",
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected transition table:\n%s", b.String())
+	}
+
+	if table := definition.TransitionTable(); len(table) != 4 || len(table[0]) != 4 {
+		t.Errorf("Expected a 4x4 table, got %v", table)
+	}
+}
@@ -4,8 +4,16 @@ package machina
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,12 +24,51 @@ import (
 )
 
 // TransitionResult holds all the successful outcomes of a Trigger event.
+// It encodes to JSON as {"newState", "autoEvent", "data", "history", "chosenTransition"}.
 type TransitionResult struct {
 	NewState        string
 	AutoEvent       string
 	PersistenceData map[string]any
+	// History lists every transition applied by the Trigger call, in order.
+	// It is only populated when auto-event chaining is enabled.
+	History []TransitionStep
+	// ChosenTransition identifies the declared transition that was applied, which tells
+	// same-event transitions apart. With auto-event chaining it describes the last one.
+	ChosenTransition *ChosenTransition
 }
 
+// ChosenTransition identifies a transition of the source state by its position and declaration
+type ChosenTransition struct {
+	// Index is the position of the transition in the source state's Transitions
+	Index int `json:"index"`
+	// Event is the declared event, which is WildcardEvent for a wildcard transition
+	Event string `json:"event"`
+	// Target is the declared target; NewState differs if an action set a dynamic target
+	Target     string   `json:"target,omitempty"`
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// TransitionStep records a single transition applied during a Trigger call
+type TransitionStep struct {
+	FromState string `json:"fromState"`
+	Event     string `json:"event"`
+	ToState   string `json:"toState"`
+}
+
+// NextStateOverrideKey is the persistence data key actions could historically use to set a dynamic target.
+//
+// Deprecated: call SetNextState from the transition action instead.
+const NextStateOverrideKey = "__next_state_override"
+
+// WildcardEvent is the event of a transition that handles any event the state has no
+// transition for, such as routing unknown events to an "unhandled" state. A state's
+// transitions for the event itself take precedence even when their conditions fail, in
+// which case the event fails with ErrTransitionNotFound instead of reaching the wildcard.
+const WildcardEvent = "*"
+
+// ReturnToPreviousStateActionName is the name under which ReturnToPreviousStateAction is registered
+const ReturnToPreviousStateActionName = "__RETURN_TO_PREVIOUS_STATE__"
+
 // StateMachine represents the finite state machine
 type StateMachine struct {
 	definition *WorkflowDefinition
@@ -29,15 +76,99 @@ type StateMachine struct {
 	logger     *slog.Logger
 	metrics    *Metrics
 	tracer     trace.Tracer
+	// customTracer is set once WithTracer replaces the default tracer
+	customTracer bool
+
+	// eventPatterns holds the compiled patterns of regex-matched transitions, keyed by pattern
+	eventPatterns map[string]*regexp.Regexp
+
+	// metricsRegisterer and metricsConfig are collected from options to build metrics
+	metricsRegisterer prometheus.Registerer
+	metricsConfig     MetricsConfig
+
+	// autoEventMaxDepth is the number of auto events Trigger follows; 0 disables chaining
+	autoEventMaxDepth int
+	// maxStackDepth limits the WorkflowStack depth; 0 means no limit
+	maxStackDepth int
+	// maxTransitionDepth limits how deeply triggers may nest; 0 means no limit
+	maxTransitionDepth int
+	// strictSideQuests only allows returning to a previous state from side quest states
+	strictSideQuests bool
+	// predefinedActionsDisabled stops the built-in side quest actions from being registered
+	predefinedActionsDisabled bool
+	// protectedKeys are the persistence data keys actions may not overwrite
+	protectedKeys map[string]bool
+	// subWorkflows holds the child machines of states declaring a SubWorkflow
+	subWorkflows *MachineRegistry
+	// selectionTrace records why each candidate transition was rejected
+	selectionTrace bool
+	// redactedKeys are the data keys masked in logs
+	redactedKeys map[string]bool
+	// visitCounting records state entries under VisitsKey
+	visitCounting bool
+	// httpClient posts transition webhooks; nil means defaultWebhookClient
+	httpClient *http.Client
+	// webhookFailurePolicy decides whether a failed webhook fails the transition
+	webhookFailurePolicy WebhookFailurePolicy
+	// eventBus receives completed transitions under topics named after busName
+	eventBus EventBus
+	busName  string
+	// resolver supplies actions and conditions missing from the registry, if set
+	resolver Resolver
+	// conditionFailureAsNonError stops guards that return false from counting as transition errors
+	conditionFailureAsNonError bool
+	// collectAllConditionFailures evaluates every condition instead of stopping at the first failure
+	collectAllConditionFailures bool
+	// clock is exposed to conditions and actions through ClockFromContext
+	clock Clock
+	// authorizer decides whether the caller may fire a transition, if set
+	authorizer AuthorizerFunc
+	// idempotencyKey names the payload key holding the idempotency key of an event
+	idempotencyKey string
+	// idempotencyStore records results by workflow ID and idempotency key, if set
+	idempotencyStore IdempotencyStore
+	// random picks among weighted transitions; nil uses the global source
+	random   *rand.Rand
+	randomMu sync.Mutex
+	// logLevel is the minimum level logged, if set with WithLogLevel
+	logLevel slog.Leveler
+	// conditionCacheDisabled makes conditions run every time they are referenced
+	conditionCacheDisabled bool
+	// rateLimits holds the token buckets of transitions declaring a RateLimit
+	rateLimits rateLimiter
+	// parallelConditions evaluates the conditions of the chosen transition concurrently
+	parallelConditions bool
+	// middleware wraps every transition, outermost first
+	middleware []Middleware
+	// transition is trigger wrapped in the middleware
+	transition TriggerFunc
 }
 
+// StateMachine implements Machine
+var _ Machine = (*StateMachine)(nil)
+
 // StateMachineOption is a function that configures a StateMachine
 type StateMachineOption func(*StateMachine)
 
 // WithMetrics configures the StateMachine with Prometheus metrics
 func WithMetrics(reg prometheus.Registerer) StateMachineOption {
 	return func(sm *StateMachine) {
-		sm.metrics = NewMetrics(reg)
+		sm.metricsRegisterer = reg
+	}
+}
+
+// WithMetricsBuckets configures the histogram buckets, in seconds, used for transition durations
+func WithMetricsBuckets(buckets []float64) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.metricsConfig.DurationBuckets = buckets
+	}
+}
+
+// WithMetricsWorkflowName labels every metric with workflow=name, so metrics of machines
+// running different workflows can be told apart
+func WithMetricsWorkflowName(name string) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.metricsConfig.WorkflowName = name
 	}
 }
 
@@ -45,31 +176,106 @@ func WithMetrics(reg prometheus.Registerer) StateMachineOption {
 func WithTracer(tracer trace.Tracer) StateMachineOption {
 	return func(sm *StateMachine) {
 		sm.tracer = tracer
+		sm.customTracer = true
+	}
+}
+
+// WithMaxTransitionDepth rejects a transition once n transitions already enclose it, as
+// reported by TransitionDepthFromContext. This stops an action that triggers the same
+// machine again, directly or through sub-workflows, from recursing until the stack overflows.
+func WithMaxTransitionDepth(n int) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.maxTransitionDepth = n
 	}
 }
 
-// NewStateMachine creates a new state machine instance
+// WithRandomSource sets the source used to pick among weighted transitions, so tests can
+// use a fixed seed
+func WithRandomSource(source rand.Source) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.random = rand.New(source)
+	}
+}
+
+// WithConditionFailureAsNonError stops conditions that evaluate to false from being
+// counted in the transition errors metric. Trigger still returns an error, and condition
+// errors are still counted as condition_error.
+func WithConditionFailureAsNonError() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.conditionFailureAsNonError = true
+	}
+}
+
+// WithCollectAllConditionFailures makes Trigger evaluate every condition of the chosen
+// transition rather than stopping at the first that fails, so a rejection can list every
+// failed guard. The failures are returned as a *ConditionFailures. Without this option
+// conditions are evaluated fail-fast.
+func WithCollectAllConditionFailures() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.collectAllConditionFailures = true
+	}
+}
+
+// WithAutoEventChaining makes Trigger keep firing each transition's AutoEvent until
+// none remains, following at most maxDepth auto events. The returned result describes
+// the final state and its History lists every step. If the chain is still going after
+// maxDepth auto events, Trigger fails with an error reporting the path taken.
+// A maxDepth of zero or less leaves chaining disabled.
+func WithAutoEventChaining(maxDepth int) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.autoEventMaxDepth = maxDepth
+	}
+}
+
+// NewStateMachine creates a new state machine instance.
+// It logs and returns nil if the definition or registry is missing or the definition is
+// invalid; use NewStateMachineE to get the error.
 func NewStateMachine(definition *WorkflowDefinition, registry *Registry, logger *slog.Logger, opts ...StateMachineOption) *StateMachine {
+	sm, err := NewStateMachineE(definition, registry, logger, opts...)
+	if err != nil {
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Error("Failed to create state machine", "error", err)
+		return nil
+	}
+	return sm
+}
+
+// NewStateMachineE creates a new state machine instance, returning an error if the definition
+// or registry is nil or the definition is invalid.
+// It registers the predefined side quest actions in the registry unless WithoutPredefinedActions
+// is given. A registry may be shared between machines, and an action the caller already
+// registered under a predefined name is kept.
+func NewStateMachineE(definition *WorkflowDefinition, registry *Registry, logger *slog.Logger, opts ...StateMachineOption) (*StateMachine, error) {
 	if logger == nil {
 		logger = slog.Default()
 	}
 
+	if definition == nil {
+		return nil, fmt.Errorf("workflow definition must not be nil")
+	}
+	if registry == nil {
+		return nil, fmt.Errorf("registry must not be nil; create one with NewRegistry")
+	}
+
 	// Validate the workflow definition
 	if err := definition.Validate(); err != nil {
-		logger.Error("Invalid workflow definition", "error", err)
-		return nil
+		return nil, fmt.Errorf("invalid workflow definition: %w", err)
 	}
 
-	// Register the predefined RETURN_TO_PREVIOUS_STATE action
-	registry.RegisterAction("__RETURN_TO_PREVIOUS_STATE__", ReturnToPreviousStateAction)
+	eventPatterns, err := compileEventPatterns(definition)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow definition: %w", err)
+	}
 
 	sm := &StateMachine{
-		definition: definition,
-		registry:   registry,
-		logger:     logger,
-		tracer:     otel.Tracer("gomachina"),
-		// Initialize with no-op metrics by default
-		metrics: NewMetrics(nil),
+		definition:    definition,
+		eventPatterns: eventPatterns,
+		registry:      registry,
+		logger:        logger,
+		tracer:        otel.Tracer(defaultTracerName),
+		clock:         realClock{},
 	}
 
 	// Apply options
@@ -77,38 +283,170 @@ func NewStateMachine(definition *WorkflowDefinition, registry *Registry, logger
 		opt(sm)
 	}
 
-	return sm
+	if err := sm.registerPredefinedActions(); err != nil {
+		return nil, err
+	}
+
+	core := sm.trigger
+	if definition.ErrorState != "" {
+		core = sm.triggerWithErrorState
+	}
+	sm.transition = sm.chainMiddleware(core)
+
+	// Metrics are unregistered (no-op) unless WithMetrics supplied a registerer
+	sm.metrics = NewMetricsWithConfig(sm.metricsRegisterer, sm.metricsConfig)
+
+	if sm.logLevel != nil {
+		sm.logger = slog.New(&levelHandler{level: sm.logLevel, handler: sm.logger.Handler()})
+	}
+
+	return sm, nil
 }
 
-// Trigger processes a single event and causes a state transition
+// Trigger processes a single event and causes a state transition.
+// With WithAutoEventChaining, it also follows any resulting auto events; if one of them
+// fails, the error is returned and no result is reported for the chain, unless it was
+// canceled with CancelWorkflow, in which case the result reports the state it stopped in.
+// Updates from actions are staged and only returned once the whole transition has succeeded,
+// and the payload is never modified.
+// If an OnEnter action of the target state fails, the workflow remains in currentState:
+// Trigger returns an *ErrEnterRolledBack together with a result for currentState whose
+// PersistenceData can be used to trigger from it again.
+// A transition that runs no actions, such as a pure routing edge, returns the payload map
+// itself as PersistenceData rather than a copy.
+// With WithIdempotency, an event redelivered with the same idempotency key returns the
+// result recorded the first time without running any actions.
 func (sm *StateMachine) Trigger(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error) {
+	if sm.idempotencyStore != nil {
+		return sm.triggerIdempotent(ctx, currentState, event, payload)
+	}
+	return sm.triggerChain(ctx, currentState, event, payload)
+}
+
+// triggerChain processes an event and, with WithAutoEventChaining, the auto events that follow
+func (sm *StateMachine) triggerChain(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error) {
+	result, err := sm.transition(ctx, currentState, event, payload)
+	if err != nil || sm.autoEventMaxDepth <= 0 {
+		return result, err
+	}
+
+	history := []TransitionStep{{FromState: currentState, Event: event, ToState: result.NewState}}
+	for result.AutoEvent != "" {
+		if len(history) > sm.autoEventMaxDepth {
+			return nil, fmt.Errorf("auto-event chain exceeded max depth %d: %s", sm.autoEventMaxDepth, formatTransitionPath(history, result.AutoEvent))
+		}
+
+		fromState, autoEvent := result.NewState, result.AutoEvent
+		result, err = sm.transition(ctx, fromState, autoEvent, result.PersistenceData)
+		if err != nil {
+			err = fmt.Errorf("auto event %s from state %s failed: %w", autoEvent, fromState, err)
+			// A canceled chain still reports where it stopped
+			var canceled *ErrWorkflowCanceled
+			if errors.As(err, &canceled) && result != nil {
+				result.History = history
+				return result, err
+			}
+			return nil, err
+		}
+		history = append(history, TransitionStep{FromState: fromState, Event: autoEvent, ToState: result.NewState})
+		if sm.metrics != nil {
+			sm.metrics.AutoTransitionsTotal.WithLabelValues(fromState, result.NewState, autoEvent).Inc()
+		}
+	}
+
+	if sm.metrics != nil {
+		sm.metrics.AutoChainDepth.WithLabelValues(currentState).Observe(float64(len(history) - 1))
+	}
+
+	result.History = history
+	return result, nil
+}
+
+// formatTransitionPath renders a chain of steps, followed by the pending event, as
+// "A -(e1)-> B -(e2)-> C -(e3)-> ..."
+func formatTransitionPath(history []TransitionStep, pendingEvent string) string {
+	var b strings.Builder
+	b.WriteString(history[0].FromState)
+	for _, step := range history {
+		fmt.Fprintf(&b, " -(%s)-> %s", step.Event, step.ToState)
+	}
+	fmt.Fprintf(&b, " -(%s)-> ...", pendingEvent)
+	return b.String()
+}
+
+// trigger processes a single event without following auto events
+func (sm *StateMachine) trigger(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error) {
 	startTime := time.Now()
 
+	// Stamp a unique ID on the transition for correlation in actions, traces and metrics
+	ctx, transitionID := withTransitionID(ctx)
+
 	// Create a span for tracing
 	ctx, span := sm.tracer.Start(ctx, "fsm.transition",
 		trace.WithAttributes(
 			attribute.String("fsm.current_state", currentState),
 			attribute.String("fsm.event", event),
+			attribute.String("fsm.transition_id", transitionID),
 		))
 	defer span.End()
 
+	// Every log line of the transition carries the same identifying attributes
+	logger := sm.logger.With("from", currentState, "event", event, "txn_id", transitionID)
+	if workflowID, ok := WorkflowIDFromContext(ctx); ok {
+		span.SetAttributes(attribute.String("fsm.workflow_id", workflowID))
+		logger = logger.With("workflow_id", workflowID)
+	}
+
+	// Nested triggers, such as from an action calling Trigger again, see a deeper path
+	ctx, depth := withTransitionPath(ctx, currentState, event)
+	if sm.maxTransitionDepth > 0 && depth > sm.maxTransitionDepth {
+		err := fmt.Errorf("transition depth %d exceeds the limit of %d: %s", depth, sm.maxTransitionDepth, strings.Join(TransitionPathFromContext(ctx), " > "))
+		sm.recordTransitionError(currentState, event, "transition_depth_exceeded", err)
+		logger.Error("Nested trigger rejected", "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, sourceStateKey, currentState)
+	ctx = context.WithValue(ctx, eventKey, event)
+	ctx = sm.withConditionCache(ctx)
+	ctx = sm.withClock(ctx)
+	ctx, cancel := withCancelHolder(ctx)
+	if sm.maxStackDepth > 0 {
+		ctx = context.WithValue(ctx, maxStackDepthKey, sm.maxStackDepth)
+	}
+
 	// Find the current state definition
 	stateDef, err := sm.getStateDefinition(currentState)
 	if err != nil {
-		err = fmt.Errorf("failed to get state definition for %s: %w", currentState, err)
+		err = withKind(fmt.Errorf("failed to get state definition for %s: %w", currentState, err), ErrStateNotFound)
 		sm.recordTransitionError(currentState, event, "state_not_found", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	sm.logger.Info("Processing event", "state", currentState, "event", event, "payload", payload)
+	logger.Debug("Processing event", "payload", sm.logData(payload))
+
+	// A deferred event waits in the data until a state that handles it is entered
+	if slices.Contains(stateDef.DeferredEvents, event) {
+		persistenceData := make(map[string]any, len(payload)+1)
+		maps.Copy(persistenceData, payload)
+		deferEvent(persistenceData, event)
+		logger.Info("Event deferred", "deferred", DeferredEvents(persistenceData))
+		span.SetAttributes(attribute.Bool("fsm.deferred", true))
+		return &TransitionResult{NewState: currentState, PersistenceData: persistenceData}, nil
+	}
 
 	// Find the transition for the event
-	transition, err := sm.getTransitionForEvent(stateDef, event, ctx, payload)
+	transition, transitionIndex, err := sm.getTransitionForEvent(stateDef, event, ctx, payload)
 	if err != nil {
+		sm.suggestEvents(ctx, currentState, payload, err)
 		err = fmt.Errorf("no valid transition found for event %s in state %s: %w", event, currentState, err)
-		sm.recordTransitionError(currentState, event, "transition_not_found", err)
+		if !sm.conditionFailureAsNonError || !errors.Is(err, errGuardRejected) {
+			sm.recordTransitionError(currentState, event, "transition_not_found", err)
+		}
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
@@ -120,89 +458,253 @@ func (sm *StateMachine) Trigger(ctx context.Context, currentState string, event
 		attribute.StringSlice("fsm.actions", transition.Actions),
 	)
 
-	sm.logger.Info("Found transition", "event", event, "target", transition.Target, "conditions", transition.Conditions, "actions", transition.Actions)
+	logger.Debug("Found transition", "target", transition.Target, "conditions", transition.Conditions, "actions", transition.Actions)
 
-	// Initialize persistenceData as a copy of the payload to avoid modifying the original
-	persistenceData := make(map[string]any)
-	for k, v := range payload {
-		persistenceData[k] = v
+	// In strict mode, returning to a previous state is reserved for side quests
+	if sm.strictSideQuests && !stateDef.IsSideQuest && slices.Contains(transition.Actions, ReturnToPreviousStateActionName) {
+		err := fmt.Errorf("state %s is not a side quest and cannot return to a previous state", currentState)
+		sm.recordTransitionError(currentState, event, "not_side_quest", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
 
-	// Check all conditions for the transition
-	if err := sm.executeConditions(ctx, currentState, event, transition, payload); err != nil {
+	// Reject callers the authorizer doesn't allow to fire the transition
+	if err := sm.authorize(ctx, currentState, event, transition, payload); err != nil {
+		logger.Info("Transition not authorized", "error", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	// Execute transition actions (proposed new order)
-	if err := sm.executeTransitionActions(ctx, currentState, event, transition.Actions, payload, persistenceData); err != nil {
+	// Reject payloads missing the data the transition needs before any condition sees them
+	if err := sm.validatePayload(ctx, currentState, event, transition, payload); err != nil {
+		sm.recordTransitionError(currentState, event, payloadErrorType(err), err)
+		logger.Info("Payload rejected", "error", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	// Check for dynamic transition target override
-	nextStateOverride, hasOverride := persistenceData["__next_state_override"]
-	if hasOverride {
-		if overrideStr, ok := nextStateOverride.(string); ok && overrideStr != "" {
-			transition.Target = overrideStr
-			span.SetAttributes(attribute.String("fsm.dynamic_target", overrideStr))
-			sm.logger.Info("Dynamic transition target override", "from", transition.Target, "to", overrideStr)
-			// Clear the override value so it doesn't affect future transitions
-			delete(persistenceData, "__next_state_override")
+	// A pure routing edge never writes to the data, so it can be returned as is
+	var persistenceData, actionData map[string]any
+	if sm.routesOnly(stateDef, transition, payload) {
+		persistenceData = payload
+		if persistenceData == nil {
+			persistenceData = make(map[string]any)
+		}
+	} else {
+		// Initialize persistenceData as a copy of the payload to avoid modifying the original
+		persistenceData = make(map[string]any, len(payload))
+		for k, v := range payload {
+			persistenceData[k] = v
+		}
+
+		// Actions read a private copy of the payload as well, so an action changing its input in
+		// place cannot leak into the caller's map or the data returned if the transition fails
+		actionData = maps.Clone(payload)
+		if actionData == nil {
+			actionData = make(map[string]any)
 		}
 	}
 
-	// Execute OnLeave actions for the current state
-	if err := sm.executeOnLeaveActions(ctx, currentState, event, stateDef.OnLeave, payload, persistenceData); err != nil {
+	chosen := &ChosenTransition{
+		Index:      transitionIndex,
+		Event:      transition.Event,
+		Target:     transition.Target,
+		Conditions: slices.Clone(transition.Conditions),
+	}
+
+	// Check all conditions for the transition
+	if err := sm.executeConditions(ctx, logger, currentState, event, transition, payload); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
+	if err := sm.checkWorkflowCanceled(logger, cancel, currentState, event, "conditions"); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return canceledResult(currentState, payload), err
+	}
 
-	// Execute OnEnter actions for the target state
-	targetStateDef, err := sm.getStateDefinition(transition.Target)
-	if err != nil {
-		err = fmt.Errorf("failed to get target state definition for %s: %w", transition.Target, err)
-		sm.recordTransitionError(currentState, event, "target_state_not_found", err)
+	// The conditions selected this transition, so a failing precondition fails the event
+	if err := sm.executePreconditions(ctx, logger, currentState, event, transition, payload); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	if err := sm.executeOnEnterActions(ctx, currentState, event, transition.Target, targetStateDef.OnEnter, payload, persistenceData); err != nil {
+	// Firing the transition uses up one of its rate limit tokens for the workflow
+	if err := sm.checkRateLimit(ctx, currentState, event, transitionIndex, transition); err != nil {
+		logger.Info("Transition rate limited", "error", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
+	// Execute transition actions (proposed new order)
+	ctx, nextState := withNextStateHolder(ctx)
+	ctx, compensations := withCompensationHolder(ctx)
+	err = sm.executeTransitionActions(ctx, logger, currentState, event, transition.Actions, actionData, persistenceData)
+	// Only sequential transition actions may choose the target, as parallel groups would race
+	nextState.sealed = true
+	if err == nil {
+		err = sm.executeParallelActions(ctx, logger, currentState, event, transition.Parallel, actionData, persistenceData)
+	}
+	if err != nil {
+		// Give the transition a chance to compensate for the actions that already ran
+		err = sm.executeOnErrorActions(ctx, logger, currentState, event, transition.OnError, err, persistenceData)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if err := sm.checkWorkflowCanceled(logger, cancel, currentState, event, "transition"); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return canceledResult(currentState, payload), err
+	}
+
+	// Catch custom actions that grew the workflow stack past the limit
+	if stack, ok := persistenceData[WorkflowStackKey].([]string); ok {
+		if err := checkStackDepth(stack, sm.maxStackDepth); err != nil {
+			sm.recordTransitionError(currentState, event, "stack_depth_exceeded", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	}
+
+	// Check for the deprecated __next_state_override key
+	// TODO: remove once callers have migrated to SetNextState
+	if nextStateOverride, hasOverride := persistenceData[NextStateOverrideKey]; hasOverride {
+		if overrideStr, ok := nextStateOverride.(string); ok && overrideStr != "" {
+			logger.Warn("Dynamic target set via deprecated key, use SetNextState instead", "key", NextStateOverrideKey)
+			if nextState.state == "" {
+				nextState.state = overrideStr
+			}
+			// Clear the override value so it doesn't affect future transitions
+			delete(persistenceData, NextStateOverrideKey)
+		}
+	}
+
+	// Apply the dynamic transition target, if any
+	if nextState.state != "" {
+		span.SetAttributes(attribute.String("fsm.dynamic_target", nextState.state))
+		logger.Debug("Dynamic transition target override", "declared_target", transition.Target, "target", nextState.state)
+		transition.Target = nextState.state
+	}
+
+	// An internal transition never leaves the state, so it cannot be routed elsewhere
+	var subWorkflowEvent string
+	if transition.Internal {
+		if transition.Target != currentState {
+			err := fmt.Errorf("internal transition for event %s must stay in state %s, got target %s", event, currentState, transition.Target)
+			sm.recordTransitionError(currentState, event, "invalid_internal_transition", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	} else {
+		// Execute OnLeave actions for the current state
+		if err := sm.executeOnLeaveActions(ctx, logger, currentState, event, stateDef.OnLeave, actionData, persistenceData); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		if err := sm.checkWorkflowCanceled(logger, cancel, currentState, event, "OnLeave"); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return canceledResult(currentState, payload), err
+		}
+
+		// Execute OnEnter actions for the target state
+		targetStateDef, err := sm.getStateDefinition(transition.Target)
+		if err != nil {
+			err = fmt.Errorf("failed to get target state definition for %s: %w", transition.Target, err)
+			sm.recordTransitionError(currentState, event, "target_state_not_found", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+
+		err = sm.executeOnEnterActions(ctx, logger, currentState, event, transition.Target, targetStateDef.OnEnter, actionData, persistenceData)
+		if err == nil && targetStateDef.SubWorkflow != nil {
+			// The child runs once the target has been entered, and its outcome picks the next event
+			subWorkflowEvent, err = sm.runSubWorkflow(ctx, logger, currentState, event, transition.Target, targetStateDef.SubWorkflow, persistenceData)
+		}
+		if err == nil {
+			// The target was entered, but a cancellation still keeps the workflow where it was
+			err = sm.checkWorkflowCanceled(logger, cancel, currentState, event, "OnEnter")
+		}
+		if err != nil {
+			// The workflow stays where it was, so restore the original state and hand back data
+			// that is safe to trigger from it again
+			restoreData, err := sm.rollbackEntry(ctx, logger, stateDef, currentState, event, transition.Target, payload, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return &TransitionResult{NewState: currentState, PersistenceData: restoreData}, err
+		}
+	}
+
+	// Webhooks announce the completed transition and, if configured, can still fail it
+	if err := sm.postWebhooks(ctx, logger, currentState, event, transition, persistenceData); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if transition.Internal {
+			return nil, err
+		}
+		restoreData, err := sm.rollbackEntry(ctx, logger, stateDef, currentState, event, transition.Target, payload, err)
+		return &TransitionResult{NewState: currentState, PersistenceData: restoreData}, err
+	}
+
+	// The transition succeeded, so its compensations join the saga
+	pushCompensations(persistenceData, compensations.actions)
+
+	autoEvent := sm.autoEventFor(ctx, logger, currentState, event, transition, persistenceData)
+	if subWorkflowEvent != "" {
+		autoEvent = subWorkflowEvent
+	}
+	// Otherwise the entered state picks up the oldest deferred event it handles
+	if autoEvent == "" && !transition.Internal {
+		if targetStateDef, err := sm.getStateDefinition(transition.Target); err == nil {
+			autoEvent = sm.takeDeferredEvent(persistenceData, targetStateDef)
+		}
+	}
+
 	// Record successful transition metrics
 	duration := time.Since(startTime).Seconds()
 	if sm.metrics != nil {
-		sm.metrics.TransitionsTotal.WithLabelValues(currentState, transition.Target, event).Inc()
-		sm.metrics.TransitionDuration.WithLabelValues(currentState, transition.Target, event).Observe(duration)
+		exemplar := exemplarLabels(ctx)
+		addWithExemplar(sm.metrics.TransitionsTotal.WithLabelValues(currentState, transition.Target, event), exemplar)
+		observeWithExemplar(sm.metrics.TransitionDuration.WithLabelValues(currentState, transition.Target, event), duration, exemplar)
+
+		sm.metrics.StatesCurrent.WithLabelValues(currentState).Dec()
+		sm.metrics.StatesCurrent.WithLabelValues(transition.Target).Inc()
 
-		// Record auto transition if applicable
-		if transition.AutoEvent != "" {
-			sm.metrics.AutoTransitionsTotal.WithLabelValues(currentState, transition.Target, event).Inc()
+		// The auto event is only declared here; the chain in triggerChain counts it once fired
+		if autoEvent != "" {
+			sm.metrics.AutoEventsDeclaredTotal.WithLabelValues(currentState, transition.Target, event).Inc()
 		}
 	}
 
-	sm.logger.Info("Transition completed", "from", currentState, "to", transition.Target, "event", event, "duration_seconds", duration)
+	sm.publishTransition(currentState, event, transition.Target, persistenceData)
+
+	logger.Info("Transition completed", "to", transition.Target, "duration_seconds", duration)
 	span.SetAttributes(
 		attribute.String("fsm.new_state", transition.Target),
 		attribute.Float64("fsm.duration_seconds", duration),
 	)
 
 	return &TransitionResult{
-		NewState:        transition.Target,
-		AutoEvent:       transition.AutoEvent,
-		PersistenceData: persistenceData,
+		NewState:         transition.Target,
+		AutoEvent:        autoEvent,
+		PersistenceData:  persistenceData,
+		ChosenTransition: chosen,
 	}, nil
 }
 
-// GetAutoEventForTransition returns the auto event for a transition, if any
+// GetAutoEventForTransition returns the auto event declared for a transition, if any.
+// Its AutoEventConditions are not evaluated, so Trigger may still suppress it.
 func (sm *StateMachine) GetAutoEventForTransition(fromState, event string) (string, error) {
 	stateDef, err := sm.getStateDefinition(fromState)
 	if err != nil {
@@ -210,7 +712,7 @@ func (sm *StateMachine) GetAutoEventForTransition(fromState, event string) (stri
 	}
 
 	// Use a background context and empty payload for auto event lookup
-	transition, err := sm.getTransitionForEvent(stateDef, event, context.Background(), map[string]any{})
+	transition, _, err := sm.getTransitionForEvent(stateDef, event, context.Background(), map[string]any{})
 	if err != nil {
 		return "", fmt.Errorf("no valid transition found for event %s in state %s: %w", event, fromState, err)
 	}
@@ -218,6 +720,124 @@ func (sm *StateMachine) GetAutoEventForTransition(fromState, event string) (stri
 	return transition.AutoEvent, nil
 }
 
+// RecordInstanceStarted counts a new workflow instance in the given state for the
+// states-current gauge. Call it once when an instance is created so that subsequent
+// transitions out of its first state do not drive the gauge negative.
+func (sm *StateMachine) RecordInstanceStarted(state string) {
+	if sm.metrics != nil {
+		sm.metrics.StatesCurrent.WithLabelValues(state).Inc()
+	}
+}
+
+// IsTerminal reports whether the named state is marked as final in the workflow definition.
+// It returns false for unknown states.
+func (sm *StateMachine) IsTerminal(state string) bool {
+	stateDef, err := sm.getStateDefinition(state)
+	if err != nil {
+		return false
+	}
+	return stateDef.IsFinal
+}
+
+// CanTrigger reports whether Trigger would find a transition for the event in the given
+// state that the authorizer allows, accepts the payload and whose conditions pass.
+// Conditions are evaluated against the payload, but no actions run, so a transition can
+// still fail when it is triggered.
+func (sm *StateMachine) CanTrigger(ctx context.Context, currentState string, event string, payload map[string]any) (bool, error) {
+	stateDef, err := sm.getStateDefinition(currentState)
+	if err != nil {
+		return false, withKind(fmt.Errorf("failed to get state definition for %s: %w", currentState, err), ErrStateNotFound)
+	}
+
+	return sm.canTrigger(sm.withConditionCache(ctx), currentState, stateDef, event, payload)
+}
+
+// AvailableEvents returns the events CanTrigger accepts in the given state, in declaration order.
+// A wildcard transition is reported as WildcardEvent. Prefix and regex transitions are left
+// out, as their patterns are not events that can be triggered.
+func (sm *StateMachine) AvailableEvents(ctx context.Context, currentState string, payload map[string]any) ([]string, error) {
+	stateDef, err := sm.getStateDefinition(currentState)
+	if err != nil {
+		return nil, withKind(fmt.Errorf("failed to get state definition for %s: %w", currentState, err), ErrStateNotFound)
+	}
+
+	// Events share one condition cache, as nothing changes the payload between them
+	ctx = sm.withConditionCache(ctx)
+	var events []string
+	checked := make(map[string]bool, len(stateDef.Transitions))
+	for i := range stateDef.Transitions {
+		event := stateDef.Transitions[i].Event
+		if !stateDef.Transitions[i].isExact() || checked[event] {
+			continue
+		}
+		checked[event] = true
+
+		ok, err := sm.canTrigger(ctx, currentState, stateDef, event, payload)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// canTrigger checks the event against the state the way Trigger does, without running actions
+func (sm *StateMachine) canTrigger(ctx context.Context, currentState string, state *State, event string, payload map[string]any) (bool, error) {
+	ctx = context.WithValue(ctx, sourceStateKey, currentState)
+	ctx = context.WithValue(ctx, eventKey, event)
+	ctx = sm.withClock(ctx)
+
+	transition, _, err := sm.getTransitionForEvent(state, event, ctx, payload)
+	if errors.Is(err, ErrTransitionNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate event %s in state %s: %w", event, currentState, err)
+	}
+
+	if sm.strictSideQuests && !state.IsSideQuest && slices.Contains(transition.Actions, ReturnToPreviousStateActionName) {
+		return false, nil
+	}
+
+	if sm.authorizer != nil && sm.authorizer(ctx, *transition, payload) != nil {
+		return false, nil
+	}
+
+	if err := sm.validatePayload(ctx, currentState, event, transition, payload); err != nil {
+		if errors.Is(err, ErrInvalidPayload) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to evaluate event %s in state %s: %w", event, currentState, err)
+	}
+
+	// getTransitionForEvent only evaluates conditions when choosing among several transitions
+	candidates := len(state.matchingTransitions(event, sm.eventPatterns))
+	transitionContext := TransitionContext{Event: event, From: currentState}
+	if candidates == 1 {
+		ok, err := sm.conditionsMet(ctx, transitionContext, transition, payload)
+		if err != nil || !ok {
+			return false, wrapCanTriggerError(currentState, event, err)
+		}
+	}
+
+	// Trigger fails the event if a precondition of the selected transition doesn't hold
+	ok, err := sm.allConditionsMet(ctx, transitionContext, transition, transition.Preconditions, payload)
+	if err != nil {
+		return false, wrapCanTriggerError(currentState, event, err)
+	}
+	return ok, nil
+}
+
+// wrapCanTriggerError adds the state and event being evaluated to a non-nil error
+func wrapCanTriggerError(currentState, event string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("failed to evaluate event %s in state %s: %w", event, currentState, err)
+}
+
 // getStateDefinition finds a state definition by name
 func (sm *StateMachine) getStateDefinition(name string) (*State, error) {
 	state, exists := sm.definition.States[name]
@@ -227,193 +847,487 @@ func (sm *StateMachine) getStateDefinition(name string) (*State, error) {
 	return &state, nil
 }
 
-// getTransitionForEvent finds the transition for a specific event in a state
+// getTransitionForEvent finds the transition for a specific event in a state, along with its
+// index in the state's transitions.
 // For conditional transitions, it evaluates conditions and returns the first matching transition
-func (sm *StateMachine) getTransitionForEvent(state *State, event string, ctx context.Context, payload map[string]any) (*Transition, error) {
-	var matchingTransitions []Transition
-	
+// Exact matches take precedence over prefix, then regex, then wildcard transitions; see MatchMode.
+func (sm *StateMachine) getTransitionForEvent(state *State, event string, ctx context.Context, payload map[string]any) (*Transition, int, error) {
 	// Collect all transitions for the event
-	for _, transition := range state.Transitions {
-		if transition.Event == event {
-			matchingTransitions = append(matchingTransitions, transition)
-		}
-	}
-	
-	if len(matchingTransitions) == 0 {
-		return nil, fmt.Errorf("no transition found for event %s", event)
+	matchingIndices := state.matchingTransitions(event, sm.eventPatterns)
+
+	if len(matchingIndices) == 0 {
+		return nil, -1, withKind(&ErrUnknownEvent{State: state.Name, Event: event, Valid: state.exactEvents()}, ErrTransitionNotFound)
 	}
-	
+
 	// If only one transition, return it directly
-	if len(matchingTransitions) == 1 {
-		return &matchingTransitions[0], nil
-	}
-	
-	// Multiple transitions - evaluate conditions to find the first matching one
-	for _, transition := range matchingTransitions {
-		// If no conditions, this is a match
-		if len(transition.Conditions) == 0 {
-			return &transition, nil
-		}
-		
-		// Evaluate all conditions
-		allConditionsMet := true
-		for _, conditionName := range transition.Conditions {
-			condition, err := sm.registry.GetCondition(conditionName)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get condition %s: %w", conditionName, err)
-			}
-			
-			ok, err := condition(ctx, payload)
-			if err != nil {
-				return nil, fmt.Errorf("condition %s failed: %w", conditionName, err)
-			}
-			
-			if !ok {
-				allConditionsMet = false
-				break
+	if len(matchingIndices) == 1 {
+		transition := state.Transitions[matchingIndices[0]]
+		return &transition, matchingIndices[0], nil
+	}
+
+	// Multiple transitions - evaluate conditions to find the first matching one.
+	// If any carry a weight, one of the weighted transitions whose conditions pass is
+	// picked at random instead, falling back to the first unweighted match.
+	weighted := slices.ContainsFunc(matchingIndices, func(i int) bool { return state.Transitions[i].Weight > 0 })
+	transitionContext := TransitionContext{Event: event, From: state.Name}
+	var candidates []int
+	var trace *SelectionTrace
+	fallback := -1
+	for _, i := range matchingIndices {
+		transition := state.Transitions[i]
+		failed, err := sm.failedCondition(ctx, transitionContext, &transition, transition.Conditions, payload)
+		if err != nil {
+			return nil, -1, err
+		}
+		if failed != "" {
+			if sm.selectionTrace {
+				if trace == nil {
+					trace = &SelectionTrace{State: state.Name, Event: event}
+				}
+				trace.Candidates = append(trace.Candidates, CandidateTrace{Index: i, Target: transition.Target, FailedCondition: failed})
 			}
+			continue
+		}
+
+		if !weighted {
+			return &transition, i, nil
 		}
-		
-		// If all conditions are met, this is our transition
-		if allConditionsMet {
-			return &transition, nil
+		if transition.Weight > 0 {
+			candidates = append(candidates, i)
+		} else if fallback < 0 {
+			fallback = i
 		}
 	}
-	
-	return nil, fmt.Errorf("no transition found for event %s with matching conditions", event)
+
+	chosen := fallback
+	if len(candidates) > 0 {
+		chosen = sm.pickWeighted(state.Transitions, candidates)
+	}
+	if chosen >= 0 {
+		transition := state.Transitions[chosen]
+		return &transition, chosen, nil
+	}
+
+	if trace != nil {
+		return nil, -1, withKind(fmt.Errorf("no transition found for event %s with matching conditions: %w", event, trace), ErrTransitionNotFound, errGuardRejected)
+	}
+	return nil, -1, withKind(fmt.Errorf("no transition found for event %s with matching conditions", event), ErrTransitionNotFound, errGuardRejected)
+}
+
+// routesOnly reports whether taking the transition runs no actions at all, so nothing can
+// write to the data: no transition, parallel or OnLeave actions, no OnEnter actions of the
+// target, no deprecated next state override to remove from the payload and no deferred
+// events to take from it
+func (sm *StateMachine) routesOnly(state *State, transition *Transition, payload map[string]any) bool {
+	if len(transition.Actions) > 0 || len(transition.Parallel) > 0 {
+		return false
+	}
+	if _, hasOverride := payload[NextStateOverrideKey]; hasOverride {
+		return false
+	}
+	if transition.Internal {
+		return true
+	}
+	if sm.visitCounting {
+		return false
+	}
+	if _, hasDeferred := payload[DeferredEventsKey]; hasDeferred {
+		return false
+	}
+
+	target, exists := sm.definition.States[transition.Target]
+	return exists && len(state.OnLeave) == 0 && len(target.OnEnter) == 0 && target.SubWorkflow == nil
 }
 
-// mergeData merges two data maps
+// conditionsMet evaluates the transition's conditions, stopping at the first that fails
+func (sm *StateMachine) conditionsMet(ctx context.Context, transitionContext TransitionContext, transition *Transition, payload map[string]any) (bool, error) {
+	return sm.allConditionsMet(ctx, transitionContext, transition, transition.Conditions, payload)
+}
+
+// allConditionsMet evaluates the named conditions of the transition, stopping at the first that fails
+func (sm *StateMachine) allConditionsMet(ctx context.Context, transitionContext TransitionContext, transition *Transition, names []string, payload map[string]any) (bool, error) {
+	failed, err := sm.failedCondition(ctx, transitionContext, transition, names, payload)
+	return err == nil && failed == "", err
+}
+
+// failedCondition evaluates the named conditions of the transition in order and returns the
+// first that evaluates to false, or "" if they all pass
+func (sm *StateMachine) failedCondition(ctx context.Context, transitionContext TransitionContext, transition *Transition, names []string, payload map[string]any) (string, error) {
+	transitionContext.Target = transition.Target
+	for _, conditionName := range names {
+		condition, err := sm.getCondition(conditionName)
+		if err != nil {
+			return "", fmt.Errorf("failed to get condition %s: %w", conditionName, err)
+		}
+
+		ok, err := sm.cachedCondition(ctx, conditionName, condition, transitionContext, payload, transition.ConditionArgs[conditionName])
+		if err != nil {
+			return "", fmt.Errorf("condition %s failed: %w", conditionName, err)
+		}
+
+		if !ok {
+			return conditionName, nil
+		}
+	}
+	return "", nil
+}
+
+// pickWeighted picks one of the candidate transition indices at random, with probability
+// proportional to the transition's weight
+func (sm *StateMachine) pickWeighted(transitions []Transition, candidates []int) int {
+	total := 0
+	for _, i := range candidates {
+		total += transitions[i].Weight
+	}
+
+	n := sm.randomIntn(total)
+	for _, i := range candidates {
+		n -= transitions[i].Weight
+		if n < 0 {
+			return i
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// randomIntn returns a random number in [0, n) from the configured source, if any
+func (sm *StateMachine) randomIntn(n int) int {
+	if sm.random == nil {
+		return rand.Intn(n)
+	}
+
+	// rand.Rand is not safe for concurrent use, unlike the top-level functions
+	sm.randomMu.Lock()
+	defer sm.randomMu.Unlock()
+	return sm.random.Intn(n)
+}
+
+// handlesEvent reports whether the state declares an exact-match transition for this event
+func (s *State) handlesEvent(event string) bool {
+	return slices.ContainsFunc(s.Transitions, func(t Transition) bool {
+		return t.isExact() && t.Event == event
+	})
+}
+
+// mergeData merges two data maps, removing the keys updates sets to Delete
 func (sm *StateMachine) mergeData(original, updates map[string]any) map[string]any {
 	// Merge the maps
 	result := make(map[string]any)
 	for k, v := range original {
 		result[k] = v
 	}
-	for k, v := range updates {
-		result[k] = v
-	}
+	applyUpdates(result, updates, false)
 
 	return result
 }
 
-// executeConditions checks all conditions for a transition
-func (sm *StateMachine) executeConditions(ctx context.Context, currentState, event string, transition *Transition, payload map[string]any) error {
-	for _, conditionName := range transition.Conditions {
-		condition, err := sm.registry.GetCondition(conditionName)
+// autoEventFor returns the transition's AutoEvent if all of its AutoEventConditions pass
+// against the data the transition produced, and "" otherwise. The transition has already
+// succeeded, so a condition that cannot be evaluated only suppresses the auto event.
+func (sm *StateMachine) autoEventFor(ctx context.Context, logger *slog.Logger, currentState, event string, transition *Transition, data map[string]any) string {
+	if transition.AutoEvent == "" {
+		return ""
+	}
+
+	// The data has changed since the guards ran, so the condition cache is bypassed
+	transitionContext := TransitionContext{Event: event, Target: transition.Target, From: currentState}
+	for _, conditionName := range transition.AutoEventConditions {
+		condition, err := sm.getCondition(conditionName)
+		if err != nil {
+			logger.Error("Auto event suppressed", "auto_event", transition.AutoEvent, "condition", conditionName, "error", err)
+			return ""
+		}
+
+		ok, err := sm.evaluateCondition(ctx, conditionName, condition, transitionContext, data, transition.ConditionArgs[conditionName])
+		if err != nil {
+			logger.Error("Auto event suppressed", "auto_event", transition.AutoEvent, "condition", conditionName, "error", err)
+			return ""
+		}
+		if !ok {
+			logger.Debug("Auto event suppressed", "auto_event", transition.AutoEvent, "condition", conditionName)
+			return ""
+		}
+	}
+
+	return transition.AutoEvent
+}
+
+// evaluateCondition runs a condition and records the outcome in the condition evaluation
+// metric and as an event on the transition span
+func (sm *StateMachine) evaluateCondition(ctx context.Context, conditionName string, condition conditionFunc, transition TransitionContext, payload map[string]any, args map[string]any) (bool, error) {
+	start := time.Now()
+	ok, err := condition(ctx, transition, payload, args)
+
+	result := "pass"
+	if err != nil {
+		result = "error"
+	} else if !ok {
+		result = "fail"
+	}
+
+	if sm.metrics != nil {
+		sm.metrics.ConditionEvaluationsTotal.WithLabelValues(conditionName, result).Inc()
+	}
+
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.AddEvent("fsm.condition", trace.WithAttributes(
+			attribute.String("fsm.condition.name", conditionName),
+			attribute.String("fsm.condition.result", result),
+			attribute.Float64("fsm.condition.duration_seconds", time.Since(start).Seconds()),
+		))
+	}
+
+	return ok, err
+}
+
+// executeConditions checks all conditions for a transition. It stops at the first that
+// fails unless WithCollectAllConditionFailures is set, in which case every condition runs
+// and the failures are returned together as a *ConditionFailures. With WithParallelConditions
+// they run concurrently, and their failures are reported in the same order.
+func (sm *StateMachine) executeConditions(ctx context.Context, logger *slog.Logger, currentState, event string, transition *Transition, payload map[string]any) error {
+	transitionContext := TransitionContext{Event: event, Target: transition.Target, From: currentState}
+	var concurrent []conditionResult
+	if sm.parallelConditions && len(transition.Conditions) > 1 {
+		var err error
+		if concurrent, err = sm.evaluateConditionsConcurrently(ctx, logger, currentState, event, transitionContext, transition, payload); err != nil {
+			return err
+		}
+	}
+
+	var failures []error
+	for i, conditionName := range transition.Conditions {
+		var ok bool
+		var err error
+		if concurrent != nil {
+			// Conditions canceled by another's failure say nothing about the transition
+			if concurrent[i].canceled {
+				continue
+			}
+			ok, err = concurrent[i].ok, concurrent[i].err
+		} else {
+			var condition conditionFunc
+			if condition, err = sm.getCondition(conditionName); err != nil {
+				err = fmt.Errorf("failed to get condition %s: %w", conditionName, err)
+				sm.recordTransitionError(currentState, event, "condition_not_found", err)
+				return err
+			}
+
+			logger.Debug("Evaluating condition", "condition", conditionName)
+			ok, err = sm.cachedCondition(ctx, conditionName, condition, transitionContext, payload, transition.ConditionArgs[conditionName])
+		}
 		if err != nil {
-			err = fmt.Errorf("failed to get condition %s: %w", conditionName, err)
+			err = withKind(fmt.Errorf("condition %s failed: %w", conditionName, err), errStepFailed)
+			sm.recordTransitionError(currentState, event, "condition_error", err)
+			logger.Error("Condition failed", "condition", conditionName, "error", err)
+			if !sm.collectAllConditionFailures {
+				return err
+			}
+			failures = append(failures, err)
+			continue
+		}
+
+		// A guard saying no is a business outcome rather than a fault, so it is logged quietly
+		if !ok {
+			err = withKind(fmt.Errorf("condition %s evaluated to false", conditionName), ErrTransitionNotFound, errGuardRejected)
+			if !sm.conditionFailureAsNonError {
+				sm.recordTransitionError(currentState, event, "condition_failed", err)
+			}
+			logger.Debug("Condition evaluated to false", "condition", conditionName)
+			if !sm.collectAllConditionFailures {
+				return err
+			}
+			failures = append(failures, err)
+			continue
+		}
+
+		logger.Debug("Condition passed", "condition", conditionName)
+	}
+
+	if len(failures) > 0 {
+		return &ConditionFailures{Errs: failures}
+	}
+	return nil
+}
+
+// executePreconditions enforces the preconditions of the transition the conditions selected.
+// A precondition evaluating to false is reported as an *ErrPreconditionFailed.
+func (sm *StateMachine) executePreconditions(ctx context.Context, logger *slog.Logger, currentState, event string, transition *Transition, payload map[string]any) error {
+	transitionContext := TransitionContext{Event: event, Target: transition.Target, From: currentState}
+	for _, conditionName := range transition.Preconditions {
+		condition, err := sm.getCondition(conditionName)
+		if err != nil {
+			err = fmt.Errorf("failed to get precondition %s: %w", conditionName, err)
 			sm.recordTransitionError(currentState, event, "condition_not_found", err)
 			return err
 		}
 
-		sm.logger.Info("Evaluating condition", "condition", conditionName)
-		ok, err := condition(ctx, payload)
+		logger.Debug("Evaluating precondition", "condition", conditionName)
+		ok, err := sm.cachedCondition(ctx, conditionName, condition, transitionContext, payload, transition.ConditionArgs[conditionName])
 		if err != nil {
-			err = fmt.Errorf("condition %s failed: %w", conditionName, err)
+			err = withKind(fmt.Errorf("precondition %s failed: %w", conditionName, err), errStepFailed)
 			sm.recordTransitionError(currentState, event, "condition_error", err)
+			logger.Error("Precondition failed", "condition", conditionName, "error", err)
 			return err
 		}
 
 		if !ok {
-			err = fmt.Errorf("condition %s evaluated to false", conditionName)
-			sm.recordTransitionError(currentState, event, "condition_failed", err)
-			sm.logger.Info("Condition evaluated to false", "condition", conditionName)
+			err = &ErrPreconditionFailed{State: currentState, Event: event, Precondition: conditionName}
+			sm.recordTransitionError(currentState, event, "precondition_failed", err)
+			logger.Info("Precondition evaluated to false", "condition", conditionName)
 			return err
 		}
-
-		sm.logger.Info("Condition passed", "condition", conditionName)
 	}
 	return nil
 }
 
 // executeTransitionActions executes transition actions
-func (sm *StateMachine) executeTransitionActions(ctx context.Context, currentState, event string, actions []string, payload map[string]any, persistenceData map[string]any) error {
+func (sm *StateMachine) executeTransitionActions(ctx context.Context, logger *slog.Logger, currentState, event string, actions []string, payload map[string]any, persistenceData map[string]any) error {
 	for _, actionName := range actions {
-		action, err := sm.registry.GetAction(actionName)
+		if err := sm.checkCancelled(ctx, currentState, event, "transition", actionName); err != nil {
+			return err
+		}
+
+		action, err := sm.getAction(actionName)
 		if err != nil {
 			err = fmt.Errorf("failed to get transition action %s: %w", actionName, err)
 			sm.recordTransitionError(currentState, event, "transition_action_not_found", err)
 			return err
 		}
 
-		sm.logger.Info("Executing transition action", "action", actionName)
+		logger.Debug("Executing transition action", "action", actionName)
+		start := time.Now()
 		result, err := action(ctx, payload)
+		sm.observeAction(ctx, "transition", actionName, start, err)
+		if errors.Is(err, ErrAbortTransition) {
+			return sm.abortTransition(logger, currentState, event, actionName, err)
+		}
 		if err != nil {
-			err = fmt.Errorf("transition action %s failed: %w", actionName, err)
+			err = withKind(fmt.Errorf("transition action %s failed: %w", actionName, err), errStepFailed)
 			sm.recordTransitionError(currentState, event, "transition_action_error", err)
 			return err
 		}
 
-		// Update persistenceData with result
-		if result != nil {
-			for k, v := range result {
-				persistenceData[k] = v
-			}
-			sm.logger.Info("Transition action updated persistenceData", "action", actionName, "updates", result)
+		if err := sm.mergeActionResult(ctx, logger, currentState, event, "transition", actionName, result, persistenceData); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 // executeOnLeaveActions executes OnLeave actions for the current state
-func (sm *StateMachine) executeOnLeaveActions(ctx context.Context, currentState, event string, actions []string, payload map[string]any, persistenceData map[string]any) error {
+func (sm *StateMachine) executeOnLeaveActions(ctx context.Context, logger *slog.Logger, currentState, event string, actions []string, payload map[string]any, persistenceData map[string]any) error {
 	for _, actionName := range actions {
-		action, err := sm.registry.GetAction(actionName)
+		if err := sm.checkCancelled(ctx, currentState, event, "OnLeave", actionName); err != nil {
+			return err
+		}
+
+		action, err := sm.getAction(actionName)
 		if err != nil {
 			err = fmt.Errorf("failed to get OnLeave action %s: %w", actionName, err)
 			sm.recordTransitionError(currentState, event, "onleave_action_not_found", err)
 			return err
 		}
 
-		sm.logger.Info("Executing OnLeave action", "action", actionName)
+		logger.Debug("Executing OnLeave action", "action", actionName)
+		start := time.Now()
 		result, err := action(ctx, payload)
+		sm.observeAction(ctx, "onleave", actionName, start, err)
+		if errors.Is(err, ErrAbortTransition) {
+			return sm.abortTransition(logger, currentState, event, actionName, err)
+		}
 		if err != nil {
-			err = fmt.Errorf("OnLeave action %s failed: %w", actionName, err)
+			err = withKind(fmt.Errorf("OnLeave action %s failed: %w", actionName, err), errStepFailed)
 			sm.recordTransitionError(currentState, event, "onleave_action_error", err)
 			return err
 		}
 
-		// Update persistenceData with result
-		if result != nil {
-			for k, v := range result {
-				persistenceData[k] = v
-			}
-			sm.logger.Info("OnLeave action updated persistenceData", "action", actionName, "updates", result)
+		if err := sm.mergeActionResult(ctx, logger, currentState, event, "OnLeave", actionName, result, persistenceData); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 // executeOnEnterActions executes OnEnter actions for the target state
-func (sm *StateMachine) executeOnEnterActions(ctx context.Context, currentState, event, targetState string, actions []string, payload map[string]any, persistenceData map[string]any) error {
+func (sm *StateMachine) executeOnEnterActions(ctx context.Context, logger *slog.Logger, currentState, event, targetState string, actions []string, payload map[string]any, persistenceData map[string]any) error {
+	if sm.visitCounting {
+		if err := recordVisit(persistenceData, targetState); err != nil {
+			err = fmt.Errorf("failed to count visit to %s: %w", targetState, err)
+			sm.recordTransitionError(currentState, event, "visit_count_error", err)
+			return err
+		}
+		// OnEnter actions see the count of the entry they are running for
+		payload[VisitsKey] = persistenceData[VisitsKey]
+	}
+	return sm.runOnEnterActions(ctx, logger, currentState, event, actions, payload, persistenceData)
+}
+
+// runOnEnterActions runs OnEnter actions without counting a visit
+func (sm *StateMachine) runOnEnterActions(ctx context.Context, logger *slog.Logger, currentState, event string, actions []string, payload map[string]any, persistenceData map[string]any) error {
 	for _, actionName := range actions {
-		action, err := sm.registry.GetAction(actionName)
+		if err := sm.checkCancelled(ctx, currentState, event, "OnEnter", actionName); err != nil {
+			return err
+		}
+
+		action, err := sm.getAction(actionName)
 		if err != nil {
 			err = fmt.Errorf("failed to get OnEnter action %s: %w", actionName, err)
 			sm.recordTransitionError(currentState, event, "onenter_action_not_found", err)
 			return err
 		}
 
-		sm.logger.Info("Executing OnEnter action", "action", actionName)
+		logger.Debug("Executing OnEnter action", "action", actionName)
+		start := time.Now()
 		result, err := action(ctx, payload)
+		sm.observeAction(ctx, "onenter", actionName, start, err)
+		if errors.Is(err, ErrAbortTransition) {
+			return sm.abortTransition(logger, currentState, event, actionName, err)
+		}
 		if err != nil {
-			err = fmt.Errorf("OnEnter action %s failed: %w", actionName, err)
+			err = withKind(fmt.Errorf("OnEnter action %s failed: %w", actionName, err), errStepFailed)
 			sm.recordTransitionError(currentState, event, "onenter_action_error", err)
 			return err
 		}
 
-		// Update persistenceData with result
-		if result != nil {
-			for k, v := range result {
-				persistenceData[k] = v
-			}
-			sm.logger.Info("OnEnter action updated persistenceData", "action", actionName, "updates", result)
+		if err := sm.mergeActionResult(ctx, logger, currentState, event, "OnEnter", actionName, result, persistenceData); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// executeOnErrorActions runs a transition's OnError actions after one of its actions failed,
+// including when it vetoed the transition with ErrAbortTransition, as the actions before it
+// may have done work that needs undoing. Each action receives the partially updated
+// persistenceData and can read the failure with TransitionErrorFromContext. All actions run
+// even if some fail; the original error is returned, joined with any compensation errors.
+func (sm *StateMachine) executeOnErrorActions(ctx context.Context, logger *slog.Logger, currentState, event string, actions []string, cause error, persistenceData map[string]any) error {
+	ctx = context.WithValue(ctx, transitionErrorKey, cause)
+
+	errs := []error{cause}
+	for _, actionName := range actions {
+		action, err := sm.getAction(actionName)
+		if err != nil {
+			err = fmt.Errorf("failed to get OnError action %s: %w", actionName, err)
+			sm.recordTransitionError(currentState, event, "onerror_action_not_found", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		logger.Info("Executing OnError action", "action", actionName, "cause", cause)
+		if _, err := action(ctx, persistenceData); err != nil {
+			err = fmt.Errorf("OnError action %s failed: %w", actionName, err)
+			sm.recordTransitionError(currentState, event, "onerror_action_error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 1 {
+		return cause
+	}
+	return errors.Join(errs...)
+}
+
 // recordTransitionError records a transition error in metrics
 func (sm *StateMachine) recordTransitionError(fromState, event, errorType string, err error) {
 	if sm.metrics != nil {
@@ -421,11 +1335,75 @@ func (sm *StateMachine) recordTransitionError(fromState, event, errorType string
 	}
 }
 
+// rollbackEntry restores the original state after an OnEnter action of the target failed.
+// It runs the state's OnReenter actions, or its OnEnter actions if it declares none, on a
+// copy of the original payload, which is returned with their updates. Restoration runs
+// even if ctx has been cancelled.
+func (sm *StateMachine) rollbackEntry(ctx context.Context, logger *slog.Logger, state *State, currentState, event, targetState string, payload map[string]any, cause error) (map[string]any, error) {
+	restoreData := make(map[string]any, len(payload))
+	for k, v := range payload {
+		restoreData[k] = v
+	}
+
+	restore := state.OnReenter
+	// Re-running the OnEnter that just failed would not restore anything on a self-transition
+	if len(restore) == 0 && targetState != currentState {
+		restore = state.OnEnter
+	}
+
+	logger.Warn("OnEnter failed, restoring original state", "target", targetState, "error", cause)
+	// Restoring the original state is not a new visit
+	restoreErr := sm.runOnEnterActions(context.WithoutCancel(ctx), logger, currentState, event, restore, restoreData, restoreData)
+
+	return restoreData, &ErrEnterRolledBack{
+		State:      currentState,
+		Target:     targetState,
+		Err:        cause,
+		RestoreErr: restoreErr,
+	}
+}
+
+// checkCancelled returns an error naming the phase if ctx is done before the next action runs,
+// so a long sequence of fast actions can still be interrupted
+func (sm *StateMachine) checkCancelled(ctx context.Context, currentState, event, phase, actionName string) error {
+	select {
+	case <-ctx.Done():
+		err := fmt.Errorf("%s actions cancelled before action %s: %w", phase, actionName, ctx.Err())
+		sm.recordTransitionError(currentState, event, "cancelled", err)
+		return err
+	default:
+		return nil
+	}
+}
+
+// observeAction records how long an action invoked in the given phase took, in the action
+// duration metric and as an event on the transition span
+func (sm *StateMachine) observeAction(ctx context.Context, phase, actionName string, start time.Time, err error) {
+	duration := time.Since(start).Seconds()
+	if sm.metrics != nil {
+		sm.metrics.ActionDuration.WithLabelValues(phase, actionName).Observe(duration)
+	}
+
+	// Building the attributes is skipped entirely when tracing is off
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		attrs := []attribute.KeyValue{
+			attribute.String("fsm.action.phase", phase),
+			attribute.String("fsm.action.name", actionName),
+			attribute.Float64("fsm.action.duration_seconds", duration),
+		}
+		if err != nil {
+			attrs = append(attrs, attribute.String("fsm.action.error", err.Error()))
+		}
+		span.AddEvent("fsm.action", trace.WithAttributes(attrs...))
+	}
+}
+
 // ReturnToPreviousStateAction is a predefined action that pops the top state from the WorkflowStack
-// and returns it as the __next_state_override
+// and routes the transition to it. When called outside of Trigger, the popped state is returned
+// under the deprecated __next_state_override key instead.
 func ReturnToPreviousStateAction(ctx context.Context, data map[string]any) (map[string]any, error) {
 	// Get the workflow stack from the context
-	workflowStack, ok := data["WorkflowStack"].([]string)
+	workflowStack, ok := data[WorkflowStackKey].([]string)
 	if !ok || len(workflowStack) == 0 {
 		return nil, fmt.Errorf("workflow stack not found or empty")
 	}
@@ -434,9 +1412,12 @@ func ReturnToPreviousStateAction(ctx context.Context, data map[string]any) (map[
 	returnState := workflowStack[len(workflowStack)-1]
 	workflowStack = workflowStack[:len(workflowStack)-1]
 
-	// Return the popped state as the next state override and updated stack
-	return map[string]any{
-		"__next_state_override": returnState,
-		"WorkflowStack":         workflowStack,
-	}, nil
+	result := map[string]any{
+		WorkflowStackKey: workflowStack,
+	}
+	if err := SetNextState(ctx, returnState); err != nil {
+		result[NextStateOverrideKey] = returnState
+	}
+
+	return result, nil
 }
@@ -2,7 +2,10 @@ package machina
 
 import (
 	"context"
+	"io"
+	"log/slog"
 	"testing"
+	"time"
 )
 
 func BenchmarkStateMachine_Trigger(b *testing.B) {
@@ -90,3 +93,101 @@ func BenchmarkStateMachine_Trigger_WithGuards(b *testing.B) {
 		}
 	}
 }
+
+// routingDefinition returns a workflow whose "route" transition runs no actions, and whose
+// "process" transition runs one
+func routingDefinition() *WorkflowDefinition {
+	return &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "route", Target: "end"},
+					{Event: "process", Target: "end", Actions: []string{"noOpAction"}},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+}
+
+// benchmarkPayload returns a payload the size of a typical order
+func benchmarkPayload() map[string]any {
+	payload := make(map[string]any)
+	for _, key := range []string{"orderID", "customerID", "amount", "currency", "items", "address", "email", "channel"} {
+		payload[key] = key
+	}
+	return payload
+}
+
+func BenchmarkStateMachine_Trigger_RoutingOnly(b *testing.B) {
+	fsm := NewStateMachine(routingDefinition(), NewRegistry(), nil)
+	payload := benchmarkPayload()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fsm.Trigger(context.Background(), "start", "route", payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStateMachine_Trigger_WithAction(b *testing.B) {
+	registry := NewRegistry()
+	registry.RegisterAction("noOpAction", MockNoOpAction)
+	fsm := NewStateMachine(routingDefinition(), registry, nil)
+	payload := benchmarkPayload()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fsm.Trigger(context.Background(), "start", "process", payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkSlowConditions measures a transition guarded by three conditions that each
+// wait on an external service for 100ms
+func benchmarkSlowConditions(b *testing.B, opts ...StateMachineOption) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end", Conditions: []string{"isUserValid", "hasCredit", "notBlocked"}},
+				},
+			},
+			"end": {Name: "end"},
+		},
+	}
+
+	slow := func(ctx context.Context, data map[string]any) (bool, error) {
+		time.Sleep(100 * time.Millisecond)
+		return true, nil
+	}
+	registry := NewRegistry()
+	registry.RegisterCondition("isUserValid", slow)
+	registry.RegisterCondition("hasCredit", slow)
+	registry.RegisterCondition("notBlocked", slow)
+	fsm := NewStateMachine(definition, registry, slog.New(slog.NewTextHandler(io.Discard, nil)), opts...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fsm.Trigger(context.Background(), "start", "proceed", map[string]any{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStateMachine_Trigger_SlowConditions(b *testing.B) {
+	b.Run("Sequential", func(b *testing.B) {
+		benchmarkSlowConditions(b)
+	})
+	b.Run("Parallel", func(b *testing.B) {
+		benchmarkSlowConditions(b, WithParallelConditions())
+	})
+}
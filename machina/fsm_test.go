@@ -2,8 +2,15 @@ package machina
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"math/rand"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -181,6 +188,95 @@ func TestStateMachine_Trigger_SuccessCases(t *testing.T) {
 				PersistenceData: map[string]any{},
 			},
 		},
+		{
+			name: "DynamicTransitionWithSetNextState",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name: "start",
+						Transitions: []Transition{
+							{
+								Event:  "proceed",
+								Target: "end", // This will be overridden by the action
+								Conditions: []string{
+									"alwaysTrue",
+								},
+								Actions: []string{
+									"routeAction",
+								},
+							},
+						},
+					},
+					"intermediate": {
+						Name: "intermediate",
+					},
+					"end": {
+						Name: "end",
+					},
+				},
+			},
+			registrySetup: func(r *Registry) {
+				r.RegisterCondition("alwaysTrue", MockTrueCondition)
+				r.RegisterAction("routeAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+					return nil, SetNextState(ctx, "intermediate")
+				})
+			},
+			currentState: "start",
+			event:        "proceed",
+			payload:      map[string]any{},
+			expectedResult: &TransitionResult{
+				NewState:        "intermediate",
+				AutoEvent:       "",
+				PersistenceData: map[string]any{},
+			},
+		},
+		{
+			name: "SetNextStateTakesPrecedenceOverLegacyKey",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name: "start",
+						Transitions: []Transition{
+							{
+								Event:  "proceed",
+								Target: "end",
+								Actions: []string{
+									"routeAction",
+									"legacyAction",
+								},
+							},
+						},
+					},
+					"intermediate": {
+						Name: "intermediate",
+					},
+					"legacy": {
+						Name: "legacy",
+					},
+					"end": {
+						Name: "end",
+					},
+				},
+			},
+			registrySetup: func(r *Registry) {
+				r.RegisterAction("routeAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+					return nil, SetNextState(ctx, "intermediate")
+				})
+				r.RegisterAction("legacyAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+					return map[string]any{
+						"__next_state_override": "legacy",
+					}, nil
+				})
+			},
+			currentState: "start",
+			event:        "proceed",
+			payload:      map[string]any{},
+			expectedResult: &TransitionResult{
+				NewState:        "intermediate",
+				AutoEvent:       "",
+				PersistenceData: map[string]any{},
+			},
+		},
 		{
 			name: "TransitionToSideQuestState",
 			definition: &WorkflowDefinition{
@@ -472,7 +568,7 @@ func TestStateMachine_Trigger_ActionErrorCases(t *testing.T) {
 			event:         "proceed",
 			payload:       map[string]any{},
 			expectError:   true,
-			errorContains: "OnEnter action errorAction failed: action error",
+			errorContains: "entering state end failed, remaining in state start: OnEnter action errorAction failed: action error",
 		},
 		// This test case is removed because it's complex to simulate correctly in a single Trigger call
 		// The ReturnToPreviousStateAction functionality is tested in the mocks_test.go file
@@ -554,6 +650,303 @@ func TestStateMachine_Trigger_ContextTimeout(t *testing.T) {
 	}
 }
 
+func TestStateMachine_Trigger_CancelledBetweenActions(t *testing.T) {
+	tests := []struct {
+		name          string
+		onLeave       []string
+		actions       []string
+		onEnter       []string
+		expectedError string
+	}{
+		{
+			name:          "TransitionActions",
+			actions:       []string{"cancelAction", "secondAction"},
+			expectedError: "transition actions cancelled before action secondAction: context canceled",
+		},
+		{
+			name:          "OnLeaveActions",
+			onLeave:       []string{"cancelAction", "secondAction"},
+			expectedError: "OnLeave actions cancelled before action secondAction: context canceled",
+		},
+		{
+			name:          "OnEnterActions",
+			onEnter:       []string{"cancelAction", "secondAction"},
+			expectedError: "entering state end failed, remaining in state start: OnEnter actions cancelled before action secondAction: context canceled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition := &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name:    "start",
+						OnLeave: tt.onLeave,
+						Transitions: []Transition{
+							{Event: "proceed", Target: "end", Actions: tt.actions},
+						},
+					},
+					"end": {
+						Name:    "end",
+						OnEnter: tt.onEnter,
+					},
+				},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var secondRan bool
+			registry := NewRegistry()
+			registry.RegisterAction("cancelAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+				// Fast action that doesn't watch ctx itself
+				cancel()
+				return nil, nil
+			})
+			registry.RegisterAction("secondAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+				secondRan = true
+				return nil, nil
+			})
+
+			fsm := NewStateMachine(definition, registry, nil)
+
+			_, err := fsm.Trigger(ctx, "start", "proceed", map[string]any{})
+			if err == nil || err.Error() != tt.expectedError {
+				t.Fatalf("Expected error '%s', got %v", tt.expectedError, err)
+			}
+
+			if !errors.Is(err, context.Canceled) {
+				t.Error("Expected error to wrap context.Canceled")
+			}
+
+			if secondRan {
+				t.Error("Expected second action not to run after cancellation")
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_OnEnterRollback(t *testing.T) {
+	tests := []struct {
+		name            string
+		onReenter       []string
+		expectedRestore string
+		expectedError   string
+	}{
+		{
+			name:            "OnReenter",
+			onReenter:       []string{"reenterAction"},
+			expectedRestore: "reenter",
+			expectedError:   "entering state end failed, remaining in state start: OnEnter action errorAction failed: action error",
+		},
+		{
+			name:            "FallsBackToOnEnter",
+			expectedRestore: "enter",
+			expectedError:   "entering state end failed, remaining in state start: OnEnter action errorAction failed: action error",
+		},
+		{
+			name:          "RestoreFails",
+			onReenter:     []string{"restoreErrorAction"},
+			expectedError: "entering state end failed, remaining in state start: OnEnter action errorAction failed: action error; restoring state start also failed: OnEnter action restoreErrorAction failed: action error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition := &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name:      "start",
+						OnEnter:   []string{"enterAction"},
+						OnReenter: tt.onReenter,
+						Transitions: []Transition{
+							{Event: "proceed", Target: "end", Actions: []string{PushCurrentStateActionName, "updateAction"}},
+							{Event: "retry", Target: "retried"},
+						},
+					},
+					"end": {
+						Name:    "end",
+						OnEnter: []string{"errorAction"},
+					},
+					"retried": {
+						Name: "retried",
+					},
+				},
+			}
+
+			registry := NewRegistry()
+			registry.RegisterAction("updateAction", MockUpdateAction)
+			registry.RegisterAction("errorAction", MockErrorAction)
+			registry.RegisterAction("restoreErrorAction", MockErrorAction)
+			registry.RegisterAction("enterAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+				return map[string]any{"restoredBy": "enter"}, nil
+			})
+			registry.RegisterAction("reenterAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+				return map[string]any{"restoredBy": "reenter"}, nil
+			})
+
+			fsm := NewStateMachine(definition, registry, nil)
+
+			result, err := fsm.Trigger(context.Background(), "start", "proceed", map[string]any{"orderID": 7})
+			if err == nil || err.Error() != tt.expectedError {
+				t.Fatalf("Expected error '%s', got %v", tt.expectedError, err)
+			}
+
+			var rolledBack *ErrEnterRolledBack
+			if !errors.As(err, &rolledBack) || rolledBack.State != "start" || rolledBack.Target != "end" {
+				t.Fatalf("Expected *ErrEnterRolledBack from start to end, got %v", err)
+			}
+
+			if result == nil || result.NewState != "start" {
+				t.Fatalf("Expected result remaining in start, got %v", result)
+			}
+
+			if tt.expectedRestore != "" && result.PersistenceData["restoredBy"] != tt.expectedRestore {
+				t.Errorf("Expected state restored by %s, got %v", tt.expectedRestore, result.PersistenceData["restoredBy"])
+			}
+
+			// Updates made on the way to the failed state are discarded
+			if _, exists := result.PersistenceData[WorkflowStackKey]; exists {
+				t.Errorf("Expected workflow stack push to be discarded, got %v", result.PersistenceData)
+			}
+			if _, exists := result.PersistenceData["updated"]; exists {
+				t.Errorf("Expected transition action updates to be discarded, got %v", result.PersistenceData)
+			}
+			if result.PersistenceData["orderID"] != 7 {
+				t.Errorf("Expected original payload to be kept, got %v", result.PersistenceData)
+			}
+
+			// The returned data can be used to trigger from the original state again
+			retried, err := fsm.Trigger(context.Background(), result.NewState, "retry", result.PersistenceData)
+			if err != nil {
+				t.Fatalf("Expected re-trigger to succeed, got %v", err)
+			}
+			if retried.NewState != "retried" {
+				t.Errorf("Expected state retried, got %s", retried.NewState)
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_StagedPersistenceData(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"cart": {
+				Name:        "cart",
+				OnLeave:     []string{"releaseCart"},
+				Transitions: []Transition{{Event: "checkout", Target: "paid", Actions: []string{"charge"}}},
+			},
+			"paid": {
+				Name:    "paid",
+				OnEnter: []string{"sendReceipt", "scheduleShipping"},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("releaseCart", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		// Changing the input in place must not reach the caller
+		data["cartReleased"] = true
+		delete(data, "items")
+		return map[string]any{"releasedAt": "now"}, nil
+	})
+	registry.RegisterAction("charge", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"charged": true}, nil
+	})
+	registry.RegisterAction("sendReceipt", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"receiptSent": true}, nil
+	})
+	registry.RegisterAction("scheduleShipping", MockErrorAction)
+
+	fsm := NewStateMachine(definition, registry, nil)
+
+	payload := map[string]any{"orderID": 7, "items": 3}
+	result, err := fsm.Trigger(context.Background(), "cart", "checkout", payload)
+
+	var rolledBack *ErrEnterRolledBack
+	if !errors.As(err, &rolledBack) {
+		t.Fatalf("Expected *ErrEnterRolledBack, got %v", err)
+	}
+
+	expected := map[string]any{"orderID": 7, "items": 3}
+	if result == nil || !maps.Equal(result.PersistenceData, expected) {
+		t.Errorf("Expected no partial updates in %v, got %v", expected, result)
+	}
+
+	if !maps.Equal(payload, expected) {
+		t.Errorf("Expected payload to be left untouched, got %v", payload)
+	}
+}
+
+func TestStateMachine_Trigger_SelfTransitions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"editing": {
+				Name:    "editing",
+				OnEnter: []string{"enter"},
+				OnLeave: []string{"leave"},
+				Transitions: []Transition{
+					{Event: "reload", Target: "editing", Actions: []string{"act"}},
+					{Event: "save", Target: "editing", Actions: []string{"act"}, Internal: true},
+					{Event: "reroute", Target: "editing", Actions: []string{"reroute"}, Internal: true},
+				},
+			},
+			"other": {Name: "other"},
+		},
+	}
+
+	var calls []string
+	record := func(name string) ActionFunc {
+		return func(ctx context.Context, data map[string]any) (map[string]any, error) {
+			calls = append(calls, name)
+			return nil, nil
+		}
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("enter", record("enter"))
+	registry.RegisterAction("leave", record("leave"))
+	registry.RegisterAction("act", record("act"))
+	registry.RegisterAction("reroute", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, SetNextState(ctx, "other")
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+
+	tests := []struct {
+		name          string
+		event         string
+		expectedCalls []string
+		expectedError string
+	}{
+		{name: "ExternalRunsLeaveAndEnter", event: "reload", expectedCalls: []string{"act", "leave", "enter"}},
+		{name: "InternalSkipsLeaveAndEnter", event: "save", expectedCalls: []string{"act"}},
+		{name: "InternalCannotBeRerouted", event: "reroute", expectedError: "internal transition for event reroute must stay in state editing, got target other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			result, err := fsm.Trigger(context.Background(), "editing", tt.event, map[string]any{})
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.NewState != "editing" {
+				t.Errorf("Expected new state to be 'editing', got '%s'", result.NewState)
+			}
+			if !slices.Equal(calls, tt.expectedCalls) {
+				t.Errorf("Expected calls %v, got %v", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
 func TestStateMachine_Trigger_ResourceNotFoundCases(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -617,7 +1010,7 @@ func TestStateMachine_Trigger_ResourceNotFoundCases(t *testing.T) {
 			event:         "nonexistent",
 			payload:       map[string]any{},
 			expectError:   true,
-			errorContains: "no valid transition found for event nonexistent in state start: no transition found for event nonexistent",
+			errorContains: "no valid transition found for event nonexistent in state start: no transition for 'nonexistent' in state start (did you mean: proceed?)",
 		},
 		{
 			name: "ConditionNotFound",
@@ -737,7 +1130,7 @@ func TestStateMachine_Trigger_ResourceNotFoundCases(t *testing.T) {
 			event:         "proceed",
 			payload:       map[string]any{},
 			expectError:   true,
-			errorContains: "failed to get OnEnter action nonexistent: action nonexistent not found",
+			errorContains: "entering state end failed, remaining in state start: failed to get OnEnter action nonexistent: action nonexistent not found",
 		},
 	}
 
@@ -1018,6 +1411,7 @@ func TestGetTransitionForEvent(t *testing.T) {
 		{
 			name: "NoTransitionForEvent",
 			state: &State{
+				Name: "start",
 				Transitions: []Transition{
 					{
 						Event:  "event1",
@@ -1027,57 +1421,265 @@ func TestGetTransitionForEvent(t *testing.T) {
 			},
 			event:         "event2",
 			expectError:   true,
-			errorContains: "no transition found for event event2",
+			errorContains: "no transition for 'event2' in state start (did you mean: event1?)",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			ctx := context.Background()
-			payload := map[string]any{}
-
-			transition, err := fsm.getTransitionForEvent(tt.state, tt.event, ctx, payload)
-
-			if tt.expectError {
-				if err == nil {
-					t.Error("Expected error, got nil")
-				} else if tt.errorContains != "" && err.Error() != tt.errorContains {
-					t.Errorf("Expected error containing '%s', got '%s'", tt.errorContains, err.Error())
-				}
-				return
-			}
-
-			if err != nil {
-				t.Errorf("Expected no error, got %v", err)
-				return
-			}
-
-			if transition == nil {
-				t.Error("Expected transition, got nil")
-				return
-			}
-
-			expectedTransition := &tt.state.Transitions[tt.expectedIndex]
-			if transition.Event != expectedTransition.Event {
-				t.Errorf("Expected transition event to be '%s', got '%s'", expectedTransition.Event, transition.Event)
-			}
-
-			if transition.Target != expectedTransition.Target {
-				t.Errorf("Expected transition target to be '%s', got '%s'", expectedTransition.Target, transition.Target)
-			}
-		})
-	}
-}
-
-func TestNewStateMachine_InvalidDefinition(t *testing.T) {
-	// Create an invalid workflow definition (empty states)
-	invalidDefinition := &WorkflowDefinition{
-		States: map[string]State{},
-	}
-
-	// Create a registry
-	registry := NewRegistry()
-
+		{
+			name: "WildcardMatchesUnhandledEvent",
+			state: &State{
+				Transitions: []Transition{
+					{
+						Event:  "event1",
+						Target: "target1",
+					},
+					{
+						Event:  WildcardEvent,
+						Target: "unhandled",
+					},
+				},
+			},
+			event:         "event2",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name: "ExactMatchWinsOverEarlierWildcard",
+			state: &State{
+				Transitions: []Transition{
+					{
+						Event:  WildcardEvent,
+						Target: "unhandled",
+					},
+					{
+						Event:  "event1",
+						Target: "target1",
+					},
+				},
+			},
+			event:         "event1",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name: "WildcardNotUsedWhenExactConditionsFail",
+			state: &State{
+				Transitions: []Transition{
+					{
+						Event:      "event1",
+						Target:     "target1",
+						Conditions: []string{"condition2"},
+					},
+					{
+						Event:      "event1",
+						Target:     "target2",
+						Conditions: []string{"condition2"},
+					},
+					{
+						Event:  WildcardEvent,
+						Target: "unhandled",
+					},
+				},
+			},
+			event:         "event1",
+			expectError:   true,
+			errorContains: "no transition found for event event1 with matching conditions",
+		},
+		{
+			name: "ConditionalWildcards",
+			state: &State{
+				Transitions: []Transition{
+					{
+						Event:      WildcardEvent,
+						Target:     "target1",
+						Conditions: []string{"condition2"},
+					},
+					{
+						Event:      WildcardEvent,
+						Target:     "target2",
+						Conditions: []string{"condition3"},
+					},
+				},
+			},
+			event:         "anything",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name: "PrefixMatch",
+			state: &State{
+				Transitions: []Transition{
+					{Event: "webhook.order.", Match: MatchPrefix, Target: "target1"},
+					{Event: "webhook.payment.", Match: MatchPrefix, Target: "target2"},
+				},
+			},
+			event:         "webhook.payment.succeeded",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name: "RegexMatchesWholeEvent",
+			state: &State{
+				Name: "start",
+				Transitions: []Transition{
+					{Event: `payment\.(succeeded|failed)`, Match: MatchRegex, Target: "target1"},
+				},
+			},
+			event:         "payment.failed.retry",
+			expectError:   true,
+			errorContains: "no transition for 'payment.failed.retry' in state start",
+		},
+		{
+			name: "ExactMatchWinsOverPrefix",
+			state: &State{
+				Transitions: []Transition{
+					{Event: "webhook.", Match: MatchPrefix, Target: "target1"},
+					{Event: "webhook.ping", Target: "target2"},
+				},
+			},
+			event:         "webhook.ping",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name: "PrefixMatchWinsOverRegex",
+			state: &State{
+				Transitions: []Transition{
+					{Event: `webhook\..*`, Match: MatchRegex, Target: "target1"},
+					{Event: "webhook.", Match: MatchPrefix, Target: "target2"},
+				},
+			},
+			event:         "webhook.ping",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name: "RegexMatchWinsOverWildcard",
+			state: &State{
+				Transitions: []Transition{
+					{Event: WildcardEvent, Target: "unhandled"},
+					{Event: `webhook\.[a-z]+`, Match: MatchRegex, Target: "target1"},
+				},
+			},
+			event:         "webhook.ping",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name: "WildcardWhenNoPatternMatches",
+			state: &State{
+				Transitions: []Transition{
+					{Event: "webhook.", Match: MatchPrefix, Target: "target1"},
+					{Event: `webhook\.[a-z]+`, Match: MatchRegex, Target: "target2"},
+					{Event: WildcardEvent, Target: "unhandled"},
+				},
+			},
+			event:         "cron.tick",
+			expectedIndex: 2,
+			expectError:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			payload := map[string]any{}
+
+			transition, index, err := fsm.getTransitionForEvent(tt.state, tt.event, ctx, payload)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				} else if tt.errorContains != "" && err.Error() != tt.errorContains {
+					t.Errorf("Expected error containing '%s', got '%s'", tt.errorContains, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+
+			if transition == nil {
+				t.Error("Expected transition, got nil")
+				return
+			}
+
+			if index != tt.expectedIndex {
+				t.Errorf("Expected transition index %d, got %d", tt.expectedIndex, index)
+			}
+
+			expectedTransition := &tt.state.Transitions[tt.expectedIndex]
+			if transition.Event != expectedTransition.Event {
+				t.Errorf("Expected transition event to be '%s', got '%s'", expectedTransition.Event, transition.Event)
+			}
+
+			if transition.Target != expectedTransition.Target {
+				t.Errorf("Expected transition target to be '%s', got '%s'", expectedTransition.Target, transition.Target)
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_WeightedTransitions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"router": {
+				Name: "router",
+				Transitions: []Transition{
+					{Event: "route", Target: "stable", Weight: 80},
+					{Event: "route", Target: "canary", Weight: 20},
+					{Event: "route", Target: "disabled", Weight: 50, Conditions: []string{"isFalse"}},
+					{Event: "fallback", Target: "disabled", Weight: 50, Conditions: []string{"isFalse"}},
+					{Event: "fallback", Target: "stable"},
+				},
+			},
+			"stable":   {Name: "stable"},
+			"canary":   {Name: "canary"},
+			"disabled": {Name: "disabled"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isFalse", MockFalseCondition)
+
+	fsm := NewStateMachine(definition, registry, slog.New(slog.NewTextHandler(io.Discard, nil)), WithRandomSource(rand.NewSource(42)))
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		result, err := fsm.Trigger(context.Background(), "router", "route", map[string]any{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		counts[result.NewState]++
+	}
+
+	if counts["disabled"] != 0 {
+		t.Errorf("Expected transitions with failing conditions never to be picked, got %d", counts["disabled"])
+	}
+
+	if counts["stable"] < 750 || counts["stable"] > 850 || counts["stable"]+counts["canary"] != 1000 {
+		t.Errorf("Expected roughly an 80/20 split, got %v", counts)
+	}
+
+	// With no weighted transition passing, the unweighted one applies
+	result, err := fsm.Trigger(context.Background(), "router", "fallback", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "stable" {
+		t.Errorf("Expected fallback to stable, got %s", result.NewState)
+	}
+}
+
+func TestNewStateMachine_InvalidDefinition(t *testing.T) {
+	// Create an invalid workflow definition (empty states)
+	invalidDefinition := &WorkflowDefinition{
+		States: map[string]State{},
+	}
+
+	// Create a registry
+	registry := NewRegistry()
+
 	// Create a logger
 	logger := slog.Default()
 
@@ -1089,3 +1691,850 @@ func TestNewStateMachine_InvalidDefinition(t *testing.T) {
 		t.Error("Expected state machine to be nil for invalid definition")
 	}
 }
+
+func TestStateMachine_IsTerminal(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "proceed",
+						Target: "end",
+					},
+				},
+			},
+			"end": {
+				Name:    "end",
+				IsFinal: true,
+			},
+		},
+	}
+
+	fsm := NewStateMachine(definition, NewRegistry(), nil)
+
+	if fsm.IsTerminal("start") {
+		t.Error("Expected start not to be terminal")
+	}
+
+	if !fsm.IsTerminal("end") {
+		t.Error("Expected end to be terminal")
+	}
+
+	if fsm.IsTerminal("nonexistent") {
+		t.Error("Expected unknown state not to be terminal")
+	}
+}
+
+func TestStateMachine_CanTrigger(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end"},
+					{Event: "guarded", Target: "end", Conditions: []string{"alwaysFalse"}},
+					{Event: "choice", Target: "end", Conditions: []string{"alwaysFalse"}},
+					{Event: "choice", Target: "start", Conditions: []string{"alwaysTrue"}},
+					{Event: "broken", Target: "end", Conditions: []string{"errorCondition"}},
+					{Event: "back", Target: "end", Actions: []string{ReturnToPreviousStateActionName}},
+				},
+			},
+			"end": {
+				Name:    "end",
+				IsFinal: true,
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("alwaysTrue", MockTrueCondition)
+	registry.RegisterCondition("alwaysFalse", MockFalseCondition)
+	registry.RegisterCondition("errorCondition", MockErrorCondition)
+
+	tests := []struct {
+		name          string
+		state         string
+		event         string
+		opts          []StateMachineOption
+		expected      bool
+		expectedError error
+	}{
+		{name: "Unconditional", state: "start", event: "proceed", expected: true},
+		{name: "GuardRejects", state: "start", event: "guarded", expected: false},
+		{name: "OneOfSeveralPasses", state: "start", event: "choice", expected: true},
+		{name: "UnknownEvent", state: "start", event: "missing", expected: false},
+		{name: "FinalState", state: "end", event: "proceed", expected: false},
+		{name: "ReturnAllowed", state: "start", event: "back", expected: true},
+		{name: "ReturnRejectedInStrictMode", state: "start", event: "back", opts: []StateMachineOption{WithStrictSideQuests()}, expected: false},
+		{name: "ConditionError", state: "start", event: "broken", expectedError: errors.New("condition error")},
+		{name: "UnknownState", state: "missing", event: "proceed", expectedError: ErrStateNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := NewStateMachine(definition, registry, nil, tt.opts...)
+
+			ok, err := fsm.CanTrigger(context.Background(), tt.state, tt.event, map[string]any{})
+			if tt.expectedError != nil {
+				if err == nil {
+					t.Fatalf("Expected error %v, got nil", tt.expectedError)
+				}
+				if !errors.Is(err, tt.expectedError) && !strings.Contains(err.Error(), tt.expectedError.Error()) {
+					t.Errorf("Expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestStateMachine_AvailableEvents(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end"},
+					{Event: "guarded", Target: "end", Conditions: []string{"alwaysFalse"}},
+					{Event: "choice", Target: "end", Conditions: []string{"alwaysFalse"}},
+					{Event: "choice", Target: "start", Conditions: []string{"alwaysTrue"}},
+					// Patterns are not events and are not reported
+					{Event: "webhook.", Match: MatchPrefix, Target: "end"},
+					{Event: `order\..+`, Match: MatchRegex, Target: "end"},
+					{Event: WildcardEvent, Target: "end"},
+				},
+			},
+			"end": {
+				Name:    "end",
+				IsFinal: true,
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("alwaysTrue", MockTrueCondition)
+	registry.RegisterCondition("alwaysFalse", MockFalseCondition)
+
+	var fsm Machine = NewStateMachine(definition, registry, nil)
+
+	events, err := fsm.AvailableEvents(context.Background(), "start", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"proceed", "choice", WildcardEvent}
+	if !slices.Equal(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+
+	events, err = fsm.AvailableEvents(context.Background(), "end", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events, got %v", events)
+	}
+
+	if _, err := fsm.AvailableEvents(context.Background(), "missing", nil); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound, got %v", err)
+	}
+}
+
+func TestStateMachine_Trigger_ParamConditions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:         "pay",
+						Target:        "review",
+						Conditions:    []string{"amountGreaterThan"},
+						ConditionArgs: map[string]map[string]any{"amountGreaterThan": {"min": 1000}},
+					},
+					{
+						Event:         "pay",
+						Target:        "approved",
+						Conditions:    []string{"amountGreaterThan"},
+						ConditionArgs: map[string]map[string]any{"amountGreaterThan": {"min": 0}},
+					},
+				},
+			},
+			"review": {
+				Name: "review",
+				Transitions: []Transition{
+					{
+						Event:         "approve",
+						Target:        "approved",
+						Conditions:    []string{"amountGreaterThan"},
+						ConditionArgs: map[string]map[string]any{"amountGreaterThan": {"min": 5000}},
+					},
+				},
+			},
+			"approved": {
+				Name: "approved",
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterParamCondition("amountGreaterThan", func(ctx context.Context, data map[string]any, args map[string]any) (bool, error) {
+		return data["amount"].(int) > args["min"].(int), nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	ctx := context.Background()
+
+	// Selection among multiple transitions uses per-transition arguments
+	result, err := fsm.Trigger(ctx, "start", "pay", map[string]any{"amount": 2000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "review" {
+		t.Errorf("Expected new state to be 'review', got '%s'", result.NewState)
+	}
+
+	result, err = fsm.Trigger(ctx, "start", "pay", map[string]any{"amount": 50})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "approved" {
+		t.Errorf("Expected new state to be 'approved', got '%s'", result.NewState)
+	}
+
+	// A single transition's guard also receives its arguments
+	_, err = fsm.Trigger(ctx, "review", "approve", map[string]any{"amount": 2000})
+	if err == nil || err.Error() != "condition amountGreaterThan evaluated to false" {
+		t.Errorf("Expected condition to evaluate to false, got %v", err)
+	}
+}
+
+func TestStateMachine_Trigger_TransitionConditions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "route", Target: "express", Conditions: []string{"targetAllowed"}},
+					{Event: "route", Target: "standard", Conditions: []string{"targetAllowed"}},
+					{Event: "escalate", Target: "manager", Conditions: []string{"targetAllowed"}},
+				},
+			},
+			"express":  {Name: "express"},
+			"standard": {Name: "standard"},
+			"manager":  {Name: "manager"},
+		},
+	}
+
+	var seen []TransitionContext
+	registry := NewRegistry()
+	registry.RegisterTransitionCondition("targetAllowed", func(ctx context.Context, transition TransitionContext, data map[string]any) (bool, error) {
+		seen = append(seen, transition)
+		allowed, _ := data["allowed"].([]string)
+		return slices.Contains(allowed, transition.Target), nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		event         string
+		allowed       []string
+		expectedState string
+		expectedError string
+	}{
+		{name: "FirstTargetAllowed", event: "route", allowed: []string{"express", "standard"}, expectedState: "express"},
+		{name: "SecondTargetAllowed", event: "route", allowed: []string{"standard"}, expectedState: "standard"},
+		{name: "NoTargetAllowed", event: "route", allowed: nil, expectedError: "no valid transition found for event route in state start: no transition found for event route with matching conditions"},
+		{name: "SingleTransitionAllowed", event: "escalate", allowed: []string{"manager"}, expectedState: "manager"},
+		{name: "SingleTransitionRejected", event: "escalate", allowed: []string{"express"}, expectedError: "condition targetAllowed evaluated to false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			result, err := fsm.Trigger(ctx, "start", tt.event, map[string]any{"allowed": tt.allowed})
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			} else if result.NewState != tt.expectedState {
+				t.Errorf("Expected new state to be '%s', got '%s'", tt.expectedState, result.NewState)
+			}
+
+			if len(seen) == 0 {
+				t.Fatal("Expected condition to be evaluated")
+			}
+			for _, transition := range seen {
+				if transition.Event != tt.event || transition.From != "start" {
+					t.Errorf("Expected event %s from start, got %+v", tt.event, transition)
+				}
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_ChosenTransition(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"payment": {
+				Name: "payment",
+				Transitions: []Transition{
+					{Event: "settle", Target: "refunded", Conditions: []string{"isRefundRequested"}},
+					{Event: "settle", Target: "shipped", Conditions: []string{"isPaid"}},
+				},
+			},
+			"refunded": {Name: "refunded"},
+			"shipped":  {Name: "shipped"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isRefundRequested", func(ctx context.Context, data map[string]any) (bool, error) {
+		return data["refund"] == true, nil
+	})
+	registry.RegisterCondition("isPaid", MockTrueCondition)
+
+	fsm := NewStateMachine(definition, registry, nil)
+
+	tests := []struct {
+		name     string
+		payload  map[string]any
+		expected ChosenTransition
+	}{
+		{name: "FirstBranch", payload: map[string]any{"refund": true}, expected: ChosenTransition{Index: 0, Event: "settle", Target: "refunded", Conditions: []string{"isRefundRequested"}}},
+		{name: "SecondBranch", payload: map[string]any{}, expected: ChosenTransition{Index: 1, Event: "settle", Target: "shipped", Conditions: []string{"isPaid"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fsm.Trigger(context.Background(), "payment", "settle", tt.payload)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.ChosenTransition == nil {
+				t.Fatal("Expected chosen transition, got nil")
+			}
+			chosen := *result.ChosenTransition
+			if chosen.Index != tt.expected.Index || chosen.Event != tt.expected.Event || chosen.Target != tt.expected.Target || !slices.Equal(chosen.Conditions, tt.expected.Conditions) {
+				t.Errorf("Expected chosen transition %+v, got %+v", tt.expected, chosen)
+			}
+		})
+	}
+}
+
+func TestNewStateMachineE_InvalidDefinition(t *testing.T) {
+	invalidDefinition := &WorkflowDefinition{
+		States: map[string]State{},
+	}
+
+	fsm, err := NewStateMachineE(invalidDefinition, NewRegistry(), nil)
+	if fsm != nil {
+		t.Error("Expected state machine to be nil for invalid definition")
+	}
+
+	if err == nil {
+		t.Fatal("Expected error for invalid definition, got nil")
+	}
+
+	if err.Error() != "invalid workflow definition: workflow must have at least one state" {
+		t.Errorf("Unexpected error message '%s'", err.Error())
+	}
+}
+
+func TestNewStateMachineE_NilArguments(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {Name: "start"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		definition    *WorkflowDefinition
+		registry      *Registry
+		expectedError string
+	}{
+		{name: "NilDefinition", definition: nil, registry: NewRegistry(), expectedError: "workflow definition must not be nil"},
+		{name: "NilRegistry", definition: definition, registry: nil, expectedError: "registry must not be nil; create one with NewRegistry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm, err := NewStateMachineE(tt.definition, tt.registry, nil)
+			if fsm != nil {
+				t.Error("Expected state machine to be nil")
+			}
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+			}
+
+			if fsm := NewStateMachine(tt.definition, tt.registry, slog.New(slog.NewTextHandler(io.Discard, nil))); fsm != nil {
+				t.Error("Expected NewStateMachine to return nil")
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_AutoEventChaining(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"A": {
+				Name: "A",
+				Transitions: []Transition{
+					{
+						Event:     "start",
+						Target:    "B",
+						AutoEvent: "toC",
+					},
+					{
+						Event:     "loop",
+						Target:    "B",
+						AutoEvent: "back",
+					},
+				},
+			},
+			"B": {
+				Name: "B",
+				Transitions: []Transition{
+					{
+						Event:   "toC",
+						Target:  "C",
+						Actions: []string{"updateAction"},
+					},
+					{
+						Event:     "back",
+						Target:    "A",
+						AutoEvent: "loop",
+					},
+				},
+			},
+			"C": {
+				Name: "C",
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("updateAction", MockUpdateAction)
+
+	t.Run("FollowsChain", func(t *testing.T) {
+		fsm := NewStateMachine(definition, registry, nil, WithAutoEventChaining(5))
+
+		result, err := fsm.Trigger(context.Background(), "A", "start", map[string]any{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if result.NewState != "C" {
+			t.Errorf("Expected new state to be 'C', got '%s'", result.NewState)
+		}
+
+		if result.AutoEvent != "" {
+			t.Errorf("Expected no pending auto event, got '%s'", result.AutoEvent)
+		}
+
+		if result.PersistenceData["updated"] != true {
+			t.Error("Expected persistence data from the chained transition")
+		}
+
+		expected := []TransitionStep{
+			{FromState: "A", Event: "start", ToState: "B"},
+			{FromState: "B", Event: "toC", ToState: "C"},
+		}
+		if len(result.History) != len(expected) {
+			t.Fatalf("Expected %d history entries, got %v", len(expected), result.History)
+		}
+		for i, step := range expected {
+			if result.History[i] != step {
+				t.Errorf("Expected history[%d] to be %v, got %v", i, step, result.History[i])
+			}
+		}
+	})
+
+	t.Run("DetectsCycle", func(t *testing.T) {
+		fsm := NewStateMachine(definition, registry, nil, WithAutoEventChaining(3))
+
+		_, err := fsm.Trigger(context.Background(), "A", "loop", map[string]any{})
+		if err == nil {
+			t.Fatal("Expected error for auto-event cycle, got nil")
+		}
+
+		expected := "auto-event chain exceeded max depth 3: A -(loop)-> B -(back)-> A -(loop)-> B -(back)-> A -(loop)-> ..."
+		if err.Error() != expected {
+			t.Errorf("Expected error '%s', got '%s'", expected, err.Error())
+		}
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		fsm := NewStateMachine(definition, registry, nil)
+
+		result, err := fsm.Trigger(context.Background(), "A", "start", map[string]any{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if result.NewState != "B" || result.AutoEvent != "toC" {
+			t.Errorf("Expected B with pending auto event toC, got %s/%s", result.NewState, result.AutoEvent)
+		}
+
+		if result.History != nil {
+			t.Errorf("Expected no history without chaining, got %v", result.History)
+		}
+	})
+}
+
+func TestStateMachine_Trigger_OnErrorActions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:   "proceed",
+						Target:  "end",
+						Actions: []string{"updateAction", "errorAction"},
+						OnError: []string{"compensate"},
+					},
+					{
+						Event:   "veto",
+						Target:  "end",
+						Actions: []string{"updateAction", "abortAction"},
+						OnError: []string{"compensate"},
+					},
+					{
+						Event:   "compensationFails",
+						Target:  "end",
+						Actions: []string{"errorAction"},
+						OnError: []string{"failingCompensation", "compensate"},
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	var compensated bool
+	var compensationData map[string]any
+	var compensationCause error
+
+	registry := NewRegistry()
+	registry.RegisterAction("updateAction", MockUpdateAction)
+	registry.RegisterAction("errorAction", MockErrorAction)
+	registry.RegisterAction("failingCompensation", MockErrorAction)
+	registry.RegisterAction("abortAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, fmt.Errorf("card flagged: %w", ErrAbortTransition)
+	})
+	registry.RegisterAction("compensate", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		compensated = true
+		compensationData = data
+		compensationCause = TransitionErrorFromContext(ctx)
+		return nil, nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+
+	t.Run("RunsCompensation", func(t *testing.T) {
+		compensated = false
+
+		_, err := fsm.Trigger(context.Background(), "start", "proceed", map[string]any{"orderID": 7})
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+
+		if err.Error() != "transition action errorAction failed: action error" {
+			t.Errorf("Expected original error to be returned, got '%s'", err.Error())
+		}
+
+		if !compensated {
+			t.Fatal("Expected OnError action to run")
+		}
+
+		if compensationData["updated"] != true || compensationData["orderID"] != 7 {
+			t.Errorf("Expected OnError action to receive partial data, got %v", compensationData)
+		}
+
+		if compensationCause == nil || compensationCause.Error() != err.Error() {
+			t.Errorf("Expected OnError action to receive the failure, got %v", compensationCause)
+		}
+	})
+
+	t.Run("RunsCompensationOnAbort", func(t *testing.T) {
+		compensated = false
+
+		_, err := fsm.Trigger(context.Background(), "start", "veto", map[string]any{})
+		var aborted *ErrTransitionAborted
+		if !errors.As(err, &aborted) {
+			t.Fatalf("Expected ErrTransitionAborted, got %v", err)
+		}
+
+		if !compensated {
+			t.Fatal("Expected OnError action to run when an action aborts")
+		}
+
+		if compensationData["updated"] != true {
+			t.Errorf("Expected OnError action to receive partial data, got %v", compensationData)
+		}
+
+		if !errors.Is(compensationCause, ErrAbortTransition) {
+			t.Errorf("Expected OnError action to receive the abort, got %v", compensationCause)
+		}
+	})
+
+	t.Run("CompensationFailure", func(t *testing.T) {
+		compensated = false
+
+		_, err := fsm.Trigger(context.Background(), "start", "compensationFails", map[string]any{})
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+
+		expected := "transition action errorAction failed: action error\nOnError action failingCompensation failed: action error"
+		if err.Error() != expected {
+			t.Errorf("Expected error '%s', got '%s'", expected, err.Error())
+		}
+
+		if !compensated {
+			t.Error("Expected remaining OnError actions to run after one fails")
+		}
+	})
+}
+
+func TestStateMachine_Trigger_ConditionalAutoEvent(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"charging": {
+				Name: "charging",
+				Transitions: []Transition{
+					{
+						Event:               "declined",
+						Target:              "failed",
+						Actions:             []string{"countAttempt"},
+						AutoEvent:           "retry",
+						AutoEventConditions: []string{"attemptsBelow"},
+						ConditionArgs:       map[string]map[string]any{"attemptsBelow": {"max": 3}},
+					},
+				},
+			},
+			"failed": {
+				Name:        "failed",
+				Transitions: []Transition{{Event: "retry", Target: "charging"}},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("countAttempt", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		attempts, _ := data["attempts"].(int)
+		return map[string]any{"attempts": attempts + 1}, nil
+	})
+	registry.RegisterParamCondition("attemptsBelow", func(ctx context.Context, data map[string]any, args map[string]any) (bool, error) {
+		return data["attempts"].(int) < args["max"].(int), nil
+	})
+
+	if err := definition.Validate(); err != nil {
+		t.Fatalf("Expected valid definition, got %v", err)
+	}
+
+	sm := NewStateMachine(definition, registry, slog.Default())
+
+	tests := []struct {
+		name              string
+		attempts          int
+		expectedAutoEvent string
+	}{
+		// The condition sees the attempt counted by the transition's action
+		{name: "Fires", attempts: 1, expectedAutoEvent: "retry"},
+		{name: "Suppressed", attempts: 2, expectedAutoEvent: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sm.Trigger(context.Background(), "charging", "declined", map[string]any{"attempts": tt.attempts})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if result.NewState != "failed" {
+				t.Errorf("Expected new state failed, got %s", result.NewState)
+			}
+
+			if result.AutoEvent != tt.expectedAutoEvent {
+				t.Errorf("Expected auto event '%s', got '%s'", tt.expectedAutoEvent, result.AutoEvent)
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_CollectAllConditionFailures(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"cart": {
+				Name: "cart",
+				Transitions: []Transition{
+					{
+						Event:      "checkout",
+						Target:     "ordered",
+						Conditions: []string{"hasItems", "hasAddress", "inStock"},
+					},
+				},
+			},
+			"ordered": {Name: "ordered"},
+		},
+	}
+
+	var evaluated []string
+	registry := NewRegistry()
+	for name, pass := range map[string]bool{"hasItems": false, "hasAddress": true, "inStock": false} {
+		registry.RegisterCondition(name, func(ctx context.Context, data map[string]any) (bool, error) {
+			evaluated = append(evaluated, name)
+			return pass, nil
+		})
+	}
+	ctx := context.Background()
+
+	// Fail-fast stays the default
+	fsm := NewStateMachine(definition, registry, nil)
+	_, err := fsm.Trigger(ctx, "cart", "checkout", map[string]any{})
+	if err == nil || err.Error() != "condition hasItems evaluated to false" {
+		t.Errorf("Expected only the first failure, got %v", err)
+	}
+	if len(evaluated) != 1 {
+		t.Errorf("Expected 1 condition to be evaluated, got %v", evaluated)
+	}
+
+	evaluated = nil
+	fsm = NewStateMachine(definition, registry, nil, WithCollectAllConditionFailures())
+	_, err = fsm.Trigger(ctx, "cart", "checkout", map[string]any{})
+	if len(evaluated) != 3 {
+		t.Errorf("Expected all 3 conditions to be evaluated, got %v", evaluated)
+	}
+
+	var failures *ConditionFailures
+	if !errors.As(err, &failures) {
+		t.Fatalf("Expected *ConditionFailures, got %v", err)
+	}
+	if len(failures.Errs) != 2 {
+		t.Errorf("Expected 2 failures, got %d", len(failures.Errs))
+	}
+	expected := "conditions failed: condition hasItems evaluated to false; condition inStock evaluated to false"
+	if err.Error() != expected {
+		t.Errorf("Expected error '%s', got '%s'", expected, err.Error())
+	}
+	if !errors.Is(err, ErrTransitionNotFound) {
+		t.Error("Expected the aggregated error to match ErrTransitionNotFound")
+	}
+}
+
+func TestStateMachine_Trigger_RoutingOnlyData(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAction("noOpAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"processed": true}, nil
+	})
+	fsm := NewStateMachine(routingDefinition(), registry, nil)
+	ctx := context.Background()
+
+	// A pure routing edge hands back the payload without copying it
+	payload := map[string]any{"orderID": "42"}
+	result, err := fsm.Trigger(ctx, "start", "route", payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "end" || !maps.Equal(result.PersistenceData, payload) {
+		t.Errorf("Expected the payload to be returned for state 'end', got %+v", result)
+	}
+
+	result, err = fsm.Trigger(ctx, "start", "route", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.PersistenceData == nil {
+		t.Error("Expected non-nil data for a nil payload")
+	}
+
+	// Running an action still works on a copy
+	result, err = fsm.Trigger(ctx, "start", "process", payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.PersistenceData["processed"] != true || result.PersistenceData["orderID"] != "42" {
+		t.Errorf("Expected the action result merged into the payload, got %v", result.PersistenceData)
+	}
+	if _, modified := payload["processed"]; modified {
+		t.Error("Expected the payload to be left unmodified")
+	}
+}
+
+func TestStateMachine_Trigger_Preconditions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"submitted": {
+				Name: "submitted",
+				Transitions: []Transition{
+					{Event: "approve", Target: "review", Conditions: []string{"isLarge"}, Preconditions: []string{"hasBudget"}},
+					{Event: "approve", Target: "approved"},
+				},
+			},
+			"review":   {Name: "review"},
+			"approved": {Name: "approved"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isLarge", func(ctx context.Context, data map[string]any) (bool, error) {
+		return data["amount"].(int) > 1000, nil
+	})
+	registry.RegisterCondition("hasBudget", func(ctx context.Context, data map[string]any) (bool, error) {
+		return data["budget"].(int) >= data["amount"].(int), nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		payload       map[string]any
+		expectedState string
+		canTrigger    bool
+	}{
+		// A failing condition only makes the transition inapplicable, so the next one applies
+		{name: "ConditionSkipsTransition", payload: map[string]any{"amount": 50, "budget": 0}, expectedState: "approved", canTrigger: true},
+		{name: "PreconditionHolds", payload: map[string]any{"amount": 2000, "budget": 5000}, expectedState: "review", canTrigger: true},
+		// A failing precondition fails the event instead of falling through to "approved"
+		{name: "PreconditionFails", payload: map[string]any{"amount": 2000, "budget": 100}, canTrigger: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fsm.Trigger(ctx, "submitted", "approve", tt.payload)
+			if tt.expectedState == "" {
+				var failed *ErrPreconditionFailed
+				if !errors.As(err, &failed) {
+					t.Fatalf("Expected *ErrPreconditionFailed, got %v", err)
+				}
+				if failed.Precondition != "hasBudget" || errors.Is(err, ErrTransitionNotFound) {
+					t.Errorf("Expected a hard failure of hasBudget, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			} else if result.NewState != tt.expectedState {
+				t.Errorf("Expected state '%s', got '%s'", tt.expectedState, result.NewState)
+			}
+
+			if ok, err := fsm.CanTrigger(ctx, "submitted", "approve", tt.payload); ok != tt.canTrigger || err != nil {
+				t.Errorf("Expected CanTrigger %v, got %v, %v", tt.canTrigger, ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package machina
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// AllStates returns copies of all states sorted by name, so callers can traverse the
+// workflow without being able to modify the definition
+func (wd *WorkflowDefinition) AllStates() []State {
+	states := make([]State, 0, len(wd.States))
+	for _, name := range wd.sortedStateNames() {
+		states = append(states, wd.States[name].clone())
+	}
+	return states
+}
+
+// OutgoingEvents returns the distinct events the state has transitions for, in declaration
+// order. Prefix and regex transitions contribute their patterns as declared.
+func (s *State) OutgoingEvents() []string {
+	var events []string
+	for _, transition := range s.Transitions {
+		if !slices.Contains(events, transition.Event) {
+			events = append(events, transition.Event)
+		}
+	}
+	return events
+}
+
+// StateEvent identifies a transition by the state it leaves and the event that triggers it
+type StateEvent struct {
+	State string
+	Event string
+}
+
+// StatesHandlingEvent returns the sorted names of the states that declare a transition
+// for the event. Wildcard transitions only count when looking up WildcardEvent itself.
+func (wd *WorkflowDefinition) StatesHandlingEvent(event string) []string {
+	var names []string
+	for _, name := range wd.sortedStateNames() {
+		state := wd.States[name]
+		if state.handlesEvent(event) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// TransitionsTo returns every (state, event) pair whose transition targets the given state,
+// ordered by state name and then declaration order. Dynamic targets are not included.
+func (wd *WorkflowDefinition) TransitionsTo(target string) []StateEvent {
+	var inbound []StateEvent
+	for _, name := range wd.sortedStateNames() {
+		for _, transition := range wd.States[name].Transitions {
+			if transition.Target == target {
+				inbound = append(inbound, StateEvent{State: name, Event: transition.Event})
+			}
+		}
+	}
+	return inbound
+}
+
+// ValidateSequence checks that the events can be applied in order starting from the given
+// state, following the first transition declared for each event without evaluating
+// conditions or running actions. Auto events are not followed. It reports the first step
+// whose state has no transition for its event, matching ErrTransitionNotFound, or whose
+// transition has a dynamic target that cannot be followed statically.
+func (wd *WorkflowDefinition) ValidateSequence(from string, events []string) error {
+	current := from
+	for i, event := range events {
+		state, exists := wd.States[current]
+		if !exists {
+			return withKind(fmt.Errorf("step %d: state %s not found", i, current), ErrStateNotFound)
+		}
+
+		matching := state.matchingTransitions(event, nil)
+		if len(matching) == 0 {
+			return withKind(fmt.Errorf("step %d: state %s has no transition for event %s", i, current, event), ErrTransitionNotFound)
+		}
+
+		transition := state.Transitions[matching[0]]
+		if transition.Target == "" {
+			return fmt.Errorf("step %d: event %s in state %s has a dynamic target", i, event, current)
+		}
+		current = transition.Target
+	}
+	return nil
+}
+
+// findAction reports where the workflow first uses the named action, checking states in
+// name order and their hooks before their transitions
+func (wd *WorkflowDefinition) findAction(action string) (string, bool) {
+	for _, name := range wd.sortedStateNames() {
+		state := wd.States[name]
+		if slices.Contains(state.OnEnter, action) {
+			return fmt.Sprintf("state %s onEnter", name), true
+		}
+		if slices.Contains(state.OnLeave, action) {
+			return fmt.Sprintf("state %s onLeave", name), true
+		}
+		if slices.Contains(state.OnReenter, action) {
+			return fmt.Sprintf("state %s onReenter", name), true
+		}
+		for _, transition := range state.Transitions {
+			if slices.Contains(transition.Actions, action) || slices.Contains(transition.OnError, action) {
+				return fmt.Sprintf("state %s transition for event %s", name, transition.Event), true
+			}
+			for _, group := range transition.Parallel {
+				if slices.Contains(group, action) {
+					return fmt.Sprintf("state %s transition for event %s", name, transition.Event), true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// clone returns a deep copy of the state
+func (s State) clone() State {
+	s.OnEnter = slices.Clone(s.OnEnter)
+	s.OnLeave = slices.Clone(s.OnLeave)
+	s.OnReenter = slices.Clone(s.OnReenter)
+	s.Metadata = maps.Clone(s.Metadata)
+	s.DeferredEvents = slices.Clone(s.DeferredEvents)
+	s.SubWorkflow = s.SubWorkflow.clone()
+	if s.Transitions != nil {
+		transitions := make([]Transition, len(s.Transitions))
+		for i, transition := range s.Transitions {
+			transitions[i] = transition.clone()
+		}
+		s.Transitions = transitions
+	}
+	return s
+}
+
+// clone returns a deep copy of the transition. Condition argument values are copied shallowly.
+func (t Transition) clone() Transition {
+	t.Conditions = slices.Clone(t.Conditions)
+	t.Preconditions = slices.Clone(t.Preconditions)
+	t.RequiredData = slices.Clone(t.RequiredData)
+	t.Validators = slices.Clone(t.Validators)
+	t.Actions = slices.Clone(t.Actions)
+	if t.Parallel != nil {
+		groups := make([][]string, len(t.Parallel))
+		for i, group := range t.Parallel {
+			groups[i] = slices.Clone(group)
+		}
+		t.Parallel = groups
+	}
+	t.OnError = slices.Clone(t.OnError)
+	t.AutoEventConditions = slices.Clone(t.AutoEventConditions)
+	t.Roles = slices.Clone(t.Roles)
+	t.Webhooks = slices.Clone(t.Webhooks)
+	if t.RateLimit != nil {
+		rateLimit := *t.RateLimit
+		t.RateLimit = &rateLimit
+	}
+	t.Metadata = maps.Clone(t.Metadata)
+	if t.ConditionArgs != nil {
+		args := make(map[string]map[string]any, len(t.ConditionArgs))
+		for name, conditionArgs := range t.ConditionArgs {
+			args[name] = maps.Clone(conditionArgs)
+		}
+		t.ConditionArgs = args
+	}
+	return t
+}
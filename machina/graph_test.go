@@ -0,0 +1,131 @@
+package machina
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWorkflowDefinition_AllStates(t *testing.T) {
+	definition := exportTestDefinition()
+
+	states := definition.AllStates()
+
+	var names []string
+	for _, state := range states {
+		names = append(names, state.Name)
+	}
+	if fmt.Sprint(names) != "[B# end start]" {
+		t.Errorf("Expected states sorted by name, got %v", names)
+	}
+
+	// Mutating the returned copies must not affect the definition
+	states[2].Transitions[0].Target = "elsewhere"
+	states[1].OnEnter = append(states[1].OnEnter, "extra")
+	if definition.States["start"].Transitions[0].Target != "end" {
+		t.Error("Expected AllStates to return copies of transitions")
+	}
+	if len(definition.States["end"].OnEnter) != 0 {
+		t.Error("Expected AllStates to return copies of states")
+	}
+}
+
+func TestState_OutgoingEvents(t *testing.T) {
+	state := &State{
+		Name: "review",
+		Transitions: []Transition{
+			{Event: "approve", Target: "approved", Conditions: []string{"isManager"}},
+			{Event: "reject", Target: "rejected"},
+			{Event: "approve", Target: "escalated"},
+		},
+	}
+
+	if events := state.OutgoingEvents(); fmt.Sprint(events) != "[approve reject]" {
+		t.Errorf("Expected [approve reject], got %v", events)
+	}
+
+	if events := (&State{Name: "end"}).OutgoingEvents(); len(events) != 0 {
+		t.Errorf("Expected no events, got %v", events)
+	}
+}
+
+func TestWorkflowDefinition_StatesHandlingEvent(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"draft":     {Name: "draft", Transitions: []Transition{{Event: "submit", Target: "review"}, {Event: "cancel", Target: "cancelled"}}},
+			"review":    {Name: "review", Transitions: []Transition{{Event: "approve", Target: "approved"}, {Event: "cancel", Target: "cancelled"}, {Event: "cancel", Target: "draft"}}},
+			"approved":  {Name: "approved", Transitions: []Transition{{Event: WildcardEvent, Target: "approved"}}},
+			"cancelled": {Name: "cancelled", IsFinal: true},
+		},
+	}
+
+	tests := []struct {
+		event    string
+		expected string
+	}{
+		{event: "cancel", expected: "[draft review]"},
+		{event: "approve", expected: "[review]"},
+		{event: WildcardEvent, expected: "[approved]"},
+		{event: "missing", expected: "[]"},
+	}
+
+	for _, tt := range tests {
+		if got := definition.StatesHandlingEvent(tt.event); fmt.Sprint(got) != tt.expected {
+			t.Errorf("StatesHandlingEvent(%s): expected %s, got %v", tt.event, tt.expected, got)
+		}
+	}
+
+	inbound := definition.TransitionsTo("cancelled")
+	expected := []StateEvent{{State: "draft", Event: "cancel"}, {State: "review", Event: "cancel"}}
+	if fmt.Sprint(inbound) != fmt.Sprint(expected) {
+		t.Errorf("Expected transitions to cancelled %v, got %v", expected, inbound)
+	}
+
+	if inbound := definition.TransitionsTo("draft"); len(inbound) != 1 || inbound[0] != (StateEvent{State: "review", Event: "cancel"}) {
+		t.Errorf("Expected a single transition to draft, got %v", inbound)
+	}
+}
+
+func TestWorkflowDefinition_ValidateSequence(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"draft":    {Name: "draft", Transitions: []Transition{{Event: "submit", Target: "review"}}},
+			"review":   {Name: "review", Transitions: []Transition{{Event: "approve", Target: "approved", Conditions: []string{"isManager"}}, {Event: "reject", Target: "draft"}, {Event: "route"}}},
+			"approved": {Name: "approved", Transitions: []Transition{{Event: "publish", Target: "live"}}},
+			"live":     {Name: "live", IsFinal: true},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		from        string
+		events      []string
+		expectedErr string
+	}{
+		{name: "ValidSequence", from: "draft", events: []string{"submit", "reject", "submit", "approve", "publish"}},
+		{name: "EmptySequence", from: "draft"},
+		{name: "BrokenSequence", from: "draft", events: []string{"submit", "publish"}, expectedErr: "step 1: state review has no transition for event publish"},
+		{name: "EventAfterFinalState", from: "approved", events: []string{"publish", "submit"}, expectedErr: "step 1: state live has no transition for event submit"},
+		{name: "UnknownStartState", from: "missing", events: []string{"submit"}, expectedErr: "step 0: state missing not found"},
+		{name: "DynamicTarget", from: "review", events: []string{"route", "submit"}, expectedErr: "step 0: event route in state review has a dynamic target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := definition.ValidateSequence(tt.from, tt.events)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("Expected error %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+
+	if err := definition.ValidateSequence("draft", []string{"approve"}); !errors.Is(err, ErrTransitionNotFound) {
+		t.Errorf("Expected ErrTransitionNotFound, got %v", err)
+	}
+}
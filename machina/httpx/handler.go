@@ -0,0 +1,113 @@
+// Package httpx exposes a machina.Machine over HTTP.
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/rahulpahuja/go-machina/machina"
+)
+
+// TriggerRequest is the JSON body accepted by POST /trigger
+type TriggerRequest struct {
+	State string         `json:"state"`
+	Event string         `json:"event"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+// DefaultMaxBodyBytes is the size limit of a request body unless WithMaxBodyBytes is applied
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// Option configures the handler returned by NewHandler
+type Option func(*handlerConfig)
+
+// handlerConfig holds the settings applied by Options
+type handlerConfig struct {
+	maxBodyBytes int64
+}
+
+// WithMaxBodyBytes limits the size of a request body to n bytes. Larger bodies are
+// rejected with 413 before Trigger is called.
+func WithMaxBodyBytes(n int64) Option {
+	return func(c *handlerConfig) {
+		c.maxBodyBytes = n
+	}
+}
+
+// errorResponse is the JSON body returned when a request fails
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler returns an http.Handler serving POST /trigger for the machine.
+// The request {state, event, data} is passed to Trigger with the request's context, so
+// client disconnects and server timeouts cancel the transition. Successful transitions
+// return the TransitionResult JSON. Failures return {"error"} with status:
+//
+//   - 400 for a malformed request
+//   - 413 for a body larger than DefaultMaxBodyBytes, or the limit set with WithMaxBodyBytes
+//   - 404 if the state does not exist (machina.ErrStateNotFound)
+//   - 409 if the event does not apply in the state (machina.ErrTransitionNotFound) or an
+//     action vetoed the transition (machina.ErrTransitionAborted)
+//   - 422 if the payload lacks required data or a payload validator rejected it
+//     (machina.ErrInvalidPayload)
+//   - 500 for action and other errors
+func NewHandler(sm machina.Machine, opts ...Option) http.Handler {
+	config := handlerConfig{maxBodyBytes: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /trigger", func(w http.ResponseWriter, r *http.Request) {
+		var req TriggerRequest
+		body := http.MaxBytesReader(w, r.Body, config.maxBodyBytes)
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: "request body exceeds " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes"})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+			return
+		}
+
+		if req.State == "" || req.Event == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "state and event are required"})
+			return
+		}
+
+		result, err := sm.Trigger(r.Context(), req.State, req.Event, req.Data)
+		if err != nil {
+			writeJSON(w, statusForError(err), errorResponse{Error: err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	})
+	return mux
+}
+
+// statusForError maps a Trigger error to an HTTP status code
+func statusForError(err error) int {
+	var aborted *machina.ErrTransitionAborted
+	switch {
+	case errors.Is(err, machina.ErrStateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, machina.ErrTransitionNotFound), errors.As(err, &aborted):
+		return http.StatusConflict
+	case errors.Is(err, machina.ErrInvalidPayload):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes v as the JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
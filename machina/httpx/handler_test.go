@@ -0,0 +1,202 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rahulpahuja/go-machina/machina"
+)
+
+// newTestMachine returns a machine for a small order workflow
+func newTestMachine() *machina.StateMachine {
+	definition := &machina.WorkflowDefinition{
+		InitialState: "pending",
+		States: map[string]machina.State{
+			"pending": {
+				Name: "pending",
+				Transitions: []machina.Transition{
+					{Event: "pay", Target: "paid", Actions: []string{"charge"}, RequiredData: []string{"orderID"}},
+					{Event: "fail", Target: "paid", Actions: []string{"broken"}},
+					{Event: "veto", Target: "paid", Actions: []string{"veto"}},
+				},
+			},
+			"paid": {
+				Name:    "paid",
+				IsFinal: true,
+			},
+		},
+	}
+
+	registry := machina.NewRegistry()
+	registry.RegisterAction("charge", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"charged": true}, nil
+	})
+	registry.RegisterAction("broken", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, errors.New("payment gateway unavailable")
+	})
+	registry.RegisterAction("veto", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, fmt.Errorf("fraud suspected: %w", machina.ErrAbortTransition)
+	})
+
+	return machina.NewStateMachine(definition, registry, nil)
+}
+
+func TestHandler_Trigger(t *testing.T) {
+	handler := NewHandler(newTestMachine())
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+		expectedState  string
+		expectedError  string
+	}{
+		{
+			name:           "Success",
+			method:         http.MethodPost,
+			body:           `{"state":"pending","event":"pay","data":{"orderID":7}}`,
+			expectedStatus: http.StatusOK,
+			expectedState:  "paid",
+		},
+		{
+			name:           "UnknownState",
+			method:         http.MethodPost,
+			body:           `{"state":"missing","event":"pay"}`,
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "failed to get state definition for missing: state missing not found",
+		},
+		{
+			name:           "UnknownEvent",
+			method:         http.MethodPost,
+			body:           `{"state":"pending","event":"ship"}`,
+			expectedStatus: http.StatusConflict,
+			expectedError:  "no valid transition found for event ship in state pending: no transition for 'ship' in state pending (did you mean: fail, veto?)",
+		},
+		{
+			name:           "Aborted",
+			method:         http.MethodPost,
+			body:           `{"state":"pending","event":"veto"}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "MissingData",
+			method:         http.MethodPost,
+			body:           `{"state":"pending","event":"pay"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  "transition from pending on event pay is missing required data: orderID",
+		},
+		{
+			name:           "ActionError",
+			method:         http.MethodPost,
+			body:           `{"state":"pending","event":"fail"}`,
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "transition action broken failed: payment gateway unavailable",
+		},
+		{
+			name:           "MalformedBody",
+			method:         http.MethodPost,
+			body:           `{"state":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "MissingEvent",
+			method:         http.MethodPost,
+			body:           `{"state":"pending"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "state and event are required",
+		},
+		{
+			name:           "WrongMethod",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/trigger", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusMethodNotAllowed {
+				return
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected JSON response, got %q", rec.Body.String())
+			}
+
+			if tt.expectedState != "" {
+				if body["newState"] != tt.expectedState {
+					t.Errorf("Expected new state %s, got %v", tt.expectedState, body["newState"])
+				}
+				data, _ := body["data"].(map[string]any)
+				if data["charged"] != true || data["orderID"] != float64(7) {
+					t.Errorf("Expected persistence data in response, got %v", body["data"])
+				}
+			}
+
+			if tt.expectedError != "" && body["error"] != tt.expectedError {
+				t.Errorf("Expected error '%s', got '%v'", tt.expectedError, body["error"])
+			}
+		})
+	}
+}
+
+func TestHandler_BodyTooLarge(t *testing.T) {
+	handler := NewHandler(newTestMachine(), WithMaxBodyBytes(64))
+
+	body := `{"state":"pending","event":"pay","data":{"orderID":7,"note":"` + strings.Repeat("x", 64) + `"}}`
+	req := httptest.NewRequest(http.MethodPost, "/trigger", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "request body exceeds 64 bytes") {
+		t.Errorf("Expected a body size error, got %s", rec.Body.String())
+	}
+}
+
+func TestHandler_PropagatesRequestContext(t *testing.T) {
+	definition := &machina.WorkflowDefinition{
+		States: map[string]machina.State{
+			"start": {Name: "start", Transitions: []machina.Transition{{Event: "go", Target: "end", Actions: []string{"wait"}}}},
+			"end":   {Name: "end"},
+		},
+	}
+
+	registry := machina.NewRegistry()
+	registry.RegisterAction("wait", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	handler := NewHandler(machina.NewStateMachine(definition, registry, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodPost, "/trigger", strings.NewReader(`{"state":"start","event":"go"}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "context canceled") {
+		t.Errorf("Expected cancelled transition, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,156 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ErrIdempotencyInFlight is returned by Trigger when another call is still processing an
+// event with the same workflow ID and idempotency key
+var ErrIdempotencyInFlight = errors.New("event with this idempotency key is already being processed")
+
+// IdempotencyStore records the result of each transition by workflow ID and idempotency
+// key, so a redelivered event can be answered without running its actions again.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Reserve claims the key before its transition runs, atomically with respect to other
+	// calls for the same key. It returns the recorded result and true if the key was
+	// completed before, and ErrIdempotencyInFlight if another call holds the key.
+	Reserve(ctx context.Context, workflowID, key string) (*TransitionResult, bool, error)
+	// Put records the result of the transition identified by a reserved key
+	Put(ctx context.Context, workflowID, key string, result *TransitionResult) error
+	// Release gives up the reservation of a key whose transition failed, so the event can
+	// be retried
+	Release(ctx context.Context, workflowID, key string) error
+}
+
+// WithIdempotency deduplicates redelivered events. The payload entry under key holds the
+// event's idempotency key, and the workflow ID comes from WithWorkflowID. The first
+// successful Trigger for a workflow ID and idempotency key is recorded in store, and later
+// calls with the same pair return a copy of the recorded result without running any
+// conditions or actions. A call made while the first is still running fails with
+// ErrIdempotencyInFlight. Events without a workflow ID or idempotency key are processed as
+// usual, and failed transitions are not recorded so they can be retried.
+//
+// The idempotency key identifies one delivery, so it is removed from the returned
+// persistence data; otherwise the next event triggered with that data would be taken for
+// a redelivery.
+func WithIdempotency(key string, store IdempotencyStore) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.idempotencyKey = key
+		sm.idempotencyStore = store
+	}
+}
+
+// triggerIdempotent runs triggerChain unless the event was already processed for the workflow
+func (sm *StateMachine) triggerIdempotent(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error) {
+	workflowID, hasWorkflowID := WorkflowIDFromContext(ctx)
+	value, hasKey := payload[sm.idempotencyKey]
+	if !hasWorkflowID || !hasKey {
+		return sm.triggerChain(ctx, currentState, event, payload)
+	}
+
+	key, ok := value.(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("idempotency key %s must be a non-empty string, got %v", sm.idempotencyKey, value)
+	}
+
+	recorded, found, err := sm.idempotencyStore.Reserve(ctx, workflowID, key)
+	if errors.Is(err, ErrIdempotencyInFlight) {
+		return nil, fmt.Errorf("idempotency key %s: %w", key, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key %s: %w", key, err)
+	}
+	if found {
+		sm.logger.Info("Duplicate event ignored", "from", currentState, "event", event, "workflow_id", workflowID, "idempotency_key", key)
+		return cloneResult(recorded), nil
+	}
+
+	result, err := sm.triggerChain(ctx, currentState, event, payload)
+	if err != nil {
+		if releaseErr := sm.idempotencyStore.Release(ctx, workflowID, key); releaseErr != nil {
+			sm.logger.Error("Failed to release idempotency key", "workflow_id", workflowID, "idempotency_key", key, "error", releaseErr)
+		}
+		return result, err
+	}
+
+	if _, exists := result.PersistenceData[sm.idempotencyKey]; exists {
+		// The data may be the caller's payload when the transition only routes
+		result.PersistenceData = maps.Clone(result.PersistenceData)
+		delete(result.PersistenceData, sm.idempotencyKey)
+	}
+
+	// The transition has already happened, so its result is returned even if recording
+	// fails. The key stays reserved, so a redelivery is refused rather than run twice.
+	if err := sm.idempotencyStore.Put(ctx, workflowID, key, cloneResult(result)); err != nil {
+		return result, fmt.Errorf("failed to record idempotency key %s: %w", key, err)
+	}
+	return result, nil
+}
+
+// cloneResult copies a result so the copy's data and history can be modified independently
+func cloneResult(result *TransitionResult) *TransitionResult {
+	clone := *result
+	clone.PersistenceData = maps.Clone(result.PersistenceData)
+	clone.History = slices.Clone(result.History)
+	if result.ChosenTransition != nil {
+		chosen := *result.ChosenTransition
+		chosen.Conditions = slices.Clone(chosen.Conditions)
+		clone.ChosenTransition = &chosen
+	}
+	return &clone
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps results in memory. Results are
+// never evicted, so it suits tests and short-lived processes.
+type MemoryIdempotencyStore struct {
+	mu sync.Mutex
+	// results holds nil for keys that are reserved but not completed yet
+	results map[[2]string]*TransitionResult
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{results: make(map[[2]string]*TransitionResult)}
+}
+
+// Reserve implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, workflowID, key string) (*TransitionResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, exists := s.results[[2]string{workflowID, key}]
+	if !exists {
+		s.results[[2]string{workflowID, key}] = nil
+		return nil, false, nil
+	}
+	if result == nil {
+		return nil, false, ErrIdempotencyInFlight
+	}
+	return result, true, nil
+}
+
+// Release implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, workflowID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result, exists := s.results[[2]string{workflowID, key}]; exists && result == nil {
+		delete(s.results, [2]string{workflowID, key})
+	}
+	return nil
+}
+
+// Put implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Put(ctx context.Context, workflowID, key string, result *TransitionResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.results[[2]string{workflowID, key}] = result
+	return nil
+}
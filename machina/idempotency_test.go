@@ -0,0 +1,156 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStateMachine_Trigger_Idempotency(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"pending": {
+				Name: "pending",
+				Transitions: []Transition{
+					{Event: "pay", Target: "paid", Actions: []string{"charge"}},
+				},
+			},
+			"paid": {Name: "paid"},
+		},
+	}
+
+	charges := 0
+	registry := NewRegistry()
+	registry.RegisterAction("charge", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		charges++
+		return map[string]any{"charges": charges}, nil
+	})
+
+	store := NewMemoryIdempotencyStore()
+	fsm := NewStateMachine(definition, registry, nil, WithIdempotency("eventID", store))
+	ctx := WithWorkflowID(context.Background(), "order-1")
+	payload := map[string]any{"eventID": "evt-1"}
+
+	// The first delivery executes the transition
+	first, err := fsm.Trigger(ctx, "pending", "pay", payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if charges != 1 {
+		t.Errorf("Expected 1 charge, got %d", charges)
+	}
+	if first.NewState != "paid" {
+		t.Errorf("Expected state 'paid', got '%s'", first.NewState)
+	}
+
+	// A redelivery returns the recorded result without charging again
+	first.PersistenceData["charges"] = 99
+	second, err := fsm.Trigger(ctx, "pending", "pay", payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if charges != 1 {
+		t.Errorf("Expected the redelivered event not to charge again, got %d charges", charges)
+	}
+	if second.NewState != "paid" || second.PersistenceData["charges"] != 1 {
+		t.Errorf("Expected the recorded result, got %+v", second)
+	}
+
+	// Another key, or another workflow with the same key, is a new event
+	if _, err := fsm.Trigger(ctx, "pending", "pay", map[string]any{"eventID": "evt-2"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := fsm.Trigger(WithWorkflowID(context.Background(), "order-2"), "pending", "pay", payload); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if charges != 3 {
+		t.Errorf("Expected 3 charges, got %d", charges)
+	}
+
+	// Without a workflow ID events are not deduplicated
+	if _, err := fsm.Trigger(context.Background(), "pending", "pay", payload); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if charges != 4 {
+		t.Errorf("Expected 4 charges, got %d", charges)
+	}
+
+	_, err = fsm.Trigger(ctx, "pending", "pay", map[string]any{"eventID": 7})
+	expected := "idempotency key eventID must be a non-empty string, got 7"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got %v", expected, err)
+	}
+}
+
+func TestStateMachine_Trigger_IdempotencyReservation(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"pending": {
+				Name: "pending",
+				Transitions: []Transition{
+					{Event: "pay", Target: "pending", Actions: []string{"charge"}},
+				},
+			},
+		},
+	}
+
+	charges := 0
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fail := true
+	registry := NewRegistry()
+	registry.RegisterAction("charge", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		if data["eventID"] == "evt-slow" {
+			close(started)
+			<-release
+		}
+		if data["eventID"] == "evt-fail" && fail {
+			fail = false
+			return nil, errors.New("card declined")
+		}
+		charges++
+		return map[string]any{"charges": charges}, nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil, WithIdempotency("eventID", NewMemoryIdempotencyStore()))
+	ctx := WithWorkflowID(context.Background(), "order-1")
+
+	// A redelivery while the first delivery is running is refused instead of charging twice
+	done := make(chan error, 1)
+	go func() {
+		_, err := fsm.Trigger(ctx, "pending", "pay", map[string]any{"eventID": "evt-slow"})
+		done <- err
+	}()
+	<-started
+	_, err := fsm.Trigger(ctx, "pending", "pay", map[string]any{"eventID": "evt-slow"})
+	if !errors.Is(err, ErrIdempotencyInFlight) {
+		t.Errorf("Expected ErrIdempotencyInFlight, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A failed transition releases its key so the event can be retried
+	if _, err := fsm.Trigger(ctx, "pending", "pay", map[string]any{"eventID": "evt-fail"}); err == nil {
+		t.Fatal("Expected the first attempt to fail")
+	}
+	result, err := fsm.Trigger(ctx, "pending", "pay", map[string]any{"eventID": "evt-fail"})
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if charges != 2 {
+		t.Errorf("Expected 2 charges, got %d", charges)
+	}
+
+	// The key is not carried over, so triggering again with the result's data is a new event
+	if _, exists := result.PersistenceData["eventID"]; exists {
+		t.Errorf("Expected the idempotency key to be removed from the result, got %v", result.PersistenceData)
+	}
+	if _, err := fsm.Trigger(ctx, "pending", "pay", result.PersistenceData); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if charges != 3 {
+		t.Errorf("Expected 3 charges, got %d", charges)
+	}
+}
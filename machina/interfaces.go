@@ -5,6 +5,48 @@ import "context"
 // ConditionFunc defines the function signature for evaluating transition conditions
 type ConditionFunc func(ctx context.Context, data map[string]any) (bool, error)
 
+// ParamConditionFunc defines the function signature for conditions that receive
+// static arguments declared per transition in the workflow definition
+type ParamConditionFunc func(ctx context.Context, data map[string]any, args map[string]any) (bool, error)
+
+// TransitionContext describes the candidate transition a TransitionConditionFunc is guarding
+type TransitionContext struct {
+	// Event is the event being processed, even when the transition is a wildcard
+	Event string
+	// Target is the declared target of the transition, empty for dynamic targets
+	Target string
+	// From is the state the transition leaves
+	From string
+}
+
+// TransitionConditionFunc defines the function signature for conditions that need to know
+// which transition they are guarding, such as one guard shared by several targets
+type TransitionConditionFunc func(ctx context.Context, transition TransitionContext, data map[string]any) (bool, error)
+
+// conditionFunc is the form every kind of condition is adapted to for evaluation
+type conditionFunc func(ctx context.Context, transition TransitionContext, data map[string]any, args map[string]any) (bool, error)
+
+// PayloadValidatorFunc defines the function signature for checking a transition's payload
+// before its conditions run. A non-nil error rejects the payload.
+type PayloadValidatorFunc func(ctx context.Context, data map[string]any) error
+
 // ActionFunc defines the function signature for executing state actions
 // It returns a map of updated data and an error
 type ActionFunc func(ctx context.Context, data map[string]any) (map[string]any, error)
+
+// Resolver lazily supplies actions and conditions that are not in the Registry, such as
+// implementations loaded from plugins at runtime. It is consulted only when a registry
+// lookup misses.
+type Resolver interface {
+	ResolveAction(name string) (ActionFunc, bool)
+	ResolveCondition(name string) (ConditionFunc, bool)
+}
+
+// Machine is the behaviour of a StateMachine, so code driving workflows can depend on
+// it and substitute fakes in tests
+type Machine interface {
+	Trigger(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error)
+	GetAutoEventForTransition(fromState, event string) (string, error)
+	CanTrigger(ctx context.Context, currentState string, event string, payload map[string]any) (bool, error)
+	AvailableEvents(ctx context.Context, currentState string, payload map[string]any) ([]string, error)
+}
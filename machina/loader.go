@@ -1,25 +1,559 @@
 package machina
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadWorkflowDefinition loads a workflow definition from a YAML file
+// workflowDocument is the on-disk form of a workflow file, which may include other files
+type workflowDocument struct {
+	Include            []string `yaml:"include,omitempty"`
+	WorkflowDefinition `yaml:",inline"`
+}
+
+// LoadOptions configures how workflow definitions are loaded
+type LoadOptions struct {
+	// ExpandEnv enables substitution of ${VAR} and ${VAR:-default} placeholders with
+	// environment variables before the YAML is parsed. Use $$ for a literal $.
+	// Referencing an unset variable without a default is an error.
+	ExpandEnv bool
+	// LookupEnv resolves variables for ExpandEnv. Defaults to os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+	// MaxBytes caps how much of each file is read, so oversized uploads are rejected
+	// before they are parsed. The limit applies again after environment variables are
+	// expanded, and to the files of the whole include tree together. Zero means no limit.
+	MaxBytes int64
+	// MaxStates caps the number of states in each file and in the merged definition.
+	// Zero means no limit.
+	MaxStates int
+	// MaxIncludeDepth caps how deeply includes may be nested. Zero means no limit.
+	MaxIncludeDepth int
+	// DisableIncludes rejects any file that uses the include key.
+	DisableIncludes bool
+	// ConfineIncludes rejects includes that resolve outside the directory of the file
+	// being loaded, such as absolute paths or paths climbing out with "..". Symbolic
+	// links are resolved before the check.
+	ConfineIncludes bool
+	// SupportedVersions lists the schema versions the caller accepts, usually set with
+	// WithSchemaVersion. A definition declaring any other version is upgraded with the
+	// migrations registered with RegisterMigration, or rejected if none apply.
+	// Empty means any version is accepted.
+	SupportedVersions []string
+
+	// migrations holds the migrations registered with RegisterMigration, keyed by the
+	// version they upgrade from
+	migrations map[string]migration
+}
+
+// MigrationFunc upgrades a loaded definition in place from one schema version to the next.
+// The loader sets the new version once it returns.
+type MigrationFunc func(definition *WorkflowDefinition) error
+
+// migration is a single registered upgrade step
+type migration struct {
+	to string
+	fn MigrationFunc
+}
+
+// WithSchemaVersion returns a copy of the options that only accepts definitions declaring
+// one of the supported versions
+func (o LoadOptions) WithSchemaVersion(supported ...string) LoadOptions {
+	o.SupportedVersions = slices.Clone(supported)
+	return o
+}
+
+// RegisterMigration returns a copy of the options with fn registered to upgrade definitions
+// declaring version from to version to. The receiver is left unchanged. Migrations are
+// chained, so 1 -> 2 and 2 -> 3 together upgrade a version 1 definition to 3. A definition
+// without a version can be migrated by registering from "".
+func (o LoadOptions) RegisterMigration(from, to string, fn MigrationFunc) (LoadOptions, error) {
+	if from == to {
+		return o, fmt.Errorf("migration from version %s must change the version", from)
+	}
+
+	if _, exists := o.migrations[from]; exists {
+		return o, fmt.Errorf("migration from version %s already registered", from)
+	}
+
+	migrations := make(map[string]migration, len(o.migrations)+1)
+	maps.Copy(migrations, o.migrations)
+	migrations[from] = migration{to: to, fn: fn}
+	o.migrations = migrations
+	return o, nil
+}
+
+// includeLoader loads workflow files and resolves their include directives
+type includeLoader struct {
+	options LoadOptions
+	stack   []string        // files currently being loaded, for cycle detection
+	loaded  map[string]bool // files already merged, so shared includes are merged once
+	root    string          // the file the include tree starts from
+	bytes   int64           // bytes parsed so far across the include tree
+}
+
+// LoadWorkflowDefinition loads a workflow definition from a YAML file.
+//
+// A file may list other workflow files under a top-level `include` key. Paths are
+// resolved relative to the including file. Included files are merged in order before
+// the including file itself, so later files take precedence: a non-empty version,
+// initialState, errorState or state name overrides earlier ones, isSideQuest and isFinal
+// are set if any file sets them, onEnter, onLeave, onReenter and deferredEvents are
+// appended, a non-empty timeout, timeoutEvent, maxDwell, subWorkflow or description
+// overrides earlier ones, and metadata keys are merged. Transitions are matched by event
+// as for overlays: a later file's transitions for an event replace the earlier ones for
+// that event, and transitions for other events are appended. Include cycles are reported
+// as errors, and a file included more than once is only merged the first time.
 func LoadWorkflowDefinition(filePath string) (*WorkflowDefinition, error) {
-	data, err := os.ReadFile(filePath)
+	return LoadWorkflowDefinitionWithOptions(filePath, LoadOptions{})
+}
+
+// LoadWorkflowDefinitionWithOptions loads a workflow definition from a YAML file
+// using the given options. Options also apply to included files, while the schema
+// version is checked and migrated once the includes have been merged.
+func LoadWorkflowDefinitionWithOptions(filePath string, options LoadOptions) (*WorkflowDefinition, error) {
+	if options.LookupEnv == nil {
+		options.LookupEnv = os.LookupEnv
+	}
+
+	loader := &includeLoader{options: options, loaded: make(map[string]bool), root: filePath}
+	definition, err := loader.load(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+		return nil, err
+	}
+
+	if err := options.migrate(filePath, definition); err != nil {
+		return nil, err
+	}
+
+	if err := options.checkStateCount(filePath, definition); err != nil {
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+// LoadWorkflowDefinitionWithOverlays loads the base workflow file and then applies each
+// overlay file on top of it in order, such as per-environment tweaks. Each file may use
+// includes as usual.
+//
+// Unlike includes, overlays replace rather than append: states missing from the base are
+// added, and for states present in both, a non-empty name, timeout, timeoutEvent, maxDwell,
+// subWorkflow or description overrides the base, non-empty onEnter, onLeave, onReenter and
+// deferredEvents lists replace the base lists, isSideQuest and isFinal are set if the
+// overlay sets them, and metadata keys are merged. Transitions are matched by event: the
+// overlay's transitions for an event replace all of the base's transitions for that event,
+// in the position of the first one, and transitions for events the base doesn't handle are
+// appended. A non-empty initialState, errorState or version also overrides the base.
+func LoadWorkflowDefinitionWithOverlays(base string, overlays ...string) (*WorkflowDefinition, error) {
+	definition, err := LoadWorkflowDefinition(base)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, overlayPath := range overlays {
+		overlay, err := LoadWorkflowDefinition(overlayPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load overlay %s: %w", overlayPath, err)
+		}
+		definition.overlay(overlay)
+	}
+
+	return definition, nil
+}
+
+// LoadWorkflowDefinitions loads every .yaml and .yml file directly inside dir, keyed by
+// file name without its extension. Subdirectories are not searched, so fragments that are
+// only meant to be included can live in one.
+//
+// Errors are collected per file and joined, so one bad file doesn't hide the others.
+// Files that fail to load are left out of the map, while definitions that load but fail
+// Validate are returned alongside their validation error.
+func LoadWorkflowDefinitions(dir string) (map[string]*WorkflowDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	definitions := make(map[string]*WorkflowDefinition)
+	var errs []error
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if _, exists := definitions[name]; exists {
+			errs = append(errs, fmt.Errorf("%s: workflow %s is already defined by another file", entry.Name(), name))
+			continue
+		}
+
+		definition, err := LoadWorkflowDefinition(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		definitions[name] = definition
+
+		if err := definition.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid workflow definition: %w", entry.Name(), err))
+		}
+	}
+
+	return definitions, errors.Join(errs...)
+}
+
+// load reads a single workflow file and merges its includes into it.
+// It returns nil if the file has already been merged.
+func (l *includeLoader) load(filePath string) (*WorkflowDefinition, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path %s: %w", filePath, err)
+	}
+
+	if slices.Contains(l.stack, absPath) {
+		return nil, fmt.Errorf("include cycle detected: %s", strings.Join(append(l.stack, absPath), " -> "))
+	}
+
+	if l.loaded[absPath] {
+		return nil, nil
+	}
+	l.loaded[absPath] = true
+
+	if l.options.MaxIncludeDepth > 0 && len(l.stack) > l.options.MaxIncludeDepth {
+		return nil, fmt.Errorf("include depth exceeds the limit of %d: %s", l.options.MaxIncludeDepth, strings.Join(append(l.stack, absPath), " -> "))
 	}
 
-	var definition WorkflowDefinition
-	definition.States = make(map[string]State)
+	data, err := l.options.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.options.ExpandEnv {
+		expanded, err := expandEnv(string(data), l.options.LookupEnv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand environment variables in %s: %w", filePath, err)
+		}
+		data = []byte(expanded)
+	}
 
-	if err := yaml.Unmarshal(data, &definition); err != nil {
+	if err := l.checkSize(filePath, data); err != nil {
+		return nil, err
+	}
+
+	var document workflowDocument
+	document.States = make(map[string]State)
+
+	if err := yaml.Unmarshal(data, &document); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
 	}
 
-	return &definition, nil
+	if err := l.options.checkStateCount(filePath, &document.WorkflowDefinition); err != nil {
+		return nil, err
+	}
+
+	l.stack = append(l.stack, absPath)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	if l.options.DisableIncludes && len(document.Include) > 0 {
+		return nil, fmt.Errorf("workflow %s uses include, which is disabled", filePath)
+	}
+
+	definition := &WorkflowDefinition{States: make(map[string]State)}
+	for _, include := range document.Include {
+		includePath := include
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(filePath), includePath)
+		}
+
+		if l.options.ConfineIncludes {
+			if err := l.checkConfined(include, includePath); err != nil {
+				return nil, err
+			}
+		}
+
+		fragment, err := l.load(includePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load include %s: %w", include, err)
+		}
+		if fragment != nil {
+			definition.merge(fragment)
+		}
+	}
+	definition.merge(&document.WorkflowDefinition)
+
+	return definition, nil
+}
+
+// checkConfined fails if includePath lies outside the directory of the root file
+func (l *includeLoader) checkConfined(include, includePath string) error {
+	rootDir, err := resolvePath(filepath.Dir(l.root))
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", l.root, err)
+	}
+	path, err := resolvePath(includePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", include, err)
+	}
+
+	rel, err := filepath.Rel(rootDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("include %s resolves outside %s", include, rootDir)
+	}
+	return nil
+}
+
+// resolvePath returns the absolute form of path with symbolic links resolved. A path
+// that doesn't exist is returned as is, leaving the read to report it.
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		return resolved, nil
+	}
+	return absPath, nil
+}
+
+// checkSize enforces MaxBytes on a file's expanded content and on the include tree so far
+func (l *includeLoader) checkSize(filePath string, data []byte) error {
+	if l.options.MaxBytes <= 0 {
+		return nil
+	}
+
+	if int64(len(data)) > l.options.MaxBytes {
+		return fmt.Errorf("file %s exceeds the limit of %d bytes after expanding environment variables", filePath, l.options.MaxBytes)
+	}
+
+	l.bytes += int64(len(data))
+	if l.bytes > l.options.MaxBytes {
+		return fmt.Errorf("files included by %s exceed the limit of %d bytes in total", l.root, l.options.MaxBytes)
+	}
+	return nil
+}
+
+// readFile reads a workflow file, failing if it is larger than MaxBytes
+func (o LoadOptions) readFile(filePath string) ([]byte, error) {
+	if o.MaxBytes <= 0 {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+		}
+		return data, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	// Read one byte past the limit to tell a file of exactly MaxBytes from a larger one
+	data, err := io.ReadAll(io.LimitReader(f, o.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	if int64(len(data)) > o.MaxBytes {
+		return nil, fmt.Errorf("file %s exceeds the limit of %d bytes", filePath, o.MaxBytes)
+	}
+	return data, nil
+}
+
+// migrate applies registered migrations until the definition's version is supported.
+// It fails if the version ends up unsupported, a migration fails or the migrations loop.
+func (o LoadOptions) migrate(filePath string, definition *WorkflowDefinition) error {
+	applied := make(map[string]bool)
+	for len(o.SupportedVersions) == 0 || !slices.Contains(o.SupportedVersions, definition.Version) {
+		step, exists := o.migrations[definition.Version]
+		if !exists {
+			if len(o.SupportedVersions) == 0 {
+				return nil
+			}
+			return fmt.Errorf("workflow %s has unsupported version %q; supported versions: %s", filePath, definition.Version, strings.Join(o.SupportedVersions, ", "))
+		}
+
+		if applied[definition.Version] {
+			return fmt.Errorf("workflow %s: migrations loop back to version %q", filePath, definition.Version)
+		}
+		applied[definition.Version] = true
+
+		if err := step.fn(definition); err != nil {
+			return fmt.Errorf("failed to migrate workflow %s from version %q to %q: %w", filePath, definition.Version, step.to, err)
+		}
+		definition.Version = step.to
+	}
+
+	return nil
+}
+
+// checkStateCount fails if the definition has more than MaxStates states
+func (o LoadOptions) checkStateCount(filePath string, definition *WorkflowDefinition) error {
+	if o.MaxStates > 0 && len(definition.States) > o.MaxStates {
+		return fmt.Errorf("workflow %s defines %d states, more than the limit of %d", filePath, len(definition.States), o.MaxStates)
+	}
+	return nil
+}
+
+// merge merges other into the definition, with other taking precedence, as described
+// for LoadWorkflowDefinition
+func (wd *WorkflowDefinition) merge(other *WorkflowDefinition) {
+	wd.combine(other, false)
+}
+
+// overlay applies other on top of the definition, as described for LoadWorkflowDefinitionWithOverlays
+func (wd *WorkflowDefinition) overlay(other *WorkflowDefinition) {
+	wd.combine(other, true)
+}
+
+// combine applies other on top of the definition. Includes and overlays only differ in
+// their action and deferred event lists, which replaceLists makes overlays replace
+// instead of appending to.
+func (wd *WorkflowDefinition) combine(other *WorkflowDefinition, replaceLists bool) {
+	if other.Version != "" {
+		wd.Version = other.Version
+	}
+	if other.InitialState != "" {
+		wd.InitialState = other.InitialState
+	}
+	if other.ErrorState != "" {
+		wd.ErrorState = other.ErrorState
+	}
+
+	for name, state := range other.States {
+		existing, exists := wd.States[name]
+		if !exists {
+			wd.States[name] = state
+			continue
+		}
+
+		if state.Name != "" {
+			existing.Name = state.Name
+		}
+		existing.IsSideQuest = existing.IsSideQuest || state.IsSideQuest
+		existing.IsFinal = existing.IsFinal || state.IsFinal
+		existing.OnEnter = combineList(existing.OnEnter, state.OnEnter, replaceLists)
+		existing.OnLeave = combineList(existing.OnLeave, state.OnLeave, replaceLists)
+		existing.OnReenter = combineList(existing.OnReenter, state.OnReenter, replaceLists)
+		existing.Transitions = overlayTransitions(existing.Transitions, state.Transitions)
+		existing.DeferredEvents = combineList(existing.DeferredEvents, state.DeferredEvents, replaceLists)
+		if state.Timeout != 0 {
+			existing.Timeout = state.Timeout
+		}
+		if state.TimeoutEvent != "" {
+			existing.TimeoutEvent = state.TimeoutEvent
+		}
+		if state.MaxDwell != 0 {
+			existing.MaxDwell = state.MaxDwell
+		}
+		if state.SubWorkflow != nil {
+			existing.SubWorkflow = state.SubWorkflow
+		}
+		if state.Description != "" {
+			existing.Description = state.Description
+		}
+		if len(state.Metadata) > 0 {
+			metadata := make(map[string]string, len(existing.Metadata)+len(state.Metadata))
+			maps.Copy(metadata, existing.Metadata)
+			maps.Copy(metadata, state.Metadata)
+			existing.Metadata = metadata
+		}
+		wd.States[name] = existing
+	}
+}
+
+// combineList appends other to base, or with replace, returns other in place of base
+// unless it is empty
+func combineList(base, other []string, replace bool) []string {
+	if !replace {
+		return append(base, other...)
+	}
+	if len(other) > 0 {
+		return other
+	}
+	return base
+}
+
+// overlayTransitions replaces the base transitions for every event the overlay declares,
+// keeping the position of the first replaced transition, and appends the rest
+func overlayTransitions(base, overlay []Transition) []Transition {
+	if len(overlay) == 0 {
+		return base
+	}
+
+	byEvent := make(map[string][]Transition)
+	for _, transition := range overlay {
+		byEvent[transition.Event] = append(byEvent[transition.Event], transition)
+	}
+
+	merged := make([]Transition, 0, len(base)+len(overlay))
+	for _, transition := range base {
+		replacements, replaced := byEvent[transition.Event]
+		if !replaced {
+			merged = append(merged, transition)
+			continue
+		}
+		// Only the first base transition for the event is swapped for the overlay's
+		if replacements != nil {
+			merged = append(merged, replacements...)
+			byEvent[transition.Event] = nil
+		}
+	}
+
+	for _, transition := range overlay {
+		if byEvent[transition.Event] != nil {
+			merged = append(merged, transition)
+		}
+	}
+
+	return merged
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} placeholders in s using lookup.
+// $$ produces a literal $, and a $ not followed by { or $ is left untouched.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated placeholder at offset %d", i)
+			}
+			expr := s[i+2 : i+2+end]
+			name, def, hasDefault := strings.Cut(expr, ":-")
+			if name == "" {
+				return "", fmt.Errorf("empty placeholder at offset %d", i)
+			}
+			value, ok := lookup(name)
+			if !ok {
+				if !hasDefault {
+					return "", fmt.Errorf("environment variable %s is not set", name)
+				}
+				value = def
+			}
+			b.WriteString(value)
+			i += 2 + end
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
 }
@@ -1,13 +1,18 @@
 package machina
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
 func TestLoadWorkflowDefinition(t *testing.T) {
 	// Create a temporary YAML file for testing
 	yamlContent := `
+initialState: start
 states:
   start:
     name: start
@@ -33,11 +38,13 @@ states:
 
   complete:
     name: complete
+    isFinal: true
     onEnter:
       - "sendReceipt"
 
   failed:
     name: failed
+    isSideQuest: true
 `
 
 	tmpfile, err := os.CreateTemp("", "workflow*.yaml")
@@ -61,6 +68,10 @@ states:
 	}
 
 	// Verify the loaded definition
+	if definition.InitialState != "start" {
+		t.Errorf("Expected initial state to be 'start', got '%s'", definition.InitialState)
+	}
+
 	if len(definition.States) != 4 {
 		t.Errorf("Expected 4 states, got %d", len(definition.States))
 	}
@@ -88,6 +99,22 @@ states:
 	if definition.States["start"].Transitions[0].Conditions[0] != "isUserValid" {
 		t.Errorf("Expected condition to be 'isUserValid', got %s", definition.States["start"].Transitions[0].Conditions[0])
 	}
+
+	if !definition.States["complete"].IsFinal {
+		t.Error("Expected complete state to be final")
+	}
+
+	if definition.States["start"].IsFinal {
+		t.Error("Expected start state not to be final")
+	}
+
+	if !definition.States["failed"].IsSideQuest {
+		t.Error("Expected failed state to be a side quest")
+	}
+
+	if definition.States["start"].IsSideQuest {
+		t.Error("Expected start state not to be a side quest")
+	}
 }
 
 func TestLoadWorkflowDefinition_FileNotFound(t *testing.T) {
@@ -129,3 +156,711 @@ states:
 		t.Error("Expected error when loading invalid YAML, got nil")
 	}
 }
+
+func TestLoadWorkflowDefinition_ConditionArgs(t *testing.T) {
+	yamlContent := `
+states:
+  start:
+    name: start
+    transitions:
+      - event: "pay"
+        target: "review"
+        conditions:
+          - "isUserValid"
+          - name: "amountGreaterThan"
+            args:
+              min: 100
+        preconditions:
+          - name: "budgetAtLeast"
+            args:
+              amount: 500
+  review:
+    name: review
+`
+
+	tmpfile, err := os.CreateTemp("", "workflow-args*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(yamlContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	definition, err := LoadWorkflowDefinition(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	transition := definition.States["start"].Transitions[0]
+	if len(transition.Conditions) != 2 || transition.Conditions[0] != "isUserValid" || transition.Conditions[1] != "amountGreaterThan" {
+		t.Errorf("Expected conditions [isUserValid amountGreaterThan], got %v", transition.Conditions)
+	}
+
+	if transition.Target != "review" {
+		t.Errorf("Expected target to be 'review', got %s", transition.Target)
+	}
+
+	if _, exists := transition.ConditionArgs["isUserValid"]; exists {
+		t.Error("Expected no arguments for isUserValid")
+	}
+
+	if transition.ConditionArgs["amountGreaterThan"]["min"] != 100 {
+		t.Errorf("Expected min argument to be 100, got %v", transition.ConditionArgs["amountGreaterThan"]["min"])
+	}
+
+	if len(transition.Preconditions) != 1 || transition.Preconditions[0] != "budgetAtLeast" {
+		t.Errorf("Expected preconditions [budgetAtLeast], got %v", transition.Preconditions)
+	}
+
+	if transition.ConditionArgs["budgetAtLeast"]["amount"] != 500 {
+		t.Errorf("Expected precondition amount argument to be 500, got %v", transition.ConditionArgs["budgetAtLeast"]["amount"])
+	}
+}
+
+func TestLoadWorkflowDefinition_DescriptionMetadata(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"workflow.yaml": `
+states:
+  start:
+    name: start
+    description: "Waiting for payment"
+    metadata:
+      owner: billing
+    transitions:
+      - event: "pay"
+        target: "end"
+        description: "Customer pays"
+        metadata:
+          ui.color: green
+        conditions:
+          - name: "amountGreaterThan"
+            args:
+              min: 1
+  end:
+    name: end
+`,
+	})
+
+	definition, err := LoadWorkflowDefinition(filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	start := definition.States["start"]
+	if start.Description != "Waiting for payment" || start.Metadata["owner"] != "billing" {
+		t.Errorf("Expected state description and metadata, got %q %v", start.Description, start.Metadata)
+	}
+
+	transition := start.Transitions[0]
+	if transition.Description != "Customer pays" || transition.Metadata["ui.color"] != "green" {
+		t.Errorf("Expected transition description and metadata, got %q %v", transition.Description, transition.Metadata)
+	}
+
+	if err := definition.Validate(); err != nil {
+		t.Errorf("Expected descriptive fields to pass validation, got %v", err)
+	}
+}
+
+// writeWorkflowFiles writes the given files into a temporary directory and returns its path
+func writeWorkflowFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoadWorkflowDefinition_Include(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"workflow.yaml": `
+include:
+  - shared/cancel.yaml
+initialState: start
+states:
+  start:
+    name: start
+    transitions:
+      - event: "proceed"
+        target: "review"
+  review:
+    name: review
+    transitions:
+      - event: "approve"
+        target: "cancelled"
+`,
+		"shared/cancel.yaml": `
+include:
+  - terminal.yaml
+states:
+  start:
+    name: start
+    transitions:
+      - event: "cancel"
+        target: "cancelled"
+  review:
+    name: review
+    onLeave:
+      - "audit"
+    transitions:
+      - event: "cancel"
+        target: "cancelled"
+`,
+		"shared/terminal.yaml": `
+initialState: cancelled
+states:
+  cancelled:
+    name: cancelled
+    isFinal: true
+`,
+	})
+
+	definition, err := LoadWorkflowDefinition(filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if definition.InitialState != "start" {
+		t.Errorf("Expected including file's initialState 'start', got '%s'", definition.InitialState)
+	}
+
+	if len(definition.States) != 3 {
+		t.Errorf("Expected 3 states, got %d", len(definition.States))
+	}
+
+	if !definition.States["cancelled"].IsFinal {
+		t.Error("Expected cancelled state from nested include to be final")
+	}
+
+	// Included transitions come first, followed by the including file's transitions
+	review := definition.States["review"]
+	if len(review.Transitions) != 2 || review.Transitions[0].Event != "cancel" || review.Transitions[1].Event != "approve" {
+		t.Errorf("Expected review transitions [cancel approve], got %v", review.Transitions)
+	}
+
+	if len(review.OnLeave) != 1 || review.OnLeave[0] != "audit" {
+		t.Errorf("Expected review onLeave [audit], got %v", review.OnLeave)
+	}
+
+	if err := definition.Validate(); err != nil {
+		t.Errorf("Expected merged definition to be valid, got %v", err)
+	}
+}
+
+func TestLoadWorkflowDefinitionWithOverlays(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"base.yaml": `
+initialState: pending
+states:
+  pending:
+    name: pending
+    onEnter:
+      - "notify"
+    transitions:
+      - event: "pay"
+        target: "paid"
+        conditions: ["hasCard"]
+      - event: "pay"
+        target: "failed"
+      - event: "cancel"
+        target: "cancelled"
+  paid:
+    name: paid
+    isFinal: true
+  failed:
+    name: failed
+    isFinal: true
+  cancelled:
+    name: cancelled
+    isFinal: true
+`,
+		"staging.yaml": `
+states:
+  pending:
+    transitions:
+      - event: "pay"
+        target: "paid"
+      - event: "expire"
+        target: "cancelled"
+`,
+		"prod.yaml": `
+states:
+  pending:
+    onEnter:
+      - "notifyOnCall"
+    transitions:
+      - event: "pay"
+        target: "review"
+  review:
+    name: review
+    transitions:
+      - event: "approve"
+        target: "paid"
+`,
+	})
+
+	definition, err := LoadWorkflowDefinitionWithOverlays(filepath.Join(dir, "base.yaml"), filepath.Join(dir, "staging.yaml"), filepath.Join(dir, "prod.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, exists := definition.States["review"]; !exists {
+		t.Error("Expected overlay state 'review' to be added")
+	}
+
+	// Both pay transitions are replaced in place, and the new expire transition is appended
+	pending := definition.States["pending"]
+	var got []string
+	for _, transition := range pending.Transitions {
+		got = append(got, transition.Event+"->"+transition.Target)
+	}
+	expected := []string{"pay->review", "cancel->cancelled", "expire->cancelled"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected transitions %v, got %v", expected, got)
+	}
+
+	if pending.Name != "pending" {
+		t.Errorf("Expected name 'pending' to be kept, got '%s'", pending.Name)
+	}
+	if !slices.Equal(pending.OnEnter, []string{"notifyOnCall"}) {
+		t.Errorf("Expected onEnter to be replaced with [notifyOnCall], got %v", pending.OnEnter)
+	}
+
+	if err := definition.Validate(); err != nil {
+		t.Errorf("Expected overlaid definition to be valid, got %v", err)
+	}
+
+	if _, err := LoadWorkflowDefinitionWithOverlays(filepath.Join(dir, "base.yaml"), filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected error for a missing overlay, got nil")
+	}
+}
+
+func TestLoadWorkflowDefinition_IncludeSharedTwice(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"workflow.yaml": `
+include: [a.yaml, b.yaml]
+states:
+  start:
+    name: start
+`,
+		"a.yaml":      "include: [common.yaml]\n",
+		"b.yaml":      "include: [common.yaml]\n",
+		"common.yaml": "states:\n  start:\n    name: start\n    transitions:\n      - event: cancel\n        target: start\n",
+	})
+
+	definition, err := LoadWorkflowDefinition(filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(definition.States["start"].Transitions) != 1 {
+		t.Errorf("Expected shared include to be merged once, got %d transitions", len(definition.States["start"].Transitions))
+	}
+}
+
+func TestLoadWorkflowDefinition_IncludeOverridesTransitions(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"workflow.yaml": `
+include: [common.yaml]
+states:
+  review:
+    name: review
+    transitions:
+      - event: cancel
+        target: archived
+`,
+		"common.yaml": `
+states:
+  review:
+    name: review
+    transitions:
+      - event: cancel
+        target: cancelled
+        conditions: [isCancellable]
+      - event: cancel
+        target: cancelled
+      - event: approve
+        target: approved
+`,
+	})
+
+	definition, err := LoadWorkflowDefinition(filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The including file's cancel replaces both included ones, in the position of the first
+	var got []string
+	for _, transition := range definition.States["review"].Transitions {
+		got = append(got, transition.Event+"->"+transition.Target)
+	}
+	expected := []string{"cancel->archived", "approve->approved"}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected review transitions %v, got %v", expected, got)
+	}
+}
+
+func TestLoadWorkflowDefinition_IncludeCycle(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"a.yaml": "include: [b.yaml]\n",
+		"b.yaml": "include: [a.yaml]\n",
+	})
+
+	_, err := LoadWorkflowDefinition(filepath.Join(dir, "a.yaml"))
+	if err == nil {
+		t.Fatal("Expected error for include cycle, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "include cycle detected") {
+		t.Errorf("Expected include cycle error, got '%s'", err.Error())
+	}
+}
+
+func TestLoadWorkflowDefinition_IncludeNotFound(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"workflow.yaml": "include: [missing.yaml]\n",
+	})
+
+	if _, err := LoadWorkflowDefinition(filepath.Join(dir, "workflow.yaml")); err == nil {
+		t.Error("Expected error for missing include, got nil")
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{
+		"SERVICE": "payments",
+		"EMPTY":   "",
+	}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectError bool
+		errorMsg    string
+	}{
+		{
+			name:     "Variable",
+			input:    "target: ${SERVICE}",
+			expected: "target: payments",
+		},
+		{
+			name:     "DefaultUnused",
+			input:    "target: ${SERVICE:-fallback}",
+			expected: "target: payments",
+		},
+		{
+			name:     "DefaultUsed",
+			input:    "min: ${THRESHOLD:-100}",
+			expected: "min: 100",
+		},
+		{
+			name:     "SetButEmpty",
+			input:    "value: '${EMPTY:-unused}'",
+			expected: "value: ''",
+		},
+		{
+			name:     "EscapedDollar",
+			input:    "price: $$5 and $${SERVICE}",
+			expected: "price: $5 and ${SERVICE}",
+		},
+		{
+			name:     "BareDollar",
+			input:    "price: $5 $",
+			expected: "price: $5 $",
+		},
+		{
+			name:        "Unset",
+			input:       "target: ${MISSING}",
+			expectError: true,
+			errorMsg:    "environment variable MISSING is not set",
+		},
+		{
+			name:        "Unterminated",
+			input:       "target: ${SERVICE",
+			expectError: true,
+			errorMsg:    "unterminated placeholder at offset 8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := expandEnv(tt.input, lookup)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				} else if err.Error() != tt.errorMsg {
+					t.Errorf("Expected error message '%s', got '%s'", tt.errorMsg, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLoadWorkflowDefinitionWithOptions_ExpandEnv(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"workflow.yaml": `
+include: [shared.yaml]
+states:
+  start:
+    name: start
+    transitions:
+      - event: "proceed"
+        target: "${GOMACHINA_TEST_TARGET}"
+`,
+		"shared.yaml": "states:\n  ${GOMACHINA_TEST_TARGET}:\n    name: ${GOMACHINA_TEST_TARGET}\n",
+	})
+	t.Setenv("GOMACHINA_TEST_TARGET", "end")
+
+	definition, err := LoadWorkflowDefinitionWithOptions(filepath.Join(dir, "workflow.yaml"), LoadOptions{ExpandEnv: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if definition.States["start"].Transitions[0].Target != "end" {
+		t.Errorf("Expected target to be 'end', got '%s'", definition.States["start"].Transitions[0].Target)
+	}
+
+	if _, exists := definition.States["end"]; !exists {
+		t.Error("Expected state 'end' from expanded include")
+	}
+
+	// Plain loads leave placeholders untouched
+	definition, err = LoadWorkflowDefinition(filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if definition.States["start"].Transitions[0].Target != "${GOMACHINA_TEST_TARGET}" {
+		t.Errorf("Expected placeholder to be left untouched, got '%s'", definition.States["start"].Transitions[0].Target)
+	}
+}
+
+func TestLoadWorkflowDefinitions(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"orders.yaml": `
+initialState: pending
+states:
+  pending:
+    name: pending
+`,
+		"shipping.yml": `
+initialState: packed
+states:
+  packed:
+    name: packed
+`,
+		"broken.yaml":   "states: [",
+		"invalid.yaml":  "initialState: missing\nstates:\n  start:\n    name: start\n",
+		"notes.txt":     "not a workflow",
+		"shared/a.yaml": "states: {}",
+		"orders.yml":    "states: {}",
+	})
+
+	definitions, err := LoadWorkflowDefinitions(dir)
+	if err == nil {
+		t.Fatal("Expected errors for the bad files, got nil")
+	}
+
+	for _, name := range []string{"orders", "shipping", "invalid"} {
+		if definitions[name] == nil {
+			t.Errorf("Expected workflow %s to be loaded", name)
+		}
+	}
+
+	if len(definitions) != 3 {
+		t.Errorf("Expected 3 workflows, got %d", len(definitions))
+	}
+
+	if definitions["shipping"].InitialState != "packed" {
+		t.Errorf("Expected shipping initial state to be packed, got %s", definitions["shipping"].InitialState)
+	}
+
+	for _, fragment := range []string{"broken.yaml: failed to unmarshal YAML", "invalid.yaml: invalid workflow definition", "orders.yml: workflow orders is already defined"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Errorf("Expected error to mention %q, got %v", fragment, err)
+		}
+	}
+}
+
+func TestLoadWorkflowDefinitions_DirectoryNotFound(t *testing.T) {
+	if _, err := LoadWorkflowDefinitions(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for missing directory, got nil")
+	}
+}
+
+func TestLoadWorkflowDefinitionWithOptions_Limits(t *testing.T) {
+	workflow := "states:\n  a:\n    name: a\n  b:\n    name: b\n  c:\n    name: c\n"
+	dir := writeWorkflowFiles(t, map[string]string{
+		"workflow.yaml":  workflow,
+		"parent.yaml":    "include: [child.yaml]\nstates:\n  d:\n    name: d\n",
+		"child.yaml":     "include: [workflow.yaml]\n",
+		"expanding.yaml": "states:\n  a:\n    name: ${NAME}\n",
+	})
+	path := filepath.Join(dir, "workflow.yaml")
+
+	tests := []struct {
+		name          string
+		file          string
+		options       LoadOptions
+		expectedError string
+	}{
+		{name: "WithinLimits", file: "workflow.yaml", options: LoadOptions{MaxBytes: int64(len(workflow)), MaxStates: 3, MaxIncludeDepth: 1}},
+		{name: "Oversized", file: "workflow.yaml", options: LoadOptions{MaxBytes: 16}, expectedError: fmt.Sprintf("file %s exceeds the limit of 16 bytes", path)},
+		{name: "OversizedIncludeTree", file: "parent.yaml", options: LoadOptions{MaxBytes: int64(len(workflow))}, expectedError: fmt.Sprintf("failed to load include child.yaml: files included by %s exceed the limit of %d bytes in total", filepath.Join(dir, "parent.yaml"), len(workflow))},
+		{name: "OversizedAfterExpansion", file: "expanding.yaml", options: LoadOptions{MaxBytes: 64, ExpandEnv: true, LookupEnv: func(string) (string, bool) { return strings.Repeat("x", 64), true }}, expectedError: fmt.Sprintf("file %s exceeds the limit of 64 bytes after expanding environment variables", filepath.Join(dir, "expanding.yaml"))},
+		{name: "TooManyStates", file: "workflow.yaml", options: LoadOptions{MaxStates: 2}, expectedError: fmt.Sprintf("workflow %s defines 3 states, more than the limit of 2", path)},
+		{name: "TooManyMergedStates", file: "parent.yaml", options: LoadOptions{MaxStates: 3}, expectedError: fmt.Sprintf("workflow %s defines 4 states, more than the limit of 3", filepath.Join(dir, "parent.yaml"))},
+		{name: "IncludesTooDeep", file: "parent.yaml", options: LoadOptions{MaxIncludeDepth: 1}, expectedError: "failed to load include child.yaml: failed to load include workflow.yaml: include depth exceeds the limit of 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadWorkflowDefinitionWithOptions(filepath.Join(dir, tt.file), tt.options)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadWorkflowDefinitionWithOptions_IncludeConfinement(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"secrets.yaml":                "states:\n  leaked:\n    name: leaked\n",
+		"workflows/common.yaml":       "states:\n  common:\n    name: common\n",
+		"workflows/shared/inner.yaml": "include: [../common.yaml]\n",
+		"workflows/nested.yaml":       "include: [shared/inner.yaml]\n",
+		"workflows/escaping.yaml":     "include: [../secrets.yaml]\n",
+	})
+	secrets := filepath.Join(dir, "secrets.yaml")
+	absolute := fmt.Sprintf("include: [%q]\n", secrets)
+	if err := os.WriteFile(filepath.Join(dir, "workflows", "absolute.yaml"), []byte(absolute), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	workflows, err := filepath.EvalSymlinks(filepath.Join(dir, "workflows"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		file          string
+		options       LoadOptions
+		expectedError string
+	}{
+		{name: "EscapingAllowedByDefault", file: "escaping.yaml"},
+		{name: "NestedWithinRoot", file: "nested.yaml", options: LoadOptions{ConfineIncludes: true}},
+		{name: "Escaping", file: "escaping.yaml", options: LoadOptions{ConfineIncludes: true}, expectedError: fmt.Sprintf("include ../secrets.yaml resolves outside %s", workflows)},
+		{name: "Absolute", file: "absolute.yaml", options: LoadOptions{ConfineIncludes: true}, expectedError: fmt.Sprintf("include %s resolves outside %s", secrets, workflows)},
+		{name: "Disabled", file: "nested.yaml", options: LoadOptions{DisableIncludes: true}, expectedError: fmt.Sprintf("workflow %s uses include, which is disabled", filepath.Join(dir, "workflows", "nested.yaml"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadWorkflowDefinitionWithOptions(filepath.Join(dir, "workflows", tt.file), tt.options)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadWorkflowDefinitionWithOptions_SchemaVersion(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"v1.yaml": "version: 1\nstates:\n  start:\n    name: start\n    transitions:\n      - event: go\n        target: done\n  done:\n    name: done\n",
+		"v3.yaml": "version: \"3\"\nstates:\n  start:\n    name: start\n",
+	})
+
+	// Version 1 named the event "go"; version 2 renamed it to "proceed"
+	unmigrated := LoadOptions{}.WithSchemaVersion("2")
+	options, err := unmigrated.RegisterMigration("1", "2", func(definition *WorkflowDefinition) error {
+		for name, state := range definition.States {
+			for i := range state.Transitions {
+				if state.Transitions[i].Event == "go" {
+					state.Transitions[i].Event = "proceed"
+				}
+			}
+			definition.States[name] = state
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := options.RegisterMigration("1", "3", func(*WorkflowDefinition) error { return nil }); err == nil {
+		t.Error("Expected error registering a second migration from version 1, got nil")
+	}
+
+	// Registering on a copy leaves the options it was made from unchanged
+	if _, err := unmigrated.WithSchemaVersion("2").RegisterMigration("1", "3", func(*WorkflowDefinition) error { return nil }); err != nil {
+		t.Errorf("Expected no error registering on unmigrated options, got %v", err)
+	}
+	if _, err := LoadWorkflowDefinitionWithOptions(filepath.Join(dir, "v1.yaml"), unmigrated); err == nil {
+		t.Error("Expected version 1 to be rejected without a migration, got nil")
+	}
+
+	definition, err := LoadWorkflowDefinitionWithOptions(filepath.Join(dir, "v1.yaml"), options)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if definition.Version != "2" {
+		t.Errorf("Expected version 2, got %q", definition.Version)
+	}
+
+	if event := definition.States["start"].Transitions[0].Event; event != "proceed" {
+		t.Errorf("Expected migrated event 'proceed', got '%s'", event)
+	}
+
+	path := filepath.Join(dir, "v3.yaml")
+	_, err = LoadWorkflowDefinitionWithOptions(path, options)
+	expected := fmt.Sprintf(`workflow %s has unsupported version "3"; supported versions: 2`, path)
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+
+	// Without supported versions, any version loads unchanged
+	definition, err = LoadWorkflowDefinition(path)
+	if err != nil || definition.Version != "3" {
+		t.Errorf("Expected version 3 to load, got %v (%v)", definition, err)
+	}
+}
@@ -0,0 +1,93 @@
+package machina
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+)
+
+// WithLogLevel sets the minimum level of the records the StateMachine logs, on top of
+// whatever the logger's handler already filters. Routine steps such as each action
+// invocation are logged at Debug, and each completed transition is summarized at Info.
+func WithLogLevel(level slog.Leveler) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.logLevel = level
+	}
+}
+
+// levelHandler drops records below a minimum level before passing them to its handler
+type levelHandler struct {
+	level   slog.Leveler
+	handler slog.Handler
+}
+
+// Enabled implements slog.Handler
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, handler: h.handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, handler: h.handler.WithGroup(name)}
+}
+
+// RedactedValue replaces the values of keys given to WithRedactedKeys in logged data
+const RedactedValue = "***"
+
+// WithRedactedKeys masks the given top-level data keys, such as an email address, in the
+// payloads and action updates the StateMachine logs. Actions still receive the real values.
+func WithRedactedKeys(keys ...string) StateMachineOption {
+	return func(sm *StateMachine) {
+		if sm.redactedKeys == nil {
+			sm.redactedKeys = make(map[string]bool, len(keys))
+		}
+		for _, key := range keys {
+			sm.redactedKeys[key] = true
+		}
+	}
+}
+
+// redactedData logs a data map with its redacted keys masked. The copy is only made when
+// a handler actually records the value.
+type redactedData struct {
+	data map[string]any
+	keys map[string]bool
+}
+
+// logData returns data for logging with the machine's redacted keys masked. Without
+// redacted keys the map is logged as is, so the hot path doesn't allocate.
+func (sm *StateMachine) logData(data map[string]any) any {
+	if len(sm.redactedKeys) == 0 {
+		return data
+	}
+	return redactedData{data: data, keys: sm.redactedKeys}
+}
+
+// LogValue implements slog.LogValuer
+func (d redactedData) LogValue() slog.Value {
+	var redacted map[string]any
+	for key := range d.keys {
+		if _, exists := d.data[key]; !exists {
+			continue
+		}
+		if redacted == nil {
+			redacted = maps.Clone(d.data)
+		}
+		redacted[key] = RedactedValue
+	}
+
+	if redacted == nil {
+		return slog.AnyValue(d.data)
+	}
+	return slog.AnyValue(redacted)
+}
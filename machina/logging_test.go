@@ -0,0 +1,148 @@
+package machina
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logRecords decodes the JSON log lines written to buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestStateMachine_TransitionLogging(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:    "start",
+				OnLeave: []string{"noopAction"},
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end", Conditions: []string{"alwaysTrue"}, Actions: []string{"updateAction"}},
+				},
+			},
+			"end": {
+				Name:    "end",
+				OnEnter: []string{"noopAction"},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("alwaysTrue", MockTrueCondition)
+	registry.RegisterAction("updateAction", MockUpdateAction)
+	registry.RegisterAction("noopAction", MockNoOpAction)
+
+	tests := []struct {
+		name            string
+		handlerLevel    slog.Level
+		opts            []StateMachineOption
+		expectedRecords int
+	}{
+		{name: "InfoSummaryOnly", handlerLevel: slog.LevelInfo, expectedRecords: 1},
+		{name: "DebugIncludesSteps", handlerLevel: slog.LevelDebug, expectedRecords: 9},
+		{name: "WithLogLevel", handlerLevel: slog.LevelDebug, opts: []StateMachineOption{WithLogLevel(slog.LevelWarn)}, expectedRecords: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: tt.handlerLevel}))
+			fsm := NewStateMachine(definition, registry, logger, tt.opts...)
+
+			ctx := WithWorkflowID(context.Background(), "order-7")
+			if _, err := fsm.Trigger(ctx, "start", "proceed", map[string]any{}); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			records := logRecords(t, &buf)
+			if len(records) != tt.expectedRecords {
+				t.Fatalf("Expected %d log records, got %d:\n%s", tt.expectedRecords, len(records), buf.String())
+			}
+
+			for _, record := range records {
+				if record["from"] != "start" || record["event"] != "proceed" || record["workflow_id"] != "order-7" || record["txn_id"] == nil {
+					t.Errorf("Expected transition attributes on every record, got %v", record)
+				}
+			}
+
+			if len(records) > 0 {
+				last := records[len(records)-1]
+				if last["msg"] != "Transition completed" || last["level"] != "INFO" || last["to"] != "end" {
+					t.Errorf("Expected Info summary as last record, got %v", last)
+				}
+			}
+		})
+	}
+}
+
+func TestStateMachine_RedactedKeys(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:        "start",
+				Transitions: []Transition{{Event: "register", Target: "registered", Actions: []string{"sendWelcome"}}},
+			},
+			"registered": {Name: "registered"},
+		},
+	}
+
+	var seenEmail any
+	registry := NewRegistry()
+	registry.RegisterAction("sendWelcome", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		seenEmail = data["email"]
+		return map[string]any{"email": "new@example.com", "welcomed": true}, nil
+	})
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	fsm := NewStateMachine(definition, registry, logger, WithRedactedKeys("email"))
+
+	result, err := fsm.Trigger(context.Background(), "start", "register", map[string]any{"email": "jane@example.com", "plan": "pro"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if seenEmail != "jane@example.com" {
+		t.Errorf("Expected the action to see the real email, got %v", seenEmail)
+	}
+	if result.PersistenceData["email"] != "new@example.com" {
+		t.Errorf("Expected the real email in the persistence data, got %v", result.PersistenceData["email"])
+	}
+	if strings.Contains(buf.String(), "@example.com") {
+		t.Errorf("Expected no email address in the logs, got:\n%s", buf.String())
+	}
+
+	var logged int
+	for _, record := range logRecords(t, &buf) {
+		for _, key := range []string{"payload", "updates"} {
+			data, ok := record[key].(map[string]any)
+			if !ok {
+				continue
+			}
+			logged++
+			if data["email"] != RedactedValue {
+				t.Errorf("Expected email logged as %s in %s, got %v", RedactedValue, key, data["email"])
+			}
+		}
+	}
+	if logged != 2 {
+		t.Errorf("Expected the payload and the action updates to be logged, got %d", logged)
+	}
+}
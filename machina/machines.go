@@ -0,0 +1,57 @@
+package machina
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MachineRegistry holds state machines by workflow name, so a service running several
+// workflows can pick the right machine at request time
+type MachineRegistry struct {
+	machines map[string]*StateMachine
+	mu       sync.RWMutex
+}
+
+// NewMachineRegistry creates a new machine registry
+func NewMachineRegistry() *MachineRegistry {
+	return &MachineRegistry{
+		machines: make(map[string]*StateMachine),
+	}
+}
+
+// Register registers a state machine under the given workflow name
+func (r *MachineRegistry) Register(name string, sm *StateMachine) error {
+	if sm == nil {
+		return fmt.Errorf("machine %s must not be nil", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.machines[name]; exists {
+		return fmt.Errorf("machine %s already registered", name)
+	}
+
+	r.machines[name] = sm
+	return nil
+}
+
+// Get retrieves the state machine registered under the given workflow name
+func (r *MachineRegistry) Get(name string) (*StateMachine, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if sm, exists := r.machines[name]; exists {
+		return sm, nil
+	}
+
+	return nil, fmt.Errorf("machine %s not found", name)
+}
+
+// Names returns the registered workflow names in sorted order
+func (r *MachineRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return sortedKeys(r.machines)
+}
@@ -0,0 +1,68 @@
+package machina
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMachineRegistry(t *testing.T) {
+	definition := &WorkflowDefinition{States: map[string]State{"start": {Name: "start"}}}
+	orders := NewStateMachine(definition, NewRegistry(), nil)
+	shipping := NewStateMachine(definition, NewRegistry(), nil)
+
+	machines := NewMachineRegistry()
+	if err := machines.Register("orders", orders); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := machines.Register("shipping", shipping); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := machines.Register("orders", shipping); err == nil || err.Error() != "machine orders already registered" {
+		t.Errorf("Expected duplicate registration error, got %v", err)
+	}
+
+	if err := machines.Register("nil", nil); err == nil {
+		t.Error("Expected error registering a nil machine, got nil")
+	}
+
+	sm, err := machines.Get("orders")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sm != orders {
+		t.Error("Expected the registered orders machine")
+	}
+
+	if _, err := machines.Get("missing"); err == nil || err.Error() != "machine missing not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	if names := machines.Names(); fmt.Sprint(names) != "[orders shipping]" {
+		t.Errorf("Expected [orders shipping], got %v", names)
+	}
+}
+
+func TestMachineRegistry_ConcurrentAccess(t *testing.T) {
+	definition := &WorkflowDefinition{States: map[string]State{"start": {Name: "start"}}}
+	machines := NewMachineRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			machines.Register(fmt.Sprintf("workflow%d", i), NewStateMachine(definition, NewRegistry(), nil))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			machines.Get(fmt.Sprintf("workflow%d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	if len(machines.Names()) != 10 {
+		t.Errorf("Expected 10 machines, got %d", len(machines.Names()))
+	}
+}
@@ -0,0 +1,132 @@
+package machina
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MatchMode selects how a transition's Event is compared with a triggered event
+type MatchMode string
+
+const (
+	// MatchExact matches the event itself. It is the default when Match is empty.
+	MatchExact MatchMode = "exact"
+	// MatchPrefix matches any event that starts with Event, such as "webhook.payment."
+	MatchPrefix MatchMode = "prefix"
+	// MatchRegex matches any event the regular expression in Event matches in full
+	MatchRegex MatchMode = "regex"
+)
+
+// isExact reports whether the transition's Event is compared literally
+func (t *Transition) isExact() bool {
+	return t.Match == "" || t.Match == MatchExact
+}
+
+// eventPattern compiles the regular expression of a MatchRegex transition, anchored so
+// that it must match the whole event
+func (t *Transition) eventPattern() (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile(`^(?:` + t.Event + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event pattern %s: %w", t.Event, err)
+	}
+	return pattern, nil
+}
+
+// validateMatch checks the transition's match mode and, for MatchRegex, its pattern
+func (t *Transition) validateMatch() error {
+	switch t.Match {
+	case "", MatchExact, MatchPrefix:
+		return nil
+	case MatchRegex:
+		_, err := t.eventPattern()
+		return err
+	default:
+		return fmt.Errorf("unknown match mode %s", t.Match)
+	}
+}
+
+// compileEventPatterns compiles the patterns of every MatchRegex transition in the
+// definition, keyed by pattern
+func compileEventPatterns(wd *WorkflowDefinition) (map[string]*regexp.Regexp, error) {
+	patterns := make(map[string]*regexp.Regexp)
+	for _, name := range wd.sortedStateNames() {
+		for _, transition := range wd.States[name].Transitions {
+			if transition.Match != MatchRegex {
+				continue
+			}
+			if _, exists := patterns[transition.Event]; exists {
+				continue
+			}
+			pattern, err := transition.eventPattern()
+			if err != nil {
+				return nil, fmt.Errorf("state %s: %w", name, err)
+			}
+			patterns[transition.Event] = pattern
+		}
+	}
+	return patterns, nil
+}
+
+// matchingTransitions returns the indices, in declaration order, of the state's
+// transitions for the event. Exact matches take precedence over prefix matches, then
+// regex matches, then wildcard transitions; only the first kind that matches is returned.
+// Precedence is decided by the event alone, before any guard runs: if the guards reject
+// every transition of that kind, the event fails rather than falling back to the next kind.
+// A nil patterns map compiles regex patterns as needed.
+func (s *State) matchingTransitions(event string, patterns map[string]*regexp.Regexp) []int {
+	var exact, prefix, regex, wildcard []int
+	for i := range s.Transitions {
+		transition := &s.Transitions[i]
+		switch transition.Match {
+		case MatchPrefix:
+			if strings.HasPrefix(event, transition.Event) {
+				prefix = append(prefix, i)
+			}
+		case MatchRegex:
+			pattern, ok := patterns[transition.Event]
+			if !ok {
+				var err error
+				if pattern, err = transition.eventPattern(); err != nil {
+					continue
+				}
+			}
+			if pattern.MatchString(event) {
+				regex = append(regex, i)
+			}
+		default:
+			if transition.Event == event {
+				exact = append(exact, i)
+			} else if transition.Event == WildcardEvent {
+				wildcard = append(wildcard, i)
+			}
+		}
+	}
+
+	for _, indices := range [][]int{exact, prefix, regex, wildcard} {
+		if len(indices) > 0 {
+			return indices
+		}
+	}
+	return nil
+}
+
+// acceptsEvent reports whether triggering the event in the state would find a transition,
+// through an exact, prefix, regex or wildcard match
+func (s *State) acceptsEvent(event string) bool {
+	return len(s.matchingTransitions(event, nil)) > 0
+}
+
+// exactEvents returns the events the state handles by exact match, in declaration order,
+// leaving out patterns and the wildcard
+func (s *State) exactEvents() []string {
+	var events []string
+	for i := range s.Transitions {
+		event := s.Transitions[i].Event
+		if s.Transitions[i].isExact() && event != WildcardEvent && !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -0,0 +1,104 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestStateMachine_Trigger_MatchModes(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"listening": {
+				Name: "listening",
+				Transitions: []Transition{
+					{Event: "webhook.payment.refunded", Target: "refunded"},
+					{Event: "webhook.payment.", Match: MatchPrefix, Target: "payment"},
+					{Event: `webhook\.(order|cart)\.[a-z_]+`, Match: MatchRegex, Target: "order"},
+					{Event: WildcardEvent, Target: "ignored"},
+				},
+			},
+			"refunded": {Name: "refunded"},
+			"payment":  {Name: "payment"},
+			"order":    {Name: "order"},
+			"ignored":  {Name: "ignored"},
+		},
+	}
+
+	sm, err := NewStateMachineE(definition, NewRegistry(), slog.Default())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		event    string
+		expected string
+	}{
+		{"webhook.payment.refunded", "refunded"},
+		{"webhook.payment.succeeded", "payment"},
+		{"webhook.order.created", "order"},
+		{"webhook.order.Created", "ignored"},
+		{"cron.tick", "ignored"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			result, err := sm.Trigger(context.Background(), "listening", tt.event, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.NewState != tt.expected {
+				t.Errorf("Expected new state %s, got %s", tt.expected, result.NewState)
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_MatchPrecedenceIgnoresGuards(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"listening": {
+				Name: "listening",
+				Transitions: []Transition{
+					{Event: "webhook.payment.refunded", Target: "refunded", Conditions: []string{"never"}},
+					{Event: "webhook.payment.", Match: MatchPrefix, Target: "payment"},
+					{Event: WildcardEvent, Target: "ignored"},
+				},
+			},
+			"refunded": {Name: "refunded"},
+			"payment":  {Name: "payment"},
+			"ignored":  {Name: "ignored"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("never", MockFalseCondition)
+	sm := NewStateMachine(definition, registry, slog.Default())
+
+	// The exact transition's guard fails, and neither the prefix nor the wildcard transition
+	// is tried in its place
+	_, err := sm.Trigger(context.Background(), "listening", "webhook.payment.refunded", nil)
+	if !errors.Is(err, ErrTransitionNotFound) {
+		t.Errorf("Expected ErrTransitionNotFound, got %v", err)
+	}
+}
+
+func TestNewStateMachineE_InvalidEventPattern(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "webhook.[", Match: MatchRegex, Target: "start"},
+				},
+			},
+		},
+	}
+
+	_, err := NewStateMachineE(definition, NewRegistry(), slog.Default())
+	if err == nil || !strings.Contains(err.Error(), "invalid event pattern webhook.[") {
+		t.Errorf("Expected an invalid event pattern error, got %v", err)
+	}
+}
@@ -1,51 +1,181 @@
 package machina
 
 import (
+	"context"
+	"unicode/utf8"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 // Metrics holds all the Prometheus metrics for the FSM
 type Metrics struct {
-	TransitionsTotal     *prometheus.CounterVec
-	TransitionErrors     *prometheus.CounterVec
-	TransitionDuration   *prometheus.HistogramVec
+	TransitionsTotal   *prometheus.CounterVec
+	TransitionErrors   *prometheus.CounterVec
+	TransitionDuration *prometheus.HistogramVec
+	// AutoTransitionsTotal counts auto events actually fired by WithAutoEventChaining,
+	// labeled with the auto event and the states it moved between
 	AutoTransitionsTotal *prometheus.CounterVec
+	// AutoEventsDeclaredTotal counts transitions that returned an auto event to fire next,
+	// whether or not it was then fired
+	AutoEventsDeclaredTotal *prometheus.CounterVec
+	// AutoChainDepth observes how many auto events each Trigger call followed when
+	// WithAutoEventChaining is enabled, labeled with the state the chain started from
+	AutoChainDepth *prometheus.HistogramVec
+	// StatesCurrent reflects the net movement of workflow instances between states.
+	// Since the machine is stateless, instances only show up in their first state
+	// once StateMachine.RecordInstanceStarted is called for them.
+	StatesCurrent             *prometheus.GaugeVec
+	ConditionEvaluationsTotal *prometheus.CounterVec
+	// ActionDuration times each action invocation by phase (onleave, onenter or transition)
+	ActionDuration *prometheus.HistogramVec
+}
+
+// MetricsConfig customizes the metrics created by NewMetricsWithConfig
+type MetricsConfig struct {
+	// DurationBuckets are the histogram buckets for TransitionDuration and ActionDuration, in seconds.
+	// Defaults to prometheus.DefBuckets.
+	DurationBuckets []float64
+	// WorkflowName, if set, is added to every metric as the constant label "workflow", so
+	// machines running different workflows can share a registry and dashboards
+	WorkflowName string
 }
 
 // NewMetrics creates a new Metrics instance with all the required metrics
 func NewMetrics(reg prometheus.Registerer) *Metrics {
+	return NewMetricsWithConfig(reg, MetricsConfig{})
+}
+
+// NewMetricsWithConfig creates a new Metrics instance customized by the given config
+func NewMetricsWithConfig(reg prometheus.Registerer, config MetricsConfig) *Metrics {
+	buckets := config.DurationBuckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	var constLabels prometheus.Labels
+	if config.WorkflowName != "" {
+		constLabels = prometheus.Labels{"workflow": config.WorkflowName}
+	}
+
 	m := &Metrics{
 		TransitionsTotal: promauto.With(reg).NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "gomachina_transitions_total",
-				Help: "Total number of state transitions",
+				Name:        "gomachina_transitions_total",
+				Help:        "Total number of state transitions",
+				ConstLabels: constLabels,
 			},
 			[]string{"from_state", "to_state", "event"},
 		),
 		TransitionErrors: promauto.With(reg).NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "gomachina_transition_errors_total",
-				Help: "Total number of transition errors",
+				Name:        "gomachina_transition_errors_total",
+				Help:        "Total number of transition errors",
+				ConstLabels: constLabels,
 			},
 			[]string{"from_state", "event", "error_type"},
 		),
 		TransitionDuration: promauto.With(reg).NewHistogramVec(
 			prometheus.HistogramOpts{
-				Name:    "gomachina_transition_duration_seconds",
-				Help:    "Duration of state transitions in seconds",
-				Buckets: prometheus.DefBuckets,
+				Name:        "gomachina_transition_duration_seconds",
+				Help:        "Duration of state transitions in seconds",
+				ConstLabels: constLabels,
+				Buckets:     buckets,
 			},
 			[]string{"from_state", "to_state", "event"},
 		),
 		AutoTransitionsTotal: promauto.With(reg).NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "gomachina_auto_transitions_total",
-				Help: "Total number of automatic transitions",
+				Name:        "gomachina_auto_transitions_total",
+				Help:        "Total number of auto events fired by auto-event chaining",
+				ConstLabels: constLabels,
 			},
 			[]string{"from_state", "to_state", "event"},
 		),
+		AutoEventsDeclaredTotal: promauto.With(reg).NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "gomachina_auto_events_declared_total",
+				Help:        "Total number of transitions that returned an auto event",
+				ConstLabels: constLabels,
+			},
+			[]string{"from_state", "to_state", "event"},
+		),
+		AutoChainDepth: promauto.With(reg).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "gomachina_auto_chain_depth",
+				Help:        "Number of auto events followed per Trigger call with auto-event chaining",
+				ConstLabels: constLabels,
+				Buckets:     []float64{0, 1, 2, 3, 5, 8, 13, 21},
+			},
+			[]string{"from_state"},
+		),
+		StatesCurrent: promauto.With(reg).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "gomachina_states_current",
+				Help:        "Net number of workflow instances currently in each state",
+				ConstLabels: constLabels,
+			},
+			[]string{"state"},
+		),
+		ConditionEvaluationsTotal: promauto.With(reg).NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "gomachina_condition_evaluations_total",
+				Help:        "Total number of condition evaluations by result (pass, fail or error)",
+				ConstLabels: constLabels,
+			},
+			[]string{"condition", "result"},
+		),
+		ActionDuration: promauto.With(reg).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "gomachina_action_duration_seconds",
+				Help:        "Duration of action invocations in seconds by phase",
+				ConstLabels: constLabels,
+				Buckets:     buckets,
+			},
+			[]string{"phase", "action"},
+		),
 	}
 
 	return m
 }
+
+// exemplarLabels builds the exemplar labels identifying the transition in ctx.
+// The workflow ID is omitted if it would exceed the exemplar size limit.
+func exemplarLabels(ctx context.Context) prometheus.Labels {
+	transitionID, ok := TransitionIDFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	labels := prometheus.Labels{"transition_id": transitionID}
+	if workflowID, ok := WorkflowIDFromContext(ctx); ok && workflowID != "" {
+		size := 0
+		for name, value := range labels {
+			size += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+		}
+		size += utf8.RuneCountInString("workflow_id") + utf8.RuneCountInString(workflowID)
+		if size <= prometheus.ExemplarMaxRunes {
+			labels["workflow_id"] = workflowID
+		}
+	}
+
+	return labels
+}
+
+// addWithExemplar increments the counter, attaching the exemplar if supported
+func addWithExemplar(counter prometheus.Counter, exemplar prometheus.Labels) {
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		adder.AddWithExemplar(1, exemplar)
+		return
+	}
+	counter.Inc()
+}
+
+// observeWithExemplar records the observation, attaching the exemplar if supported
+func observeWithExemplar(observer prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(value, exemplar)
+		return
+	}
+	observer.Observe(value)
+}
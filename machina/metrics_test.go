@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/trace/noop"
@@ -119,6 +120,15 @@ func TestMetricsAutoTransition(t *testing.T) {
 			},
 			"end": {
 				Name: "end",
+				Transitions: []Transition{
+					{
+						Event:  "auto",
+						Target: "done",
+					},
+				},
+			},
+			"done": {
+				Name: "done",
 			},
 		},
 	}
@@ -145,6 +155,470 @@ func TestMetricsAutoTransition(t *testing.T) {
 	}
 }
 
+func TestMetricsAutoChainDepth(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	// start -(next)-> a -(e1)-> b -(e2)-> c -(e3)-> done
+	states := map[string]State{"done": {Name: "done"}}
+	chain := []struct{ name, event, target, autoEvent string }{
+		{"start", "next", "a", "e1"},
+		{"a", "e1", "b", "e2"},
+		{"b", "e2", "c", "e3"},
+		{"c", "e3", "done", ""},
+	}
+	for _, step := range chain {
+		states[step.name] = State{
+			Name:        step.name,
+			Transitions: []Transition{{Event: step.event, Target: step.target, AutoEvent: step.autoEvent}},
+		}
+	}
+
+	sm := NewStateMachine(&WorkflowDefinition{States: states}, NewRegistry(), slog.Default(),
+		WithMetrics(reg), WithAutoEventChaining(10), WithTracer(noop.NewTracerProvider().Tracer("test")))
+
+	result, err := sm.Trigger(context.Background(), "start", "next", map[string]any{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.NewState != "done" {
+		t.Fatalf("Expected state 'done', got '%s'", result.NewState)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	totals := map[string]float64{}
+	var depthCount uint64
+	var depthSum float64
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch family.GetName() {
+			case "gomachina_auto_chain_depth":
+				depthCount += metric.GetHistogram().GetSampleCount()
+				depthSum += metric.GetHistogram().GetSampleSum()
+			case "gomachina_auto_transitions_total", "gomachina_auto_events_declared_total":
+				totals[family.GetName()] += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	if depthCount != 1 || depthSum != 3 {
+		t.Errorf("Expected one chain depth observation of 3, got %d observations summing to %v", depthCount, depthSum)
+	}
+	if totals["gomachina_auto_transitions_total"] != 3 {
+		t.Errorf("Expected 3 fired auto transitions, got %v", totals["gomachina_auto_transitions_total"])
+	}
+	if totals["gomachina_auto_events_declared_total"] != 3 {
+		t.Errorf("Expected 3 declared auto events, got %v", totals["gomachina_auto_events_declared_total"])
+	}
+}
+
+func TestMetricsExemplar(t *testing.T) {
+	// Create a test registry
+	reg := prometheus.NewRegistry()
+
+	// Create a simple workflow definition
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "next",
+						Target: "end",
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	// Create the state machine with metrics
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default(), WithMetrics(reg), WithTracer(noop.NewTracerProvider().Tracer("test")))
+
+	// Perform a transition with a workflow ID
+	ctx := WithWorkflowID(context.Background(), "order-42")
+	if _, err := sm.Trigger(ctx, "start", "next", map[string]any{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	found := false
+	for _, family := range families {
+		if family.GetName() != "gomachina_transitions_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetCounter().GetExemplar().GetLabel() {
+				if label.GetName() == "workflow_id" && label.GetValue() == "order-42" {
+					found = true
+				}
+			}
+		}
+	}
+
+	if !found {
+		t.Error("Expected transitions counter exemplar with workflow_id 'order-42'")
+	}
+}
+
+func TestMetricsBuckets(t *testing.T) {
+	// Create a test registry
+	reg := prometheus.NewRegistry()
+
+	// Create a simple workflow definition
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "next",
+						Target: "end",
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	// Options are order independent: buckets may be supplied before WithMetrics
+	buckets := []float64{0.0001, 0.001, 5, 30}
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default(), WithMetricsBuckets(buckets), WithMetrics(reg), WithTracer(noop.NewTracerProvider().Tracer("test")))
+
+	if _, err := sm.Trigger(context.Background(), "start", "next", map[string]any{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	var upperBounds []float64
+	for _, family := range families {
+		if family.GetName() != "gomachina_transition_duration_seconds" {
+			continue
+		}
+		for _, bucket := range family.GetMetric()[0].GetHistogram().GetBucket() {
+			upperBounds = append(upperBounds, bucket.GetUpperBound())
+		}
+	}
+
+	if len(upperBounds) != len(buckets) {
+		t.Fatalf("Expected %d buckets, got %v", len(buckets), upperBounds)
+	}
+	for i, bound := range buckets {
+		if upperBounds[i] != bound {
+			t.Errorf("Expected bucket %d upper bound %v, got %v", i, bound, upperBounds[i])
+		}
+	}
+}
+
+func TestMetricsStatesCurrent(t *testing.T) {
+	// Create a test registry
+	reg := prometheus.NewRegistry()
+
+	// Create a workflow definition with two hops
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "next",
+						Target: "middle",
+					},
+				},
+			},
+			"middle": {
+				Name: "middle",
+				Transitions: []Transition{
+					{
+						Event:  "next",
+						Target: "end",
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default(), WithMetrics(reg), WithTracer(noop.NewTracerProvider().Tracer("test")))
+
+	// Two instances start, one advances twice and one advances once
+	sm.RecordInstanceStarted("start")
+	sm.RecordInstanceStarted("start")
+	for _, state := range []string{"start", "middle", "start"} {
+		if _, err := sm.Trigger(context.Background(), state, "next", map[string]any{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "gomachina_states_current" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			values[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	expected := map[string]float64{"start": 0, "middle": 1, "end": 1}
+	for state, value := range expected {
+		if values[state] != value {
+			t.Errorf("Expected %v instances in %s, got %v", value, state, values[state])
+		}
+	}
+}
+
+func TestMetricsConditionEvaluations(t *testing.T) {
+	// Create a test registry
+	reg := prometheus.NewRegistry()
+
+	// Create a workflow with a guarded transition and a conditional branch
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:      "guarded",
+						Target:     "end",
+						Conditions: []string{"isFalse"},
+					},
+					{
+						Event:      "branch",
+						Target:     "end",
+						Conditions: []string{"isFalse"},
+					},
+					{
+						Event:      "branch",
+						Target:     "end",
+						Conditions: []string{"isError"},
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isFalse", MockFalseCondition)
+	registry.RegisterCondition("isError", MockErrorCondition)
+
+	sm := NewStateMachine(definition, registry, slog.Default(), WithMetrics(reg), WithTracer(noop.NewTracerProvider().Tracer("test")))
+
+	// Single-transition guard evaluated in executeConditions
+	if _, err := sm.Trigger(context.Background(), "start", "guarded", map[string]any{}); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	// Branch selection evaluated in getTransitionForEvent
+	if _, err := sm.Trigger(context.Background(), "start", "branch", map[string]any{}); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	counts := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "gomachina_condition_evaluations_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			counts[labels["condition"]+"/"+labels["result"]] = metric.GetCounter().GetValue()
+		}
+	}
+
+	if counts["isFalse/fail"] != 2 {
+		t.Errorf("Expected 2 fail evaluations of isFalse, got %v", counts["isFalse/fail"])
+	}
+
+	if counts["isError/error"] != 1 {
+		t.Errorf("Expected 1 error evaluation of isError, got %v", counts["isError/error"])
+	}
+}
+
+func TestMetricsConditionErrorTypes(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "rejected", Target: "end", Conditions: []string{"isFalse"}},
+					{Event: "broken", Target: "end", Conditions: []string{"isError"}},
+					{Event: "branch", Target: "end", Conditions: []string{"isFalse"}},
+					{Event: "branch", Target: "end", Conditions: []string{"isFalse"}},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isFalse", MockFalseCondition)
+	registry.RegisterCondition("isError", MockErrorCondition)
+
+	tests := []struct {
+		name     string
+		opts     []StateMachineOption
+		expected map[string]float64
+	}{
+		{
+			name:     "Default",
+			expected: map[string]float64{"rejected/condition_failed": 1, "broken/condition_error": 1, "branch/transition_not_found": 1},
+		},
+		{
+			name:     "ConditionFailureAsNonError",
+			opts:     []StateMachineOption{WithConditionFailureAsNonError()},
+			expected: map[string]float64{"broken/condition_error": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			opts := append([]StateMachineOption{WithMetrics(reg), WithTracer(noop.NewTracerProvider().Tracer("test"))}, tt.opts...)
+			sm := NewStateMachine(definition, registry, slog.Default(), opts...)
+
+			for _, event := range []string{"rejected", "broken", "branch"} {
+				if _, err := sm.Trigger(context.Background(), "start", event, map[string]any{}); err == nil {
+					t.Fatalf("Expected error for event %s, got nil", event)
+				}
+			}
+
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Error gathering metrics: %v", err)
+			}
+
+			counts := map[string]float64{}
+			for _, family := range families {
+				if family.GetName() != "gomachina_transition_errors_total" {
+					continue
+				}
+				for _, metric := range family.GetMetric() {
+					labels := map[string]string{}
+					for _, label := range metric.GetLabel() {
+						labels[label.GetName()] = label.GetValue()
+					}
+					counts[labels["event"]+"/"+labels["error_type"]] = metric.GetCounter().GetValue()
+				}
+			}
+
+			if len(counts) != len(tt.expected) {
+				t.Errorf("Expected error counts %v, got %v", tt.expected, counts)
+			}
+			for key, value := range tt.expected {
+				if counts[key] != value {
+					t.Errorf("Expected %v for %s, got %v", value, key, counts[key])
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsActionDuration(t *testing.T) {
+	// Create a test registry
+	reg := prometheus.NewRegistry()
+
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:    "start",
+				OnLeave: []string{"noopAction"},
+				Transitions: []Transition{
+					{
+						Event:   "proceed",
+						Target:  "end",
+						Actions: []string{"slowAction"},
+					},
+				},
+			},
+			"end": {
+				Name:    "end",
+				OnEnter: []string{"noopAction"},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("noopAction", MockNoOpAction)
+	registry.RegisterAction("slowAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		time.Sleep(20 * time.Millisecond)
+		return nil, nil
+	})
+
+	sm := NewStateMachine(definition, registry, slog.Default(), WithMetrics(reg), WithTracer(noop.NewTracerProvider().Tracer("test")))
+
+	if _, err := sm.Trigger(context.Background(), "start", "proceed", map[string]any{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	sums := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "gomachina_action_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			sums[labels["phase"]+"/"+labels["action"]] = metric.GetHistogram().GetSampleSum()
+		}
+	}
+
+	if len(sums) != 3 {
+		t.Errorf("Expected durations for 3 phase/action pairs, got %v", sums)
+	}
+
+	if sums["transition/slowAction"] < 0.02 {
+		t.Errorf("Expected slowAction to take at least 20ms, got %vs", sums["transition/slowAction"])
+	}
+
+	for _, key := range []string{"onleave/noopAction", "onenter/noopAction"} {
+		if _, ok := sums[key]; !ok {
+			t.Errorf("Expected duration recorded for %s", key)
+		}
+	}
+}
+
 func TestGetAutoEventForTransition(t *testing.T) {
 	// Create a workflow definition with an auto transition
 	definition := &WorkflowDefinition{
@@ -161,6 +635,15 @@ func TestGetAutoEventForTransition(t *testing.T) {
 			},
 			"end": {
 				Name: "end",
+				Transitions: []Transition{
+					{
+						Event:  "auto",
+						Target: "done",
+					},
+				},
+			},
+			"done": {
+				Name: "done",
 			},
 		},
 	}
@@ -317,4 +800,72 @@ func TestNewMetrics(t *testing.T) {
 	if metrics.AutoTransitionsTotal == nil {
 		t.Error("AutoTransitionsTotal metric not created")
 	}
+
+	if metrics.AutoEventsDeclaredTotal == nil {
+		t.Error("AutoEventsDeclaredTotal metric not created")
+	}
+
+	if metrics.AutoChainDepth == nil {
+		t.Error("AutoChainDepth metric not created")
+	}
+
+	if metrics.StatesCurrent == nil {
+		t.Error("StatesCurrent metric not created")
+	}
+
+	if metrics.ConditionEvaluationsTotal == nil {
+		t.Error("ConditionEvaluationsTotal metric not created")
+	}
+
+	if metrics.ActionDuration == nil {
+		t.Error("ActionDuration metric not created")
+	}
+}
+
+func TestMetricsWorkflowName(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:        "start",
+				Transitions: []Transition{{Event: "next", Target: "end"}},
+			},
+			"end": {Name: "end"},
+		},
+	}
+
+	// Machines for different workflows can share a registry when they are named
+	orders := NewStateMachine(definition, NewRegistry(), slog.Default(), WithMetrics(reg), WithMetricsWorkflowName("orders"))
+	refunds := NewStateMachine(definition, NewRegistry(), slog.Default(), WithMetrics(reg), WithMetricsWorkflowName("refunds"))
+
+	if _, err := orders.Trigger(context.Background(), "start", "next", map[string]any{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := refunds.Trigger(context.Background(), "start", "next", map[string]any{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+
+	workflows := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "gomachina_transitions_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "workflow" {
+					workflows[label.GetValue()] += metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	if workflows["orders"] != 1 || workflows["refunds"] != 1 {
+		t.Errorf("Expected one transition for each workflow label, got %v", workflows)
+	}
 }
@@ -0,0 +1,28 @@
+package machina
+
+import "context"
+
+// TriggerFunc processes a single event, with the signature of Trigger
+type TriggerFunc func(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error)
+
+// Middleware wraps the processing of every transition. It can reject a transition by
+// returning an error without calling next, observe it, or change the result next returns.
+type Middleware func(next TriggerFunc) TriggerFunc
+
+// WithMiddleware adds middleware around every transition, including those followed by
+// auto-event chaining. Middleware is applied in registration order, so the first one
+// registered is the outermost and sees the transition first.
+func WithMiddleware(middleware ...Middleware) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.middleware = append(sm.middleware, middleware...)
+	}
+}
+
+// chainMiddleware wraps core in the configured middleware
+func (sm *StateMachine) chainMiddleware(core TriggerFunc) TriggerFunc {
+	handler := core
+	for i := len(sm.middleware) - 1; i >= 0; i-- {
+		handler = sm.middleware[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,76 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestStateMachine_WithMiddleware(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"pending": {
+				Name: "pending",
+				Transitions: []Transition{
+					{Event: "approve", Target: "approved", AutoEvent: "ship"},
+					{Event: "delete", Target: "deleted"},
+				},
+			},
+			"approved": {
+				Name:        "approved",
+				Transitions: []Transition{{Event: "ship", Target: "shipped"}},
+			},
+			"deleted": {Name: "deleted"},
+			"shipped": {Name: "shipped"},
+		},
+	}
+
+	var order []string
+	calls := 0
+	counter := func(next TriggerFunc) TriggerFunc {
+		return func(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error) {
+			calls++
+			order = append(order, "counter:"+event)
+			return next(ctx, currentState, event, payload)
+		}
+	}
+
+	errForbidden := errors.New("forbidden")
+	blockDelete := func(next TriggerFunc) TriggerFunc {
+		return func(ctx context.Context, currentState string, event string, payload map[string]any) (*TransitionResult, error) {
+			order = append(order, "auth:"+event)
+			if event == "delete" {
+				return nil, fmt.Errorf("event %s rejected: %w", event, errForbidden)
+			}
+			return next(ctx, currentState, event, payload)
+		}
+	}
+
+	fsm := NewStateMachine(definition, NewRegistry(), nil, WithAutoEventChaining(5), WithMiddleware(counter, blockDelete))
+	ctx := context.Background()
+
+	result, err := fsm.Trigger(ctx, "pending", "approve", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "shipped" {
+		t.Errorf("Expected new state to be 'shipped', got '%s'", result.NewState)
+	}
+
+	// Middleware runs in registration order for every transition, including auto events
+	expectedOrder := []string{"counter:approve", "auth:approve", "counter:ship", "auth:ship"}
+	if !slices.Equal(order, expectedOrder) {
+		t.Errorf("Expected middleware order %v, got %v", expectedOrder, order)
+	}
+
+	_, err = fsm.Trigger(ctx, "pending", "delete", map[string]any{})
+	if !errors.Is(err, errForbidden) {
+		t.Errorf("Expected delete to be rejected, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected 3 counted transitions, got %d", calls)
+	}
+}
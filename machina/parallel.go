@@ -0,0 +1,113 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// WithParallelConditions evaluates the conditions of the chosen transition concurrently
+// instead of in order, which helps when they call independent slow services. The first
+// condition to fail cancels the context of the others. When several fail, the first one in
+// listed order whose failure was not caused by that cancellation is reported, so errors stay
+// deterministic. Conditions must be safe to run concurrently.
+func WithParallelConditions() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.parallelConditions = true
+	}
+}
+
+// conditionResult is the outcome of a condition run by evaluateConditionsConcurrently
+type conditionResult struct {
+	ok  bool
+	err error
+	// canceled is set if the condition failed because another one failed first
+	canceled bool
+}
+
+// evaluateConditionsConcurrently runs the transition's conditions concurrently and returns
+// their results in listed order. Unless every failure is collected, the first failure
+// cancels the context of the other conditions. A missing condition is reported before any
+// of them runs.
+func (sm *StateMachine) evaluateConditionsConcurrently(ctx context.Context, logger *slog.Logger, currentState, event string, transitionContext TransitionContext, transition *Transition, payload map[string]any) ([]conditionResult, error) {
+	conditions := make([]conditionFunc, len(transition.Conditions))
+	for i, conditionName := range transition.Conditions {
+		condition, err := sm.getCondition(conditionName)
+		if err != nil {
+			err = fmt.Errorf("failed to get condition %s: %w", conditionName, err)
+			sm.recordTransitionError(currentState, event, "condition_not_found", err)
+			return nil, err
+		}
+		conditions[i] = condition
+	}
+
+	results := make([]conditionResult, len(conditions))
+	g, groupCtx := errgroup.WithContext(ctx)
+	for i, conditionName := range transition.Conditions {
+		g.Go(func() error {
+			logger.Debug("Evaluating condition", "condition", conditionName)
+			ok, err := sm.cachedCondition(groupCtx, conditionName, conditions[i], transitionContext, payload, transition.ConditionArgs[conditionName])
+			results[i] = conditionResult{ok: ok, err: err}
+			if sm.collectAllConditionFailures || (ok && err == nil) {
+				return nil
+			}
+			if err == nil {
+				err = errGuardRejected
+			}
+			return err
+		})
+	}
+
+	// Failures are reported from the results, in order, by the caller
+	if g.Wait() != nil && ctx.Err() == nil {
+		for i := range results {
+			results[i].canceled = errors.Is(results[i].err, context.Canceled)
+		}
+	}
+	return results, nil
+}
+
+// executeParallelActions runs each group of actions concurrently, the actions of a group in
+// order, and waits for all of them. The first failure cancels the context of the other
+// groups and is returned. Once every group has succeeded, their results are merged into
+// persistenceData and their compensations registered in group order, so the outcome does
+// not depend on scheduling.
+func (sm *StateMachine) executeParallelActions(ctx context.Context, logger *slog.Logger, currentState, event string, groups [][]string, payload map[string]any, persistenceData map[string]any) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	results := make([]map[string]any, len(groups))
+	compensations := make([]*compensationHolder, len(groups))
+
+	g, groupCtx := errgroup.WithContext(ctx)
+	for i, actions := range groups {
+		results[i] = make(map[string]any)
+		var actionCtx context.Context
+		actionCtx, compensations[i] = withCompensationHolder(groupCtx)
+		actionCtx = context.WithValue(actionCtx, parallelGroupKey, true)
+
+		g.Go(func() error {
+			if err := sm.executeTransitionActions(actionCtx, logger, currentState, event, actions, payload, results[i]); err != nil {
+				return fmt.Errorf("parallel group %d failed: %w", i, err)
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	outer, _ := ctx.Value(compensationKey).(*compensationHolder)
+	for i := range groups {
+		applyUpdates(persistenceData, results[i], false)
+		if outer != nil {
+			outer.actions = append(outer.actions, compensations[i].actions...)
+		}
+	}
+	return nil
+}
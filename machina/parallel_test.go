@@ -0,0 +1,250 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// parallelDefinition returns a workflow whose "complete" transition forks into a notify
+// group and an archive group before entering "done"
+func parallelDefinition() *WorkflowDefinition {
+	return &WorkflowDefinition{
+		States: map[string]State{
+			"processing": {
+				Name: "processing",
+				Transitions: []Transition{
+					{
+						Event:    "complete",
+						Target:   "done",
+						Parallel: [][]string{{"notify"}, {"archive", "compress"}},
+					},
+				},
+			},
+			"done": {
+				Name:    "done",
+				OnEnter: []string{"onEnterDone"},
+			},
+		},
+	}
+}
+
+func TestStateMachine_Trigger_Parallel(t *testing.T) {
+	var entered bool
+	var started sync.WaitGroup
+	started.Add(2)
+
+	// Each group's first action waits for the other, which only finishes if they run concurrently
+	rendezvous := func(ctx context.Context) error {
+		started.Done()
+		done := make(chan struct{})
+		go func() { started.Wait(); close(done) }()
+		select {
+		case <-done:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("groups did not run concurrently")
+		}
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("notify", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		if err := rendezvous(ctx); err != nil {
+			return nil, err
+		}
+		RegisterCompensation(ctx, "retractNotification")
+		return map[string]any{"notified": true}, nil
+	})
+	registry.RegisterAction("archive", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		if err := rendezvous(ctx); err != nil {
+			return nil, err
+		}
+		RegisterCompensation(ctx, "restoreArchive")
+		return map[string]any{"archived": true}, nil
+	})
+	registry.RegisterAction("compress", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"compressed": true}, nil
+	})
+	registry.RegisterAction("onEnterDone", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		entered = true
+		return nil, nil
+	})
+
+	sm := NewStateMachine(parallelDefinition(), registry, slog.Default())
+
+	result, err := sm.Trigger(context.Background(), "processing", "complete", map[string]any{"orderID": "42"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.NewState != "done" || !entered {
+		t.Errorf("Expected to enter done after the join, got %s (entered=%v)", result.NewState, entered)
+	}
+
+	for _, key := range []string{"orderID", "notified", "archived", "compressed"} {
+		if _, ok := result.PersistenceData[key]; !ok {
+			t.Errorf("Expected %s in persistence data, got %v", key, result.PersistenceData)
+		}
+	}
+
+	// Compensations are registered in group order regardless of scheduling
+	expected := []string{"retractNotification", "restoreArchive"}
+	if stack, _ := result.PersistenceData[CompensationStackKey].([]string); !slices.Equal(stack, expected) {
+		t.Errorf("Expected compensation stack %v, got %v", expected, stack)
+	}
+}
+
+func TestStateMachine_Trigger_ParallelFailureCancelsOthers(t *testing.T) {
+	errArchiveFull := errors.New("archive full")
+
+	var entered, notified, compressed bool
+	registry := NewRegistry()
+	registry.RegisterAction("notify", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			notified = true
+			return nil, nil
+		}
+	})
+	registry.RegisterAction("archive", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, errArchiveFull
+	})
+	registry.RegisterAction("compress", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		compressed = true
+		return nil, nil
+	})
+	registry.RegisterAction("onEnterDone", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		entered = true
+		return nil, nil
+	})
+
+	sm := NewStateMachine(parallelDefinition(), registry, slog.Default())
+
+	result, err := sm.Trigger(context.Background(), "processing", "complete", map[string]any{})
+	if result != nil {
+		t.Errorf("Expected no result, got %v", result)
+	}
+
+	if !errors.Is(err, errArchiveFull) {
+		t.Fatalf("Expected the archive failure, got %v", err)
+	}
+
+	expected := "parallel group 1 failed: transition action archive failed: archive full"
+	if err.Error() != expected {
+		t.Errorf("Expected error %q, got %q", expected, err.Error())
+	}
+
+	// The notify group is cancelled whether or not it had started
+	if notified {
+		t.Error("Expected the notify group to be cancelled")
+	}
+
+	if compressed {
+		t.Error("Expected the failed group to stop at its failing action")
+	}
+
+	if entered {
+		t.Error("Expected OnEnter actions not to run after a failed group")
+	}
+}
+
+// sleepCondition returns a condition that reports ok after d, or fails early if its context is canceled
+func sleepCondition(d time.Duration, ok bool) func(ctx context.Context, data map[string]any) (bool, error) {
+	return func(ctx context.Context, data map[string]any) (bool, error) {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(d):
+			return ok, nil
+		}
+	}
+}
+
+func TestStateMachine_Trigger_ParallelConditions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end", Conditions: []string{"first", "second", "third"}},
+				},
+			},
+			"end": {Name: "end"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		first         func(ctx context.Context, data map[string]any) (bool, error)
+		second        func(ctx context.Context, data map[string]any) (bool, error)
+		expectedError string
+		maxDuration   time.Duration
+	}{
+		{
+			name:        "AllPass",
+			first:       sleepCondition(100*time.Millisecond, true),
+			second:      sleepCondition(100*time.Millisecond, true),
+			maxDuration: 250 * time.Millisecond,
+		},
+		{
+			name:          "FailureCancelsOthers",
+			first:         sleepCondition(time.Second, true),
+			second:        MockFalseCondition,
+			expectedError: "condition second evaluated to false",
+			maxDuration:   500 * time.Millisecond,
+		},
+		{
+			// The slower failure is listed first, so it is reported whichever finishes first
+			name: "SimultaneousFailuresReportListedOrder",
+			first: func(ctx context.Context, data map[string]any) (bool, error) {
+				time.Sleep(50 * time.Millisecond)
+				return false, nil
+			},
+			second:        MockErrorCondition,
+			expectedError: "condition first evaluated to false",
+			maxDuration:   500 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.RegisterCondition("first", tt.first)
+			registry.RegisterCondition("second", tt.second)
+			registry.RegisterCondition("third", sleepCondition(100*time.Millisecond, true))
+			sm := NewStateMachine(definition, registry, slog.Default(), WithParallelConditions())
+
+			start := time.Now()
+			result, err := sm.Trigger(context.Background(), "start", "proceed", map[string]any{})
+			elapsed := time.Since(start)
+
+			if elapsed > tt.maxDuration {
+				t.Errorf("Expected conditions to run concurrently within %s, took %s", tt.maxDuration, elapsed)
+			}
+
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if result.NewState != "end" {
+					t.Errorf("Expected state end, got %s", result.NewState)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.HasSuffix(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error ending in %q, got %q", tt.expectedError, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package machina
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidPayload is matched by errors.Is when Trigger rejects a payload because it lacks
+// a transition's RequiredData or one of its payload validators returned an error
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// ErrMissingData is returned by Trigger when the payload lacks keys listed in the
+// transition's RequiredData. The workflow remains in State.
+type ErrMissingData struct {
+	State string
+	Event string
+	// Keys lists the missing keys in the order the transition declares them
+	Keys []string
+}
+
+// Error implements the error interface
+func (e *ErrMissingData) Error() string {
+	return fmt.Sprintf("transition from %s on event %s is missing required data: %s", e.State, e.Event, strings.Join(e.Keys, ", "))
+}
+
+// Is reports whether target is ErrInvalidPayload
+func (e *ErrMissingData) Is(target error) bool {
+	return target == ErrInvalidPayload
+}
+
+// validatePayload checks the payload against the transition's RequiredData and payload
+// validators. It runs on the selected transition, so guards used to select among several
+// transitions for the same event must not rely on it.
+func (sm *StateMachine) validatePayload(ctx context.Context, currentState, event string, transition *Transition, payload map[string]any) error {
+	var missing []string
+	for _, key := range transition.RequiredData {
+		if _, exists := payload[key]; !exists {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return &ErrMissingData{State: currentState, Event: event, Keys: missing}
+	}
+
+	for _, validatorName := range transition.Validators {
+		validator, err := sm.registry.GetPayloadValidator(validatorName)
+		if err != nil {
+			return fmt.Errorf("failed to get payload validator %s: %w", validatorName, err)
+		}
+
+		if err := validator(ctx, payload); err != nil {
+			return withKind(fmt.Errorf("payload validator %s rejected the payload: %w", validatorName, err), ErrInvalidPayload)
+		}
+	}
+
+	return nil
+}
+
+// payloadErrorType returns the error type recorded in metrics for a validatePayload error
+func payloadErrorType(err error) string {
+	var missing *ErrMissingData
+	switch {
+	case errors.As(err, &missing):
+		return "missing_data"
+	case errors.Is(err, ErrInvalidPayload):
+		return "invalid_payload"
+	default:
+		return "validator_not_found"
+	}
+}
+
+// payloadSchema is the JSON Schema document produced by PayloadSchema
+type payloadSchema struct {
+	Schema     string                           `json:"$schema"`
+	Type       string                           `json:"type"`
+	Properties map[string]payloadSchemaProperty `json:"properties"`
+	Required   []string                         `json:"required"`
+}
+
+// payloadSchemaProperty describes a single payload key
+type payloadSchemaProperty struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// PayloadSchema returns a JSON Schema document describing the payload keys the workflow
+// requires. Every key listed in a transition's RequiredData becomes a required string
+// property, described by the transitions that require it. Payload validators are not
+// reflected, as their checks are opaque functions.
+func (wd *WorkflowDefinition) PayloadSchema() ([]byte, error) {
+	requiredBy := make(map[string][]string)
+	for _, name := range wd.sortedStateNames() {
+		for _, transition := range wd.States[name].Transitions {
+			for _, key := range transition.RequiredData {
+				requiredBy[key] = append(requiredBy[key], fmt.Sprintf("%s from %s", transition.Event, name))
+			}
+		}
+	}
+
+	schema := payloadSchema{
+		Schema:     "https://json-schema.org/draft/2020-12/schema",
+		Type:       "object",
+		Properties: make(map[string]payloadSchemaProperty, len(requiredBy)),
+		Required:   make([]string, 0, len(requiredBy)),
+	}
+	for key, transitions := range requiredBy {
+		schema.Properties[key] = payloadSchemaProperty{
+			Type:        "string",
+			Description: "Required by " + strings.Join(slices.Compact(transitions), ", "),
+		}
+		schema.Required = append(schema.Required, key)
+	}
+	slices.Sort(schema.Required)
+
+	return json.MarshalIndent(schema, "", "  ")
+}
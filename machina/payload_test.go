@@ -0,0 +1,184 @@
+package machina
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestStateMachine_Trigger_PayloadValidation(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"pending": {
+				Name: "pending",
+				Transitions: []Transition{
+					{
+						Event:        "pay",
+						Target:       "paid",
+						Conditions:   []string{"countingCondition"},
+						RequiredData: []string{"orderId", "amount"},
+						Validators:   []string{"positiveAmount"},
+					},
+					{Event: "refund", Target: "paid", Validators: []string{"missingValidator"}},
+				},
+			},
+			"paid": {Name: "paid"},
+		},
+	}
+
+	conditionCalls := 0
+	registry := NewRegistry()
+	registry.RegisterCondition("countingCondition", func(ctx context.Context, data map[string]any) (bool, error) {
+		conditionCalls++
+		return true, nil
+	})
+	registry.RegisterPayloadValidator("positiveAmount", func(ctx context.Context, data map[string]any) error {
+		if amount, _ := data["amount"].(int); amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+		return nil
+	})
+
+	fsm := NewStateMachine(definition, registry, nil)
+
+	tests := []struct {
+		name          string
+		event         string
+		payload       map[string]any
+		expectedState string
+		expectedError string
+		expectedKeys  []string
+	}{
+		{
+			name:          "SatisfiedPayload",
+			event:         "pay",
+			payload:       map[string]any{"orderId": "o-1", "amount": 100},
+			expectedState: "paid",
+		},
+		{
+			name:          "MissingFields",
+			event:         "pay",
+			payload:       map[string]any{"note": "rush"},
+			expectedError: "transition from pending on event pay is missing required data: orderId, amount",
+			expectedKeys:  []string{"orderId", "amount"},
+		},
+		{
+			name:          "ValidatorRejects",
+			event:         "pay",
+			payload:       map[string]any{"orderId": "o-1", "amount": -5},
+			expectedError: "payload validator positiveAmount rejected the payload: amount must be positive",
+		},
+		{
+			name:          "UnknownValidator",
+			event:         "refund",
+			payload:       map[string]any{},
+			expectedError: "failed to get payload validator missingValidator: payload validator missingValidator not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditionCalls = 0
+			result, err := fsm.Trigger(context.Background(), "pending", tt.event, tt.payload)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if result.NewState != tt.expectedState {
+					t.Errorf("Expected new state to be '%s', got '%s'", tt.expectedState, result.NewState)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tt.expectedError {
+				t.Fatalf("Expected error %q, got %v", tt.expectedError, err)
+			}
+			if conditionCalls != 0 {
+				t.Errorf("Expected conditions not to run, got %d calls", conditionCalls)
+			}
+
+			var missing *ErrMissingData
+			if tt.expectedKeys != nil {
+				if !errors.As(err, &missing) {
+					t.Fatalf("Expected *ErrMissingData, got %T", err)
+				}
+				if !slices.Equal(missing.Keys, tt.expectedKeys) {
+					t.Errorf("Expected missing keys %v, got %v", tt.expectedKeys, missing.Keys)
+				}
+			}
+
+			if tt.event == "pay" && !errors.Is(err, ErrInvalidPayload) {
+				t.Errorf("Expected error to match ErrInvalidPayload, got %v", err)
+			}
+		})
+	}
+
+	if ok, err := fsm.CanTrigger(context.Background(), "pending", "pay", map[string]any{"orderId": "o-1"}); ok || err != nil {
+		t.Errorf("Expected CanTrigger to reject the payload, got %v (err=%v)", ok, err)
+	}
+}
+
+func TestWorkflowDefinition_PayloadSchema(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"pending": {
+				Name: "pending",
+				Transitions: []Transition{
+					{Event: "pay", Target: "paid", RequiredData: []string{"orderId", "amount"}, Conditions: []string{"isCard"}},
+					{Event: "pay", Target: "paid", RequiredData: []string{"orderId", "amount"}},
+					{Event: "cancel", Target: "cancelled"},
+				},
+			},
+			"paid": {
+				Name: "paid",
+				Transitions: []Transition{
+					{Event: "refund", Target: "cancelled", RequiredData: []string{"orderId", "reason"}},
+				},
+			},
+			"cancelled": {Name: "cancelled"},
+		},
+	}
+
+	data, err := definition.PayloadSchema()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var schema struct {
+		Schema     string `json:"$schema"`
+		Type       string `json:"type"`
+		Properties map[string]struct {
+			Type        string `json:"type"`
+			Description string `json:"description"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+
+	if schema.Schema != "https://json-schema.org/draft/2020-12/schema" || schema.Type != "object" {
+		t.Errorf("Expected a draft 2020-12 object schema, got %s %s", schema.Schema, schema.Type)
+	}
+
+	expectedRequired := []string{"amount", "orderId", "reason"}
+	if !slices.Equal(schema.Required, expectedRequired) {
+		t.Errorf("Expected required keys %v, got %v", expectedRequired, schema.Required)
+	}
+
+	if len(schema.Properties) != len(expectedRequired) {
+		t.Errorf("Expected %d properties, got %d", len(expectedRequired), len(schema.Properties))
+	}
+	for _, key := range expectedRequired {
+		if schema.Properties[key].Type != "string" {
+			t.Errorf("Expected property %s to be a string, got %q", key, schema.Properties[key].Type)
+		}
+	}
+
+	expectedDescription := "Required by refund from paid, pay from pending"
+	if got := schema.Properties["orderId"].Description; got != expectedDescription {
+		t.Errorf("Expected description %q, got %q", expectedDescription, got)
+	}
+}
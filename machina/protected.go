@@ -0,0 +1,90 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// ErrProtectedKey is matched by errors.Is when an action returned a value for a key
+// protected with WithProtectedKeys
+var ErrProtectedKey = errors.New("protected key")
+
+// WithProtectedKeys stops actions from overwriting the given persistence data keys, such
+// as WorkflowStackKey. A transition whose action returns one of them fails with an error
+// naming the action and key. The built-in side quest actions may still update the keys,
+// and NextStateOverrideKey is never protected since the engine consumes it.
+func WithProtectedKeys(keys ...string) StateMachineOption {
+	return func(sm *StateMachine) {
+		if sm.protectedKeys == nil {
+			sm.protectedKeys = make(map[string]bool, len(keys))
+		}
+		for _, key := range keys {
+			sm.protectedKeys[key] = true
+		}
+	}
+}
+
+// Delete, returned as the value of a key in an action's result, removes the key from the
+// persistence data. Any other value, including nil, is stored as is.
+var Delete any = deleteMarker{}
+
+// deleteMarker is the type of Delete
+type deleteMarker struct{}
+
+// applyUpdates copies updates into data, removing the keys set to Delete unless keepDeletes
+// is true, in which case the marker itself is copied
+func applyUpdates(data, updates map[string]any, keepDeletes bool) {
+	for k, v := range updates {
+		if _, isDelete := v.(deleteMarker); isDelete && !keepDeletes {
+			delete(data, k)
+			continue
+		}
+		data[k] = v
+	}
+}
+
+// mergeActionResult copies an action's result into persistenceData, failing without
+// changes if the result writes a protected key. Keys set to Delete are removed, except
+// within a parallel group, whose results keep the marker until they are merged.
+func (sm *StateMachine) mergeActionResult(ctx context.Context, logger *slog.Logger, currentState, event, phase, actionName string, result, persistenceData map[string]any) error {
+	if len(result) == 0 {
+		return nil
+	}
+
+	if err := sm.checkProtectedKeys(actionName, result); err != nil {
+		err = withKind(fmt.Errorf("%s action %s failed: %w", phase, actionName, err), errStepFailed)
+		sm.recordTransitionError(currentState, event, "protected_key_overwrite", err)
+		return err
+	}
+
+	_, inParallelGroup := ctx.Value(parallelGroupKey).(bool)
+	applyUpdates(persistenceData, result, inParallelGroup)
+	logger.Debug("Action updated persistenceData", "phase", phase, "action", actionName, "updates", sm.logData(result))
+
+	return nil
+}
+
+// checkProtectedKeys returns an error for the first protected key, in name order, that
+// the result of actionName writes. The built-in side quest actions own the WorkflowStack
+// and are exempt, but an action of the caller's registered under their name is not.
+func (sm *StateMachine) checkProtectedKeys(actionName string, result map[string]any) error {
+	if len(sm.protectedKeys) == 0 || sm.registry.isBuiltinAction(actionName) {
+		return nil
+	}
+
+	var keys []string
+	for key := range result {
+		if sm.protectedKeys[key] && key != NextStateOverrideKey {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	slices.Sort(keys)
+
+	return fmt.Errorf("%w %s may not be overwritten", ErrProtectedKey, keys[0])
+}
@@ -0,0 +1,176 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// protectedDefinition returns a workflow whose "save" transition runs the given action
+func protectedDefinition(action string) *WorkflowDefinition {
+	return &WorkflowDefinition{
+		States: map[string]State{
+			"draft": {
+				Name: "draft",
+				Transitions: []Transition{
+					{Event: "save", Target: "saved", Actions: []string{action}},
+				},
+			},
+			"saved":    {Name: "saved"},
+			"archived": {Name: "archived"},
+		},
+	}
+}
+
+func TestStateMachine_Trigger_ProtectedKeyBlocked(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAction("clobber", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"title": "draft", WorkflowStackKey: []string{}}, nil
+	})
+
+	sm := NewStateMachine(protectedDefinition("clobber"), registry, nil, WithProtectedKeys(WorkflowStackKey, "state"))
+
+	result, err := sm.Trigger(context.Background(), "draft", "save", map[string]any{WorkflowStackKey: []string{"main"}})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if result != nil {
+		t.Errorf("Expected no result, got %v", result)
+	}
+	if !errors.Is(err, ErrProtectedKey) {
+		t.Errorf("Expected error to match ErrProtectedKey, got %v", err)
+	}
+	expected := "transition action clobber failed: protected key WorkflowStack may not be overwritten"
+	if err.Error() != expected {
+		t.Errorf("Expected error '%s', got '%s'", expected, err.Error())
+	}
+}
+
+func TestStateMachine_Trigger_ProtectedKeyCallerActionWithBuiltinName(t *testing.T) {
+	// Only the built-in action is exempt, not any action registered under its name
+	registry := NewRegistry()
+	registry.RegisterAction(PushCurrentStateActionName, func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{WorkflowStackKey: []string{}}, nil
+	})
+
+	sm := NewStateMachine(protectedDefinition(PushCurrentStateActionName), registry, nil, WithProtectedKeys(WorkflowStackKey))
+
+	_, err := sm.Trigger(context.Background(), "draft", "save", map[string]any{WorkflowStackKey: []string{"main"}})
+	if !errors.Is(err, ErrProtectedKey) {
+		t.Errorf("Expected error to match ErrProtectedKey, got %v", err)
+	}
+}
+
+func TestStateMachine_Trigger_ProtectedKeyAllowed(t *testing.T) {
+	t.Run("UnprotectedKeysAndOverride", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.RegisterAction("redirect", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+			return map[string]any{"title": "final", NextStateOverrideKey: "archived"}, nil
+		})
+
+		sm := NewStateMachine(protectedDefinition("redirect"), registry, nil, WithProtectedKeys(WorkflowStackKey, NextStateOverrideKey))
+
+		result, err := sm.Trigger(context.Background(), "draft", "save", map[string]any{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.NewState != "archived" {
+			t.Errorf("Expected state 'archived', got '%s'", result.NewState)
+		}
+		if result.PersistenceData["title"] != "final" {
+			t.Errorf("Expected title 'final', got %v", result.PersistenceData["title"])
+		}
+	})
+
+	t.Run("PredefinedSideQuestActions", func(t *testing.T) {
+		sm := NewStateMachine(sideQuestDefinition(PushCurrentStateActionName), NewRegistry(), nil, WithProtectedKeys(WorkflowStackKey))
+		ctx := context.Background()
+
+		result, err := sm.Trigger(ctx, "main", "detour", map[string]any{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		result, err = sm.Trigger(ctx, result.NewState, "return", result.PersistenceData)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.NewState != "main" {
+			t.Errorf("Expected state 'main', got '%s'", result.NewState)
+		}
+	})
+}
+
+func TestStateMachine_Trigger_ActionResultSemantics(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   map[string]any
+		parallel bool
+		expected map[string]any
+	}{
+		{
+			name:     "PartialUpdate",
+			result:   map[string]any{"status": "paid", "receipt": "r-1"},
+			expected: map[string]any{"orderID": "42", "status": "paid", "coupon": "SAVE10", "receipt": "r-1"},
+		},
+		{
+			name:     "NoOp",
+			result:   nil,
+			expected: map[string]any{"orderID": "42", "status": "new", "coupon": "SAVE10"},
+		},
+		{
+			name:     "Delete",
+			result:   map[string]any{"coupon": Delete, "missing": Delete},
+			expected: map[string]any{"orderID": "42", "status": "new"},
+		},
+		{
+			name:     "NilIsStored",
+			result:   map[string]any{"coupon": nil},
+			expected: map[string]any{"orderID": "42", "status": "new", "coupon": nil},
+		},
+		{
+			name:     "DeleteFromParallelGroup",
+			result:   map[string]any{"coupon": Delete},
+			parallel: true,
+			expected: map[string]any{"orderID": "42", "status": "new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transition := Transition{Event: "pay", Target: "paid", Actions: []string{"payAction"}}
+			if tt.parallel {
+				transition.Actions = nil
+				transition.Parallel = [][]string{{"payAction"}, {"noOpAction"}}
+			}
+			definition := &WorkflowDefinition{
+				States: map[string]State{
+					"new":  {Name: "new", Transitions: []Transition{transition}},
+					"paid": {Name: "paid"},
+				},
+			}
+
+			registry := NewRegistry()
+			registry.RegisterAction("payAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+				return tt.result, nil
+			})
+			registry.RegisterAction("noOpAction", MockNoOpAction)
+			fsm := NewStateMachine(definition, registry, slog.Default())
+
+			payload := map[string]any{"orderID": "42", "status": "new", "coupon": "SAVE10"}
+			result, err := fsm.Trigger(context.Background(), "new", "pay", payload)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(result.PersistenceData, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result.PersistenceData)
+			}
+			if payload["coupon"] != "SAVE10" {
+				t.Error("Expected the caller's payload not to be modified")
+			}
+		})
+	}
+}
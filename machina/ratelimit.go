@@ -0,0 +1,124 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimit caps how often a transition may fire for the same workflow. Each workflow gets a
+// token bucket holding Max tokens that refills at Max tokens per Per, so bursts of up to Max
+// are allowed and the sustained rate is Max per Per.
+type RateLimit struct {
+	Max int           `yaml:"max" json:"max"`
+	Per time.Duration `yaml:"per" json:"per"`
+}
+
+// ErrRateLimited is returned by Trigger when the transition selected for the event has
+// exhausted its RateLimit for the workflow. The workflow remains in State.
+type ErrRateLimited struct {
+	State      string
+	Event      string
+	WorkflowID string
+	// RetryAfter is how long until the transition may fire again
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *ErrRateLimited) Error() string {
+	return fmt.Sprintf("event %s in state %s is rate limited, retry after %s", e.Event, e.State, e.RetryAfter)
+}
+
+// validate checks that the rate limit allows a positive number of events per positive period
+func (r *RateLimit) validate() error {
+	if r.Max <= 0 {
+		return fmt.Errorf("rateLimit max must be positive")
+	}
+	if r.Per <= 0 {
+		return fmt.Errorf("rateLimit per must be positive")
+	}
+	return nil
+}
+
+// rateLimitKey identifies the bucket of one transition for one workflow
+type rateLimitKey struct {
+	workflowID string
+	state      string
+	transition int
+}
+
+// tokenBucket tracks the tokens left for a key as the time at which the bucket will be
+// full again, which keeps the arithmetic exact
+type tokenBucket struct {
+	full time.Time
+}
+
+// rateLimiter tracks the token buckets of rate limited transitions. Buckets that have
+// refilled completely are dropped, as they behave like new ones.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[rateLimitKey]*tokenBucket
+	lastSweep time.Time
+}
+
+// take removes a token from the bucket of key, returning how long to wait if it is empty
+func (l *rateLimiter) take(key rateLimitKey, limit *RateLimit, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = make(map[rateLimitKey]*tokenBucket)
+		l.lastSweep = now
+	}
+	if now.Sub(l.lastSweep) >= time.Minute {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{full: now}
+		l.buckets[key] = bucket
+	}
+	if bucket.full.Before(now) {
+		bucket.full = now
+	}
+
+	// Each token takes interval to refill; the bucket has a token while it is less than
+	// Per - interval away from being full
+	interval := limit.Per / time.Duration(limit.Max)
+	if wait := bucket.full.Sub(now) - (limit.Per - interval); wait > 0 {
+		return wait, false
+	}
+	bucket.full = bucket.full.Add(interval)
+	return 0, true
+}
+
+// sweep drops the buckets that have refilled completely
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if !bucket.full.After(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// checkRateLimit takes a token for the workflow in ctx from the bucket of the transition.
+// Calls without a workflow ID share one bucket per transition.
+func (sm *StateMachine) checkRateLimit(ctx context.Context, currentState, event string, transitionIndex int, transition *Transition) error {
+	if transition.RateLimit == nil {
+		return nil
+	}
+
+	workflowID, _ := WorkflowIDFromContext(ctx)
+	key := rateLimitKey{workflowID: workflowID, state: currentState, transition: transitionIndex}
+	retryAfter, ok := sm.rateLimits.take(key, transition.RateLimit, sm.clock.Now())
+	if ok {
+		return nil
+	}
+
+	err := &ErrRateLimited{State: currentState, Event: event, WorkflowID: workflowID, RetryAfter: retryAfter}
+	sm.recordTransitionError(currentState, event, "rate_limited", err)
+	return err
+}
@@ -0,0 +1,55 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStateMachine_Trigger_RateLimit(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"failed": {
+				Name: "failed",
+				Transitions: []Transition{
+					{Event: "retry", Target: "failed", Internal: true, RateLimit: &RateLimit{Max: 3, Per: time.Minute}},
+				},
+			},
+		},
+	}
+
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	fsm := NewStateMachine(definition, NewRegistry(), nil, WithClock(clock))
+	ctx := WithWorkflowID(context.Background(), "order-1")
+
+	for i := 0; i < 3; i++ {
+		if _, err := fsm.Trigger(ctx, "failed", "retry", map[string]any{}); err != nil {
+			t.Fatalf("Expected retry %d to be allowed, got %v", i+1, err)
+		}
+		clock.Advance(time.Second)
+	}
+
+	_, err := fsm.Trigger(ctx, "failed", "retry", map[string]any{})
+	var rateLimited *ErrRateLimited
+	if !errors.As(err, &rateLimited) {
+		t.Fatalf("Expected *ErrRateLimited for the 4th retry, got %v", err)
+	}
+	if rateLimited.WorkflowID != "order-1" {
+		t.Errorf("Expected workflow ID order-1, got %s", rateLimited.WorkflowID)
+	}
+	// Each token takes 20s to refill and the first was taken 3s ago
+	if rateLimited.RetryAfter != 17*time.Second {
+		t.Errorf("Expected retry after 17s, got %s", rateLimited.RetryAfter)
+	}
+
+	// Other workflows have their own bucket
+	if _, err := fsm.Trigger(WithWorkflowID(context.Background(), "order-2"), "failed", "retry", map[string]any{}); err != nil {
+		t.Errorf("Expected another workflow to be allowed, got %v", err)
+	}
+
+	clock.Advance(rateLimited.RetryAfter)
+	if _, err := fsm.Trigger(ctx, "failed", "retry", map[string]any{}); err != nil {
+		t.Errorf("Expected retry to be allowed after %s, got %v", rateLimited.RetryAfter, err)
+	}
+}
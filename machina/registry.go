@@ -1,22 +1,36 @@
 package machina
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
 )
 
 // Registry holds mappings of condition and action implementations
 type Registry struct {
-	conditions map[string]ConditionFunc
-	actions    map[string]ActionFunc
-	mu         sync.RWMutex
+	conditions           map[string]ConditionFunc
+	paramConditions      map[string]ParamConditionFunc
+	transitionConditions map[string]TransitionConditionFunc
+	payloadValidators    map[string]PayloadValidatorFunc
+	actions              map[string]ActionFunc
+	// builtinActions holds the names of the actions registered by the state machine itself,
+	// such as the predefined side quest actions, rather than by the caller
+	builtinActions map[string]bool
+	mu             sync.RWMutex
 }
 
 // NewRegistry creates a new registry
 func NewRegistry() *Registry {
 	return &Registry{
-		conditions: make(map[string]ConditionFunc),
-		actions:    make(map[string]ActionFunc),
+		conditions:           make(map[string]ConditionFunc),
+		paramConditions:      make(map[string]ParamConditionFunc),
+		transitionConditions: make(map[string]TransitionConditionFunc),
+		payloadValidators:    make(map[string]PayloadValidatorFunc),
+		actions:              make(map[string]ActionFunc),
+		builtinActions:       make(map[string]bool),
 	}
 }
 
@@ -25,7 +39,7 @@ func (r *Registry) RegisterCondition(name string, condition ConditionFunc) error
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.conditions[name]; exists {
+	if r.hasCondition(name) {
 		return fmt.Errorf("condition %s already registered", name)
 	}
 
@@ -33,6 +47,45 @@ func (r *Registry) RegisterCondition(name string, condition ConditionFunc) error
 	return nil
 }
 
+// RegisterParamCondition registers a condition function that receives per-transition arguments
+func (r *Registry) RegisterParamCondition(name string, condition ParamConditionFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hasCondition(name) {
+		return fmt.Errorf("condition %s already registered", name)
+	}
+
+	r.paramConditions[name] = condition
+	return nil
+}
+
+// RegisterTransitionCondition registers a condition function that receives the transition it guards
+func (r *Registry) RegisterTransitionCondition(name string, condition TransitionConditionFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hasCondition(name) {
+		return fmt.Errorf("condition %s already registered", name)
+	}
+
+	r.transitionConditions[name] = condition
+	return nil
+}
+
+// hasCondition reports whether a condition of any kind is registered under name.
+// The caller must hold the lock.
+func (r *Registry) hasCondition(name string) bool {
+	if _, exists := r.conditions[name]; exists {
+		return true
+	}
+	if _, exists := r.paramConditions[name]; exists {
+		return true
+	}
+	_, exists := r.transitionConditions[name]
+	return exists
+}
+
 // RegisterAction registers an action function
 func (r *Registry) RegisterAction(name string, action ActionFunc) error {
 	r.mu.Lock()
@@ -46,7 +99,165 @@ func (r *Registry) RegisterAction(name string, action ActionFunc) error {
 	return nil
 }
 
-// GetCondition retrieves a condition function by name
+// registerBuiltinAction registers a built-in action under name unless an action already
+// has that name, in which case the caller's action is kept
+func (r *Registry) registerBuiltinAction(name string, action ActionFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.actions[name]; !exists {
+		r.actions[name] = action
+		r.builtinActions[name] = true
+	}
+}
+
+// isBuiltinAction reports whether the action registered as name is a built-in one
+func (r *Registry) isBuiltinAction(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.builtinActions[name]
+}
+
+// RegisterPayloadValidator registers a payload validator function
+func (r *Registry) RegisterPayloadValidator(name string, validator PayloadValidatorFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.payloadValidators[name]; exists {
+		return fmt.Errorf("payload validator %s already registered", name)
+	}
+
+	r.payloadValidators[name] = validator
+	return nil
+}
+
+// AliasAction registers alias as another name for the action registered as existing,
+// for example to keep an old name working during a rename
+func (r *Registry) AliasAction(existing, alias string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	action, exists := r.actions[existing]
+	if !exists {
+		return fmt.Errorf("cannot alias action %s: action %s not found", alias, existing)
+	}
+	if _, exists := r.actions[alias]; exists {
+		return fmt.Errorf("action %s already registered", alias)
+	}
+
+	r.actions[alias] = action
+	if r.builtinActions[existing] {
+		r.builtinActions[alias] = true
+	}
+	return nil
+}
+
+// AliasCondition registers alias as another name for the condition registered as existing.
+// The alias keeps the kind of the original, so it receives the same arguments.
+func (r *Registry) AliasCondition(existing, alias string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.hasCondition(existing) {
+		return fmt.Errorf("cannot alias condition %s: condition %s not found", alias, existing)
+	}
+	if r.hasCondition(alias) {
+		return fmt.Errorf("condition %s already registered", alias)
+	}
+
+	if condition, exists := r.conditions[existing]; exists {
+		r.conditions[alias] = condition
+	} else if condition, exists := r.paramConditions[existing]; exists {
+		r.paramConditions[alias] = condition
+	} else {
+		r.transitionConditions[alias] = r.transitionConditions[existing]
+	}
+	return nil
+}
+
+// RegisterConditions registers every condition in the map. Registration continues past
+// failures and the returned error lists every name that could not be registered.
+func (r *Registry) RegisterConditions(conditions map[string]ConditionFunc) error {
+	var errs []error
+	for _, name := range sortedKeys(conditions) {
+		if err := r.RegisterCondition(name, conditions[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterActions registers every action in the map. Registration continues past
+// failures and the returned error lists every name that could not be registered.
+func (r *Registry) RegisterActions(actions map[string]ActionFunc) error {
+	var errs []error
+	for _, name := range sortedKeys(actions) {
+		if err := r.RegisterAction(name, actions[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterStruct registers the exported methods of svc whose signatures match ActionFunc,
+// ConditionFunc, ParamConditionFunc or TransitionConditionFunc, each under prefix followed
+// by the method name. Other methods are skipped, so a service can mix workflow logic with
+// helpers. Methods with pointer receivers are only found if svc is a pointer. Registration
+// continues past name conflicts, and the returned error lists them. It is an error if svc
+// has no matching method.
+func (r *Registry) RegisterStruct(prefix string, svc any) error {
+	if svc == nil {
+		return fmt.Errorf("cannot register methods of a nil value")
+	}
+
+	value := reflect.ValueOf(svc)
+	valueType := value.Type()
+
+	var errs []error
+	registered := 0
+	for i := 0; i < value.NumMethod(); i++ {
+		name := prefix + valueType.Method(i).Name
+
+		var err error
+		switch method := value.Method(i).Interface().(type) {
+		case func(context.Context, map[string]any) (map[string]any, error):
+			err = r.RegisterAction(name, method)
+		case func(context.Context, map[string]any) (bool, error):
+			err = r.RegisterCondition(name, method)
+		case func(context.Context, map[string]any, map[string]any) (bool, error):
+			err = r.RegisterParamCondition(name, method)
+		case func(context.Context, TransitionContext, map[string]any) (bool, error):
+			err = r.RegisterTransitionCondition(name, method)
+		default:
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		registered++
+	}
+
+	if registered == 0 && len(errs) == 0 {
+		return fmt.Errorf("%T has no methods matching an action or condition signature", svc)
+	}
+	return errors.Join(errs...)
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetCondition retrieves a condition function by name.
+// Transition conditions are adapted and receive an empty TransitionContext.
 func (r *Registry) GetCondition(name string) (ConditionFunc, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -55,6 +266,79 @@ func (r *Registry) GetCondition(name string) (ConditionFunc, error) {
 		return condition, nil
 	}
 
+	if condition, exists := r.paramConditions[name]; exists {
+		return func(ctx context.Context, data map[string]any) (bool, error) {
+			return condition(ctx, data, nil)
+		}, nil
+	}
+
+	if condition, exists := r.transitionConditions[name]; exists {
+		return func(ctx context.Context, data map[string]any) (bool, error) {
+			return condition(ctx, TransitionContext{}, data)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("condition %s not found", name)
+}
+
+// GetParamCondition retrieves a condition function by name in its parameterized form.
+// Conditions registered with RegisterCondition are adapted and ignore their arguments, and
+// transition conditions receive an empty TransitionContext.
+func (r *Registry) GetParamCondition(name string) (ParamConditionFunc, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if condition, exists := r.paramConditions[name]; exists {
+		return condition, nil
+	}
+
+	if condition, exists := r.conditions[name]; exists {
+		return func(ctx context.Context, data map[string]any, args map[string]any) (bool, error) {
+			return condition(ctx, data)
+		}, nil
+	}
+
+	if condition, exists := r.transitionConditions[name]; exists {
+		return func(ctx context.Context, data map[string]any, args map[string]any) (bool, error) {
+			return condition(ctx, TransitionContext{}, data)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("condition %s not found", name)
+}
+
+// isTransitionCondition reports whether name is registered with RegisterTransitionCondition
+func (r *Registry) isTransitionCondition(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.transitionConditions[name]
+	return exists
+}
+
+// lookupCondition retrieves a condition function of any kind in the form the state machine evaluates
+func (r *Registry) lookupCondition(name string) (conditionFunc, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if condition, exists := r.transitionConditions[name]; exists {
+		return func(ctx context.Context, transition TransitionContext, data map[string]any, args map[string]any) (bool, error) {
+			return condition(ctx, transition, data)
+		}, nil
+	}
+
+	if condition, exists := r.paramConditions[name]; exists {
+		return func(ctx context.Context, transition TransitionContext, data map[string]any, args map[string]any) (bool, error) {
+			return condition(ctx, data, args)
+		}, nil
+	}
+
+	if condition, exists := r.conditions[name]; exists {
+		return func(ctx context.Context, transition TransitionContext, data map[string]any, args map[string]any) (bool, error) {
+			return condition(ctx, data)
+		}, nil
+	}
+
 	return nil, fmt.Errorf("condition %s not found", name)
 }
 
@@ -69,3 +353,15 @@ func (r *Registry) GetAction(name string) (ActionFunc, error) {
 
 	return nil, fmt.Errorf("action %s not found", name)
 }
+
+// GetPayloadValidator retrieves a payload validator function by name
+func (r *Registry) GetPayloadValidator(name string) (PayloadValidatorFunc, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if validator, exists := r.payloadValidators[name]; exists {
+		return validator, nil
+	}
+
+	return nil, fmt.Errorf("payload validator %s not found", name)
+}
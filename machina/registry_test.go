@@ -106,3 +106,278 @@ func TestRegistry_GetNonExistentAction(t *testing.T) {
 		t.Error("Expected error when getting non-existent action, got nil")
 	}
 }
+
+func TestRegistry_RegisterAndGetParamCondition(t *testing.T) {
+	registry := NewRegistry()
+
+	err := registry.RegisterParamCondition("amountGreaterThan", func(ctx context.Context, data map[string]any, args map[string]any) (bool, error) {
+		return data["amount"].(int) > args["min"].(int), nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	condition, err := registry.GetParamCondition("amountGreaterThan")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ok, err := condition(context.Background(), map[string]any{"amount": 150}, map[string]any{"min": 100})
+	if err != nil || !ok {
+		t.Errorf("Expected condition to pass, got %v (err=%v)", ok, err)
+	}
+
+	// Plain conditions are available in parameterized form
+	registry.RegisterCondition("testCondition", MockCondition)
+	if _, err := registry.GetParamCondition("testCondition"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// Parameterized conditions are available in plain form
+	if _, err := registry.GetCondition("amountGreaterThan"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestRegistry_RegisterTransitionCondition(t *testing.T) {
+	registry := NewRegistry()
+
+	err := registry.RegisterTransitionCondition("isExpress", func(ctx context.Context, transition TransitionContext, data map[string]any) (bool, error) {
+		return transition.Target == "express", nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	condition, err := registry.lookupCondition("isExpress")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ok, err := condition(context.Background(), TransitionContext{Target: "express"}, nil, nil)
+	if err != nil || !ok {
+		t.Errorf("Expected condition to pass, got %v (err=%v)", ok, err)
+	}
+
+	// Transition conditions are available in plain form with an empty TransitionContext
+	plain, err := registry.GetCondition("isExpress")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ok, _ := plain(context.Background(), nil); ok {
+		t.Error("Expected condition to fail without a target")
+	}
+
+	// Names are shared with the other kinds of conditions
+	registry.RegisterCondition("testCondition", MockCondition)
+	err = registry.RegisterTransitionCondition("testCondition", func(ctx context.Context, transition TransitionContext, data map[string]any) (bool, error) {
+		return true, nil
+	})
+	if err == nil {
+		t.Error("Expected error when registering a name used by a plain condition, got nil")
+	}
+	if err := registry.RegisterCondition("isExpress", MockCondition); err == nil {
+		t.Error("Expected error when registering a name used by a transition condition, got nil")
+	}
+}
+
+func TestRegistry_RegisterParamConditionConflictsWithCondition(t *testing.T) {
+	registry := NewRegistry()
+
+	registry.RegisterCondition("testCondition", MockCondition)
+	err := registry.RegisterParamCondition("testCondition", func(ctx context.Context, data map[string]any, args map[string]any) (bool, error) {
+		return true, nil
+	})
+	if err == nil {
+		t.Error("Expected error when registering a name used by a plain condition, got nil")
+	}
+}
+
+func TestRegistry_RegisterActions(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAction("existing", MockAction)
+
+	err := registry.RegisterActions(map[string]ActionFunc{
+		"first":    MockAction,
+		"second":   MockAction,
+		"existing": MockAction,
+	})
+	if err == nil {
+		t.Fatal("Expected error for duplicate action, got nil")
+	}
+	if err.Error() != "action existing already registered" {
+		t.Errorf("Unexpected error message '%s'", err.Error())
+	}
+
+	// Non-conflicting actions are still registered
+	for _, name := range []string{"first", "second"} {
+		if _, err := registry.GetAction(name); err != nil {
+			t.Errorf("Expected action %s to be registered, got %v", name, err)
+		}
+	}
+}
+
+func TestRegistry_RegisterConditions(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterCondition("a", MockCondition)
+	registry.RegisterCondition("b", MockCondition)
+
+	err := registry.RegisterConditions(map[string]ConditionFunc{
+		"a": MockCondition,
+		"b": MockCondition,
+		"c": MockCondition,
+	})
+	if err == nil {
+		t.Fatal("Expected error for duplicate conditions, got nil")
+	}
+	if err.Error() != "condition a already registered\ncondition b already registered" {
+		t.Errorf("Unexpected error message '%s'", err.Error())
+	}
+
+	if _, err := registry.GetCondition("c"); err != nil {
+		t.Errorf("Expected condition c to be registered, got %v", err)
+	}
+
+	if err := NewRegistry().RegisterConditions(map[string]ConditionFunc{"a": MockCondition}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestRegistry_AliasAction(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAction("chargeCard", MockUpdateAction)
+	registry.RegisterAction("refund", MockNoOpAction)
+
+	if err := registry.AliasAction("chargeCard", "ChargePaymentAction"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	action, err := registry.GetAction("ChargePaymentAction")
+	if err != nil {
+		t.Fatalf("Expected alias to be registered, got %v", err)
+	}
+	if result, _ := action(context.Background(), nil); result["updated"] != true {
+		t.Errorf("Expected alias to run the original action, got %v", result)
+	}
+
+	tests := []struct {
+		name          string
+		existing      string
+		alias         string
+		expectedError string
+	}{
+		{name: "MissingSource", existing: "missing", alias: "other", expectedError: "cannot alias action other: action missing not found"},
+		{name: "Collision", existing: "chargeCard", alias: "refund", expectedError: "action refund already registered"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.AliasAction(tt.existing, tt.alias)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestRegistry_AliasCondition(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterCondition("isPaid", MockCondition)
+	registry.RegisterParamCondition("amountAbove", func(ctx context.Context, data map[string]any, args map[string]any) (bool, error) {
+		return data["amount"].(int) > args["min"].(int), nil
+	})
+
+	if err := registry.AliasCondition("isPaid", "IsPaymentSuccessCondition"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := registry.GetCondition("IsPaymentSuccessCondition"); err != nil {
+		t.Errorf("Expected alias to be registered, got %v", err)
+	}
+
+	// Aliases of parameterized conditions still receive their arguments
+	if err := registry.AliasCondition("amountAbove", "amountGreaterThan"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	condition, err := registry.GetParamCondition("amountGreaterThan")
+	if err != nil {
+		t.Fatalf("Expected alias to be registered, got %v", err)
+	}
+	if ok, _ := condition(context.Background(), map[string]any{"amount": 10}, map[string]any{"min": 5}); !ok {
+		t.Error("Expected alias to pass its arguments to the original condition")
+	}
+
+	tests := []struct {
+		name          string
+		existing      string
+		alias         string
+		expectedError string
+	}{
+		{name: "MissingSource", existing: "missing", alias: "other", expectedError: "cannot alias condition other: condition missing not found"},
+		{name: "Collision", existing: "isPaid", alias: "amountAbove", expectedError: "condition amountAbove already registered"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.AliasCondition(tt.existing, tt.alias)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+// paymentService is a service whose methods double as workflow actions and conditions
+type paymentService struct {
+	limit int
+}
+
+func (s *paymentService) Charge(ctx context.Context, data map[string]any) (map[string]any, error) {
+	return map[string]any{"charged": true}, nil
+}
+
+func (s *paymentService) IsWithinLimit(ctx context.Context, data map[string]any) (bool, error) {
+	amount, _ := data["amount"].(int)
+	return amount <= s.limit, nil
+}
+
+// Describe does not match any registrable signature and is skipped
+func (s *paymentService) Describe() string {
+	return "payments"
+}
+
+func TestRegistry_RegisterStruct(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.RegisterStruct("payment.", &paymentService{limit: 100}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	action, err := registry.GetAction("payment.Charge")
+	if err != nil {
+		t.Fatalf("Expected action payment.Charge to be registered, got %v", err)
+	}
+	if result, _ := action(context.Background(), nil); result["charged"] != true {
+		t.Errorf("Expected charged result, got %v", result)
+	}
+
+	condition, err := registry.GetCondition("payment.IsWithinLimit")
+	if err != nil {
+		t.Fatalf("Expected condition payment.IsWithinLimit to be registered, got %v", err)
+	}
+	if ok, _ := condition(context.Background(), map[string]any{"amount": 150}); ok {
+		t.Error("Expected the method to use the receiver's limit")
+	}
+
+	if _, err := registry.GetAction("payment.Describe"); err == nil {
+		t.Error("Expected non-matching method Describe to be skipped")
+	}
+
+	// Registering the same service again reports every conflict
+	err = registry.RegisterStruct("payment.", &paymentService{})
+	expected := "action payment.Charge already registered\ncondition payment.IsWithinLimit already registered"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+
+	// Pointer receiver methods are not in the method set of a plain value
+	if err := NewRegistry().RegisterStruct("", paymentService{}); err == nil {
+		t.Error("Expected error for a value without matching methods, got nil")
+	}
+}
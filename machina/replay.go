@@ -0,0 +1,77 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReplayStep reports how one recorded transition behaved when replayed
+type ReplayStep struct {
+	// Recorded is the transition from the history being replayed
+	Recorded TransitionStep
+	// FromState is the state the event was replayed from, which differs from
+	// Recorded.FromState once an earlier step has diverged
+	FromState string
+	// ToState is the state the replayed transition ended in
+	ToState string
+	// Matches reports whether ToState equals Recorded.ToState
+	Matches bool
+}
+
+// ReplayReport describes the outcome of Replay
+type ReplayReport struct {
+	// Steps lists the replayed transitions in order, stopping at the first that failed
+	Steps []ReplayStep
+	// FinalState and PersistenceData are those left by the last replayed step
+	FinalState      string
+	PersistenceData map[string]any
+}
+
+// Diverged reports whether any replayed step ended in a different state than recorded
+func (r ReplayReport) Diverged() bool {
+	for _, step := range r.Steps {
+		if !step.Matches {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay re-runs the events of a recorded history, such as TransitionResult.History,
+// starting from the first step's FromState with the given payload, and reports per step
+// whether the machine reached the recorded state. Run against an updated definition,
+// it shows whether existing instances would behave differently after a migration.
+//
+// Each step is applied as a single transition without auto-event chaining, since the
+// history records chained transitions as steps of their own. Steps keep running from the
+// replayed state after a mismatch. Replay stops at the first step that fails, returning
+// the report so far together with the error. Conditions and actions run for real, so
+// register side-effect free implementations when replaying production histories.
+func (sm *StateMachine) Replay(ctx context.Context, history []TransitionStep, initialPayload map[string]any) (ReplayReport, error) {
+	report := ReplayReport{PersistenceData: make(map[string]any, len(initialPayload))}
+	for k, v := range initialPayload {
+		report.PersistenceData[k] = v
+	}
+	if len(history) == 0 {
+		return report, nil
+	}
+
+	report.FinalState = history[0].FromState
+	for i, recorded := range history {
+		result, err := sm.transition(ctx, report.FinalState, recorded.Event, report.PersistenceData)
+		if err != nil {
+			return report, fmt.Errorf("replay step %d (%s) from state %s failed: %w", i, recorded.Event, report.FinalState, err)
+		}
+
+		report.Steps = append(report.Steps, ReplayStep{
+			Recorded:  recorded,
+			FromState: report.FinalState,
+			ToState:   result.NewState,
+			Matches:   result.NewState == recorded.ToState,
+		})
+		report.FinalState = result.NewState
+		report.PersistenceData = result.PersistenceData
+	}
+
+	return report, nil
+}
@@ -0,0 +1,75 @@
+package machina
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStateMachine_Replay(t *testing.T) {
+	recordingDefinition := &WorkflowDefinition{
+		States: map[string]State{
+			"cart": {
+				Name:        "cart",
+				Transitions: []Transition{{Event: "checkout", Target: "payment"}},
+			},
+			"payment": {
+				Name:        "payment",
+				Transitions: []Transition{{Event: "pay", Target: "shipped"}},
+			},
+			"review": {
+				Name:        "review",
+				Transitions: []Transition{{Event: "approve", Target: "shipped"}},
+			},
+			"shipped": {Name: "shipped"},
+		},
+	}
+
+	// The updated definition routes payments through a review state
+	updatedDefinition := &WorkflowDefinition{
+		States: map[string]State{
+			"cart":    recordingDefinition.States["cart"],
+			"payment": {Name: "payment", Transitions: []Transition{{Event: "pay", Target: "review"}}},
+			"review":  recordingDefinition.States["review"],
+			"shipped": {Name: "shipped"},
+		},
+	}
+
+	ctx := context.Background()
+	recorded, errs := NewStateMachine(recordingDefinition, NewRegistry(), nil).TriggerBatch(ctx, "cart", []string{"checkout", "pay"}, map[string]any{"orderId": "o-1"})
+	if errs != nil {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	report, err := NewStateMachine(recordingDefinition, NewRegistry(), nil).Replay(ctx, recorded.History, map[string]any{"orderId": "o-1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Diverged() || len(report.Steps) != 2 || report.FinalState != "shipped" {
+		t.Errorf("Expected an identical replay ending in shipped, got %+v", report)
+	}
+	if report.PersistenceData["orderId"] != "o-1" {
+		t.Errorf("Expected payload to be carried through, got %v", report.PersistenceData)
+	}
+
+	report, err = NewStateMachine(updatedDefinition, NewRegistry(), nil).Replay(ctx, recorded.History, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !report.Diverged() {
+		t.Error("Expected replay against the updated definition to diverge")
+	}
+	if !report.Steps[0].Matches || report.Steps[1].Matches || report.Steps[1].ToState != "review" {
+		t.Errorf("Expected only the pay step to diverge into review, got %+v", report.Steps)
+	}
+
+	// Replaying past a divergence runs from the replayed state, where the event may not apply
+	history := append(recorded.History, TransitionStep{FromState: "shipped", Event: "pay", ToState: "shipped"})
+	report, err = NewStateMachine(updatedDefinition, NewRegistry(), nil).Replay(ctx, history, nil)
+	expectedError := "replay step 2 (pay) from state review failed: no valid transition found for event pay in state review: no transition for 'pay' in state review (did you mean: approve?)"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error %q, got %v", expectedError, err)
+	}
+	if len(report.Steps) != 2 || report.FinalState != "review" {
+		t.Errorf("Expected the report to stop after 2 steps in review, got %+v", report)
+	}
+}
@@ -0,0 +1,37 @@
+package machina
+
+import (
+	"maps"
+	"slices"
+)
+
+// reservedKeys are the persistence data keys owned by the engine. A feature that keeps
+// state in the data must list its key here so that ResetData removes it.
+var reservedKeys = []string{
+	NextStateOverrideKey,
+	WorkflowStackKey,
+	CompensationStackKey,
+	TimeoutStartKey,
+	VisitsKey,
+	DeferredEventsKey,
+	ErrorKey,
+}
+
+// ReservedKeys returns the persistence data keys owned by the engine
+func ReservedKeys() []string {
+	return slices.Clone(reservedKeys)
+}
+
+// ResetData returns a copy of data without the engine's reserved keys, such as the
+// WorkflowStack, visit counts and deferred events, keeping the business data. It gives a
+// clean payload to restart a workflow from its initial state. data is not modified.
+func ResetData(data map[string]any) map[string]any {
+	reset := maps.Clone(data)
+	if reset == nil {
+		reset = make(map[string]any)
+	}
+	for _, key := range reservedKeys {
+		delete(reset, key)
+	}
+	return reset
+}
@@ -0,0 +1,70 @@
+package machina
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResetData(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"review": {
+				Name:           "review",
+				DeferredEvents: []string{"ship"},
+				Transitions:    []Transition{{Event: "askCustomer", Target: "waiting", Actions: []string{PushCurrentStateActionName}}},
+			},
+			"waiting": {
+				Name:        "waiting",
+				IsSideQuest: true,
+				Transitions: []Transition{{Event: "answered", Actions: []string{ReturnToPreviousStateActionName}}},
+			},
+		},
+	}
+	fsm := NewStateMachine(definition, NewRegistry(), nil, WithVisitCounting())
+
+	payload := map[string]any{
+		"orderID":            "42",
+		"amount":             99.5,
+		TimeoutStartKey:      time.Now(),
+		ErrorKey:             "payment declined",
+		CompensationStackKey: []string{"refund"},
+	}
+	result, err := fsm.Trigger(context.Background(), "review", "ship", payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	result, err = fsm.Trigger(context.Background(), "review", "askCustomer", result.PersistenceData)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data := result.PersistenceData
+	for _, key := range []string{WorkflowStackKey, VisitsKey, DeferredEventsKey} {
+		if _, ok := data[key]; !ok {
+			t.Fatalf("Expected the engine to have written %s, got %v", key, data)
+		}
+	}
+
+	reset := ResetData(data)
+
+	expected := map[string]any{"orderID": "42", "amount": 99.5}
+	if !reflect.DeepEqual(reset, expected) {
+		t.Errorf("Expected %v, got %v", expected, reset)
+	}
+	if _, ok := data[WorkflowStackKey]; !ok {
+		t.Error("Expected the original data not to be modified")
+	}
+
+	for _, key := range []string{NextStateOverrideKey, WorkflowStackKey, VisitsKey} {
+		if !slices.Contains(ReservedKeys(), key) {
+			t.Errorf("Expected %s to be reserved", key)
+		}
+	}
+
+	if reset := ResetData(nil); reset == nil || len(reset) != 0 {
+		t.Errorf("Expected an empty map for nil data, got %v", reset)
+	}
+}
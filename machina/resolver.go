@@ -0,0 +1,38 @@
+package machina
+
+import "context"
+
+// WithResolver configures a fallback for actions and conditions missing from the registry
+func WithResolver(resolver Resolver) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.resolver = resolver
+	}
+}
+
+// getAction looks up an action in the registry, falling back to the resolver
+func (sm *StateMachine) getAction(name string) (ActionFunc, error) {
+	action, err := sm.registry.GetAction(name)
+	if err == nil || sm.resolver == nil {
+		return action, err
+	}
+
+	if resolved, ok := sm.resolver.ResolveAction(name); ok && resolved != nil {
+		return resolved, nil
+	}
+	return nil, err
+}
+
+// getCondition looks up a condition in the registry, falling back to the resolver
+func (sm *StateMachine) getCondition(name string) (conditionFunc, error) {
+	condition, err := sm.registry.lookupCondition(name)
+	if err == nil || sm.resolver == nil {
+		return condition, err
+	}
+
+	if resolved, ok := sm.resolver.ResolveCondition(name); ok && resolved != nil {
+		return func(ctx context.Context, transition TransitionContext, data map[string]any, args map[string]any) (bool, error) {
+			return resolved(ctx, data)
+		}, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,92 @@
+package machina
+
+import (
+	"context"
+	"testing"
+)
+
+// mapResolver resolves actions and conditions from maps
+type mapResolver struct {
+	actions    map[string]ActionFunc
+	conditions map[string]ConditionFunc
+}
+
+func (r mapResolver) ResolveAction(name string) (ActionFunc, bool) {
+	action, ok := r.actions[name]
+	return action, ok
+}
+
+func (r mapResolver) ResolveCondition(name string) (ConditionFunc, bool) {
+	condition, ok := r.conditions[name]
+	return condition, ok
+}
+
+func TestStateMachine_Trigger_Resolver(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:      "proceed",
+						Target:     "end",
+						Conditions: []string{"pluginCondition"},
+						Actions:    []string{"pluginAction"},
+					},
+					{
+						Event:   "missing",
+						Target:  "end",
+						Actions: []string{"unknownAction"},
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+		},
+	}
+
+	resolver := mapResolver{
+		actions:    map[string]ActionFunc{"pluginAction": MockUpdateAction},
+		conditions: map[string]ConditionFunc{"pluginCondition": MockTrueCondition},
+	}
+
+	// The registry is empty, so everything must come from the resolver
+	fsm := NewStateMachine(definition, NewRegistry(), nil, WithResolver(resolver))
+
+	result, err := fsm.Trigger(context.Background(), "start", "proceed", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.NewState != "end" {
+		t.Errorf("Expected state end, got %s", result.NewState)
+	}
+
+	if result.PersistenceData["updated"] != true {
+		t.Errorf("Expected resolved action to run, got %v", result.PersistenceData)
+	}
+
+	_, err = fsm.Trigger(context.Background(), "start", "missing", map[string]any{})
+	expected := "failed to get transition action unknownAction: action unknownAction not found"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got %v", expected, err)
+	}
+}
+
+func TestStateMachine_Resolver_RegistryTakesPrecedence(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAction("action", MockNoOpAction)
+
+	resolver := mapResolver{actions: map[string]ActionFunc{"action": MockErrorAction}}
+	fsm := NewStateMachine(&WorkflowDefinition{States: map[string]State{"start": {Name: "start"}}}, registry, nil, WithResolver(resolver))
+
+	action, err := fsm.getAction("action")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := action(context.Background(), nil); err != nil {
+		t.Errorf("Expected the registered action to be used, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package machina
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// transitionResultJSON is the wire form of a TransitionResult
+type transitionResultJSON struct {
+	NewState  string            `json:"newState"`
+	AutoEvent string            `json:"autoEvent,omitempty"`
+	Data      map[string]any    `json:"data"`
+	History   []TransitionStep  `json:"history,omitempty"`
+	Chosen    *ChosenTransition `json:"chosenTransition,omitempty"`
+}
+
+// MarshalJSON encodes the result as {"newState", "autoEvent", "data", "history", "chosenTransition"}.
+// Values in PersistenceData that JSON cannot represent, such as channels, functions or
+// NaN, are encoded as their fmt.Sprint representation rather than failing the whole result.
+// Actions should still prefer JSON-safe outputs: strings, numbers, booleans, time.Time,
+// and maps and slices of those.
+func (r TransitionResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(transitionResultJSON{
+		NewState:  r.NewState,
+		AutoEvent: r.AutoEvent,
+		Data:      jsonSafeMap(r.PersistenceData),
+		History:   r.History,
+		Chosen:    r.ChosenTransition,
+	})
+}
+
+// UnmarshalJSON decodes a result encoded by MarshalJSON. Values in data take their
+// generic JSON form, so a time.Time comes back as an RFC 3339 string.
+func (r *TransitionResult) UnmarshalJSON(data []byte) error {
+	var decoded transitionResultJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*r = TransitionResult{
+		NewState:         decoded.NewState,
+		AutoEvent:        decoded.AutoEvent,
+		PersistenceData:  decoded.Data,
+		History:          decoded.History,
+		ChosenTransition: decoded.Chosen,
+	}
+	return nil
+}
+
+// String returns the string stored under key in PersistenceData.
+// It reports false if the key is missing or holds another type.
+func (r TransitionResult) String(key string) (string, bool) {
+	s, ok := r.PersistenceData[key].(string)
+	return s, ok
+}
+
+// Int returns the integer stored under key in PersistenceData. An int32, int64 or whole
+// float64 is converted too, so values decoded from JSON still read as integers.
+// It reports false if the key is missing, holds another type or does not fit in an int.
+func (r TransitionResult) Int(key string) (int, bool) {
+	switch v := r.PersistenceData[key].(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		if v < math.MinInt || v > math.MaxInt {
+			return 0, false
+		}
+		return int(v), true
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt || v >= math.MaxInt {
+			return 0, false
+		}
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// Bool returns the boolean stored under key in PersistenceData.
+// It reports false if the key is missing or holds another type.
+func (r TransitionResult) Bool(key string) (bool, bool) {
+	b, ok := r.PersistenceData[key].(bool)
+	return b, ok
+}
+
+// Time returns the time stored under key in PersistenceData. An RFC 3339 string is
+// parsed as well, since that is how a time.Time comes back from UnmarshalJSON.
+// It reports false if the key is missing, holds another type or is not a valid time.
+func (r TransitionResult) Time(key string) (time.Time, bool) {
+	switch v := r.PersistenceData[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// jsonSafeMap returns m with every value that cannot be encoded replaced by a safe equivalent
+func jsonSafeMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+
+	safe := make(map[string]any, len(m))
+	for k, v := range m {
+		safe[k] = jsonSafeValue(v)
+	}
+	return safe
+}
+
+// jsonSafeValue returns v if it can be encoded as JSON, otherwise a safe equivalent.
+// Generic maps and slices are sanitized element by element so one bad value does not
+// hide its siblings. Only the other values are test-encoded, so each value is encoded at
+// most once however deeply it is nested.
+func jsonSafeValue(v any) any {
+	switch value := v.(type) {
+	case nil, string, bool, int, int64:
+		return v
+	case map[string]any:
+		return jsonSafeMap(value)
+	case []any:
+		safe := make([]any, len(value))
+		for i, element := range value {
+			safe[i] = jsonSafeValue(element)
+		}
+		return safe
+	}
+
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
@@ -0,0 +1,163 @@
+package machina
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTransitionResult_JSONRoundTrip(t *testing.T) {
+	chargedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	result := &TransitionResult{
+		NewState:  "paid",
+		AutoEvent: "ship",
+		PersistenceData: map[string]any{
+			"amount":    42,
+			"chargedAt": chargedAt,
+		},
+		History:          []TransitionStep{{FromState: "pending", Event: "pay", ToState: "paid"}},
+		ChosenTransition: &ChosenTransition{Index: 1, Event: "pay", Target: "paid"},
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `{"newState":"paid","autoEvent":"ship","data":{"amount":42,"chargedAt":"2024-03-01T12:30:00Z"},"history":[{"fromState":"pending","event":"pay","toState":"paid"}],"chosenTransition":{"index":1,"event":"pay","target":"paid"}}`
+	if string(encoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+
+	var decoded TransitionResult
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if decoded.NewState != "paid" || decoded.AutoEvent != "ship" {
+		t.Errorf("Expected paid/ship, got %s/%s", decoded.NewState, decoded.AutoEvent)
+	}
+
+	timestamp, _ := decoded.PersistenceData["chargedAt"].(string)
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil || !parsed.Equal(chargedAt) {
+		t.Errorf("Expected timestamp %v, got %v (%v)", chargedAt, decoded.PersistenceData["chargedAt"], err)
+	}
+
+	if len(decoded.History) != 1 || decoded.History[0] != result.History[0] {
+		t.Errorf("Expected history %v, got %v", result.History, decoded.History)
+	}
+
+	if decoded.ChosenTransition == nil || decoded.ChosenTransition.Index != 1 || decoded.ChosenTransition.Target != "paid" {
+		t.Errorf("Expected chosen transition %+v, got %+v", result.ChosenTransition, decoded.ChosenTransition)
+	}
+}
+
+func TestTransitionResult_MarshalJSON_UnsupportedValues(t *testing.T) {
+	result := TransitionResult{
+		NewState: "done",
+		PersistenceData: map[string]any{
+			"ratio":  math.NaN(),
+			"nested": map[string]any{"ok": true, "callback": func() {}},
+			"deep":   []any{map[string]any{"items": []any{1, math.Inf(1)}}},
+		},
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+
+	data := decoded["data"].(map[string]any)
+	if data["ratio"] != "NaN" {
+		t.Errorf("Expected NaN to be encoded as a string, got %v", data["ratio"])
+	}
+
+	nested := data["nested"].(map[string]any)
+	if nested["ok"] != true {
+		t.Errorf("Expected JSON-safe siblings to be preserved, got %v", nested)
+	}
+
+	if _, ok := nested["callback"].(string); !ok {
+		t.Errorf("Expected function to be encoded as a string, got %v", nested["callback"])
+	}
+
+	deep := data["deep"].([]any)[0].(map[string]any)["items"].([]any)
+	if deep[0] != float64(1) || deep[1] != "+Inf" {
+		t.Errorf("Expected [1 +Inf] in nested containers, got %v", deep)
+	}
+}
+
+func TestTransitionResult_TypedGetters(t *testing.T) {
+	chargedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	result := TransitionResult{
+		PersistenceData: map[string]any{
+			"paymentStatus": "captured",
+			"attempts":      3,
+			"retries":       int64(2),
+			"decodedCount":  float64(7),
+			"ratio":         0.5,
+			"approved":      true,
+			"chargedAt":     chargedAt,
+			"settledAt":     "2024-03-02T08:00:00Z",
+			"badTime":       "yesterday",
+		},
+	}
+
+	if s, ok := result.String("paymentStatus"); !ok || s != "captured" {
+		t.Errorf("Expected captured, got %q (%v)", s, ok)
+	}
+	if _, ok := result.String("attempts"); ok {
+		t.Error("Expected String to reject an int")
+	}
+	if _, ok := result.String("missing"); ok {
+		t.Error("Expected String to report a missing key")
+	}
+
+	intTests := []struct {
+		key      string
+		expected int
+		ok       bool
+	}{
+		{"attempts", 3, true},
+		{"retries", 2, true},
+		{"decodedCount", 7, true},
+		{"ratio", 0, false},
+		{"paymentStatus", 0, false},
+		{"missing", 0, false},
+	}
+	for _, tt := range intTests {
+		n, ok := result.Int(tt.key)
+		if n != tt.expected || ok != tt.ok {
+			t.Errorf("Int(%s): expected %d/%v, got %d/%v", tt.key, tt.expected, tt.ok, n, ok)
+		}
+	}
+
+	if b, ok := result.Bool("approved"); !ok || !b {
+		t.Errorf("Expected true, got %v (%v)", b, ok)
+	}
+	if _, ok := result.Bool("paymentStatus"); ok {
+		t.Error("Expected Bool to reject a string")
+	}
+
+	if ts, ok := result.Time("chargedAt"); !ok || !ts.Equal(chargedAt) {
+		t.Errorf("Expected %v, got %v (%v)", chargedAt, ts, ok)
+	}
+	if ts, ok := result.Time("settledAt"); !ok || !ts.Equal(time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected parsed RFC 3339 time, got %v (%v)", ts, ok)
+	}
+	if _, ok := result.Time("badTime"); ok {
+		t.Error("Expected Time to reject an unparseable string")
+	}
+
+	var empty TransitionResult
+	if _, ok := empty.String("paymentStatus"); ok {
+		t.Error("Expected getters to handle nil PersistenceData")
+	}
+}
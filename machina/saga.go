@@ -0,0 +1,86 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+)
+
+// CompensationStackKey is the persistence data key holding the names of the compensating
+// actions registered by completed transitions, in the order they were registered
+const CompensationStackKey = "CompensationStack"
+
+// compensationHolder collects the compensating actions registered during a transition
+type compensationHolder struct {
+	actions []string
+}
+
+// RegisterCompensation records the named action as the compensator for work done by the
+// calling action. Once the transition completes successfully the name is pushed onto the
+// CompensationStack in the persistence data, and Compensate later runs the stack in reverse.
+// Compensations registered by a transition that fails are discarded.
+// It may only be called from an action executed by Trigger.
+func RegisterCompensation(ctx context.Context, actionName string) error {
+	holder, ok := ctx.Value(compensationKey).(*compensationHolder)
+	if !ok {
+		return fmt.Errorf("compensation can only be registered from within a transition")
+	}
+
+	if actionName == "" {
+		return fmt.Errorf("compensation action name must not be empty")
+	}
+
+	holder.actions = append(holder.actions, actionName)
+	return nil
+}
+
+// Compensate runs the compensating actions on the CompensationStack in data in reverse
+// order, merging their results into data and removing the keys they set to Delete. Each
+// action is popped from the stack once it succeeds, so if one fails Compensate stops and
+// can be retried with the same data.
+func (sm *StateMachine) Compensate(ctx context.Context, data map[string]any) error {
+	ctx = sm.withClock(ctx)
+	stack, _ := data[CompensationStackKey].([]string)
+
+	for len(stack) > 0 {
+		actionName := stack[len(stack)-1]
+
+		action, err := sm.getAction(actionName)
+		if err != nil {
+			return fmt.Errorf("failed to get compensation action %s: %w", actionName, err)
+		}
+
+		sm.logger.Info("Executing compensation action", "action", actionName)
+		result, err := action(ctx, data)
+		if err != nil {
+			return fmt.Errorf("compensation action %s failed: %w", actionName, err)
+		}
+
+		applyUpdates(data, result, false)
+
+		stack = stack[:len(stack)-1]
+		data[CompensationStackKey] = stack
+	}
+
+	return nil
+}
+
+// withCompensationHolder returns a context carrying a new holder for registered compensations
+func withCompensationHolder(ctx context.Context) (context.Context, *compensationHolder) {
+	holder := &compensationHolder{}
+	return context.WithValue(ctx, compensationKey, holder), holder
+}
+
+// pushCompensations appends the registered compensations to the stack in persistenceData
+func pushCompensations(persistenceData map[string]any, actions []string) {
+	if len(actions) == 0 {
+		return
+	}
+
+	stack, _ := persistenceData[CompensationStackKey].([]string)
+
+	// Copy so the caller's payload is never modified through a shared backing array
+	newStack := make([]string, 0, len(stack)+len(actions))
+	newStack = append(newStack, stack...)
+	newStack = append(newStack, actions...)
+	persistenceData[CompensationStackKey] = newStack
+}
@@ -0,0 +1,141 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCompensate_ThreeStepUnwind(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"step1": {
+				Name: "step1",
+				Transitions: []Transition{
+					{Event: "next", Target: "step2", Actions: []string{"reserveStock"}},
+				},
+			},
+			"step2": {
+				Name: "step2",
+				Transitions: []Transition{
+					{Event: "next", Target: "step3", Actions: []string{"chargeCard"}},
+				},
+			},
+			"step3": {
+				Name: "step3",
+				Transitions: []Transition{
+					{Event: "next", Target: "step4", Actions: []string{"bookShipping"}},
+				},
+			},
+			"step4": {
+				Name: "step4",
+				Transitions: []Transition{
+					{Event: "next", Target: "done", Actions: []string{"errorAction"}},
+				},
+			},
+			"done": {
+				Name: "done",
+			},
+		},
+	}
+
+	var undone []string
+	registry := NewRegistry()
+	registry.RegisterAction("errorAction", MockErrorAction)
+	for _, step := range []string{"reserveStock", "chargeCard", "bookShipping"} {
+		registry.RegisterAction(step, func(ctx context.Context, data map[string]any) (map[string]any, error) {
+			return nil, RegisterCompensation(ctx, "undo_"+step)
+		})
+		registry.RegisterAction("undo_"+step, func(ctx context.Context, data map[string]any) (map[string]any, error) {
+			undone = append(undone, step)
+			return map[string]any{"undone_" + step: true}, nil
+		})
+	}
+
+	fsm := NewStateMachine(definition, registry, nil)
+	ctx := context.Background()
+
+	state, data := "step1", map[string]any{}
+	for i := 0; i < 3; i++ {
+		result, err := fsm.Trigger(ctx, state, "next", data)
+		if err != nil {
+			t.Fatalf("Expected step %d to succeed, got %v", i+1, err)
+		}
+		state, data = result.NewState, result.PersistenceData
+	}
+
+	stack, _ := data[CompensationStackKey].([]string)
+	if len(stack) != 3 {
+		t.Fatalf("Expected 3 compensations on the stack, got %v", stack)
+	}
+
+	// Step 4 fails, so its compensations (none) are discarded and the saga unwinds
+	if _, err := fsm.Trigger(ctx, state, "next", data); err == nil {
+		t.Fatal("Expected step 4 to fail")
+	}
+
+	if err := fsm.Compensate(ctx, data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"bookShipping", "chargeCard", "reserveStock"}
+	if fmt.Sprint(undone) != fmt.Sprint(expected) {
+		t.Errorf("Expected compensations in order %v, got %v", expected, undone)
+	}
+
+	if stack, _ := data[CompensationStackKey].([]string); len(stack) != 0 {
+		t.Errorf("Expected empty compensation stack, got %v", stack)
+	}
+
+	if data["undone_reserveStock"] != true {
+		t.Error("Expected compensation results to be merged into data")
+	}
+}
+
+func TestCompensate_StopsOnFailure(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAction("undoA", MockNoOpAction)
+	registry.RegisterAction("undoB", MockErrorAction)
+	registry.RegisterAction("undoC", MockNoOpAction)
+
+	fsm := NewStateMachine(&WorkflowDefinition{States: map[string]State{"start": {Name: "start"}}}, registry, nil)
+
+	data := map[string]any{CompensationStackKey: []string{"undoA", "undoB", "undoC"}}
+	err := fsm.Compensate(context.Background(), data)
+	if err == nil || err.Error() != "compensation action undoB failed: action error" {
+		t.Fatalf("Expected undoB failure, got %v", err)
+	}
+
+	// undoC succeeded and was popped; undoB remains for a retry
+	stack := data[CompensationStackKey].([]string)
+	if len(stack) != 2 || stack[1] != "undoB" {
+		t.Errorf("Expected remaining stack [undoA undoB], got %v", stack)
+	}
+}
+
+func TestCompensate_DeletesKeys(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAction("releaseStock", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"reservationID": Delete, "stockReleased": true}, nil
+	})
+
+	fsm := NewStateMachine(&WorkflowDefinition{States: map[string]State{"start": {Name: "start"}}}, registry, nil)
+
+	data := map[string]any{"reservationID": "r-1", CompensationStackKey: []string{"releaseStock"}}
+	if err := fsm.Compensate(context.Background(), data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, exists := data["reservationID"]; exists {
+		t.Errorf("Expected reservationID to be deleted, got %v", data["reservationID"])
+	}
+	if data["stockReleased"] != true {
+		t.Errorf("Expected stockReleased to be set, got %v", data)
+	}
+}
+
+func TestRegisterCompensation_OutsideTransition(t *testing.T) {
+	if err := RegisterCompensation(context.Background(), "undo"); err == nil {
+		t.Error("Expected error outside a transition, got nil")
+	}
+}
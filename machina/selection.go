@@ -0,0 +1,48 @@
+package machina
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectionTrace explains why none of the transitions for an event could be selected,
+// listing each candidate with the condition that rejected it. Trigger attaches it to the
+// error of a failed selection when the machine is created with WithSelectionTrace; recover
+// it with errors.As.
+type SelectionTrace struct {
+	State      string
+	Event      string
+	Candidates []CandidateTrace
+}
+
+// CandidateTrace records how a candidate transition was rejected
+type CandidateTrace struct {
+	// Index is the position of the transition within the state's transitions
+	Index int
+	// Target is the declared target, empty for dynamic targets
+	Target string
+	// FailedCondition is the first condition that evaluated to false
+	FailedCondition string
+}
+
+// Error implements the error interface
+func (t *SelectionTrace) Error() string {
+	candidates := make([]string, len(t.Candidates))
+	for i, candidate := range t.Candidates {
+		target := candidate.Target
+		if target == "" {
+			target = "(dynamic)"
+		}
+		candidates[i] = fmt.Sprintf("#%d to %s failed %s", candidate.Index, target, candidate.FailedCondition)
+	}
+	return "candidates: " + strings.Join(candidates, ", ")
+}
+
+// WithSelectionTrace makes Trigger record which condition rejected each candidate when
+// several transitions handle an event and none of their conditions pass. It is meant for
+// debugging, as the trace is built on every such failure.
+func WithSelectionTrace() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.selectionTrace = true
+	}
+}
@@ -0,0 +1,72 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestStateMachine_Trigger_SelectionTrace(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"review": {
+				Name: "review",
+				Transitions: []Transition{
+					{Event: "decide", Target: "approved", Conditions: []string{"alwaysTrue", "isManager"}},
+					{Event: "decide", Target: "escalated", Conditions: []string{"isOverLimit"}},
+					{Event: "decide", Conditions: []string{"isManager"}},
+				},
+			},
+			"approved":  {Name: "approved"},
+			"escalated": {Name: "escalated"},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("alwaysTrue", MockTrueCondition)
+	registry.RegisterCondition("isManager", MockFalseCondition)
+	registry.RegisterCondition("isOverLimit", MockFalseCondition)
+
+	fsm := NewStateMachine(definition, registry, slog.Default(), WithSelectionTrace())
+
+	_, err := fsm.Trigger(context.Background(), "review", "decide", map[string]any{})
+
+	var trace *SelectionTrace
+	if !errors.As(err, &trace) {
+		t.Fatalf("Expected a *SelectionTrace, got %v", err)
+	}
+	if !errors.Is(err, ErrTransitionNotFound) {
+		t.Errorf("Expected ErrTransitionNotFound, got %v", err)
+	}
+	if trace.State != "review" || trace.Event != "decide" {
+		t.Errorf("Expected trace for review/decide, got %s/%s", trace.State, trace.Event)
+	}
+
+	expected := []CandidateTrace{
+		{Index: 0, Target: "approved", FailedCondition: "isManager"},
+		{Index: 1, Target: "escalated", FailedCondition: "isOverLimit"},
+		{Index: 2, Target: "", FailedCondition: "isManager"},
+	}
+	if len(trace.Candidates) != len(expected) {
+		t.Fatalf("Expected %d candidates, got %v", len(expected), trace.Candidates)
+	}
+	for i, candidate := range trace.Candidates {
+		if candidate != expected[i] {
+			t.Errorf("Expected candidate %+v, got %+v", expected[i], candidate)
+		}
+	}
+
+	expectedMsg := "no valid transition found for event decide in state review: " +
+		"no transition found for event decide with matching conditions: candidates: " +
+		"#0 to approved failed isManager, #1 to escalated failed isOverLimit, #2 to (dynamic) failed isManager"
+	if err.Error() != expectedMsg {
+		t.Errorf("Expected %q, got %q", expectedMsg, err.Error())
+	}
+
+	// Without the option the error carries no trace
+	_, err = NewStateMachine(definition, registry, slog.Default()).Trigger(context.Background(), "review", "decide", map[string]any{})
+	if errors.As(err, &trace) {
+		t.Errorf("Expected no trace without WithSelectionTrace, got %v", err)
+	}
+}
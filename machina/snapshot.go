@@ -0,0 +1,108 @@
+package machina
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MachineSnapshot is everything needed to resume a workflow instance after a restart:
+// the state it is in and its persistence data, which includes the WorkflowStack and
+// CompensationStack
+type MachineSnapshot struct {
+	State string
+	Data  map[string]any
+}
+
+// machineSnapshotJSON is the wire form of a MachineSnapshot
+type machineSnapshotJSON struct {
+	State string         `json:"state"`
+	Data  map[string]any `json:"data"`
+}
+
+// SnapshotFrom captures a workflow instance in state with the given persistence data,
+// typically a TransitionResult's NewState and PersistenceData. The data map is copied.
+func SnapshotFrom(state string, data map[string]any) MachineSnapshot {
+	snapshot := MachineSnapshot{State: state, Data: make(map[string]any, len(data))}
+	for k, v := range data {
+		snapshot.Data[k] = v
+	}
+	return snapshot
+}
+
+// MarshalJSON encodes the snapshot as {"state", "data"}. Values in Data that JSON cannot
+// represent are encoded as their fmt.Sprint representation, as for TransitionResult.
+func (s MachineSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(machineSnapshotJSON{
+		State: s.State,
+		Data:  jsonSafeMap(s.Data),
+	})
+}
+
+// UnmarshalJSON decodes a snapshot encoded by MarshalJSON. Other values in data take
+// their generic JSON form, but the WorkflowStack, CompensationStack and deferred events are
+// restored as []string and the visit counts as map[string]int, so side quests, compensation,
+// deferred events and visit counting keep working after a resume.
+func (s *MachineSnapshot) UnmarshalJSON(data []byte) error {
+	var decoded machineSnapshotJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	for _, key := range []string{WorkflowStackKey, CompensationStackKey, DeferredEventsKey} {
+		value, exists := decoded.Data[key]
+		if !exists {
+			continue
+		}
+		stack, err := stringSlice(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s in snapshot: %w", key, err)
+		}
+		decoded.Data[key] = stack
+	}
+
+	if value, exists := decoded.Data[VisitsKey]; exists {
+		visits, err := visitCounts(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s in snapshot: %w", VisitsKey, err)
+		}
+		decoded.Data[VisitsKey] = visits
+	}
+
+	*s = MachineSnapshot{State: decoded.State, Data: decoded.Data}
+	return nil
+}
+
+// stringSlice converts a decoded JSON array of strings to a []string
+func stringSlice(value any) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	elements, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of strings, got %T", value)
+	}
+
+	strs := make([]string, len(elements))
+	for i, element := range elements {
+		str, ok := element.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an array of strings, got %T at index %d", element, i)
+		}
+		strs[i] = str
+	}
+	return strs, nil
+}
+
+// Resume continues a workflow instance from a snapshot by triggering event from its state
+// with its data. A snapshot whose state is no longer in the definition, for example after
+// a state was renamed, is rejected with an error matching ErrStateNotFound before any
+// condition or action runs.
+func (sm *StateMachine) Resume(ctx context.Context, snapshot MachineSnapshot, event string) (*TransitionResult, error) {
+	if _, err := sm.getStateDefinition(snapshot.State); err != nil {
+		return nil, withKind(fmt.Errorf("cannot resume: %w", err), ErrStateNotFound)
+	}
+
+	return sm.Trigger(ctx, snapshot.State, event, snapshot.Data)
+}
@@ -0,0 +1,86 @@
+package machina
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func TestMachineSnapshot_RoundTripAndResume(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"review": {
+				Name:        "review",
+				Transitions: []Transition{{Event: "askCustomer", Target: "waiting", Actions: []string{PushCurrentStateActionName}}},
+			},
+			"waiting": {
+				Name:        "waiting",
+				IsSideQuest: true,
+				Transitions: []Transition{{Event: "answered", Actions: []string{ReturnToPreviousStateActionName}}},
+			},
+		},
+	}
+
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default())
+
+	result, err := sm.Trigger(context.Background(), "review", "askCustomer", map[string]any{"orderID": "42"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	encoded, err := json.Marshal(SnapshotFrom(result.NewState, result.PersistenceData))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `{"state":"waiting","data":{"WorkflowStack":["review"],"orderID":"42"}}`
+	if string(encoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+
+	var snapshot MachineSnapshot
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if stack, ok := snapshot.Data[WorkflowStackKey].([]string); !ok || !slices.Equal(stack, []string{"review"}) {
+		t.Errorf("Expected WorkflowStack [review] as []string, got %#v", snapshot.Data[WorkflowStackKey])
+	}
+
+	// The restored stack lets the side quest return where it came from
+	result, err = sm.Resume(context.Background(), snapshot, "answered")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.NewState != "review" {
+		t.Errorf("Expected to return to review, got %s", result.NewState)
+	}
+
+	if result.PersistenceData["orderID"] != "42" {
+		t.Errorf("Expected orderID to survive the snapshot, got %v", result.PersistenceData)
+	}
+}
+
+func TestStateMachine_Resume_UnknownState(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {Name: "start"},
+		},
+	}
+
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default())
+
+	_, err := sm.Resume(context.Background(), MachineSnapshot{State: "renamed"}, "next")
+	if !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound, got %v", err)
+	}
+
+	var snapshot MachineSnapshot
+	if err := json.Unmarshal([]byte(`{"state":"start","data":{"WorkflowStack":[1]}}`), &snapshot); err == nil {
+		t.Error("Expected error for a malformed WorkflowStack, got nil")
+	}
+}
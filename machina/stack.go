@@ -0,0 +1,121 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+)
+
+// WorkflowStackKey is the persistence data key holding the side quest breadcrumb trail
+const WorkflowStackKey = "WorkflowStack"
+
+// PushCurrentStateActionName is the name under which PushCurrentStateAction is registered
+const PushCurrentStateActionName = "__PUSH_CURRENT_STATE__"
+
+// WithMaxStackDepth limits how deep the WorkflowStack may grow when side quests nest.
+// Pushing beyond n entries fails the transition. A limit of zero or less means no limit.
+func WithMaxStackDepth(n int) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.maxStackDepth = n
+	}
+}
+
+// WithStrictSideQuests rejects transitions that use the built-in return action from a
+// state that is not marked isSideQuest, which usually indicates a workflow bug
+func WithStrictSideQuests() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.strictSideQuests = true
+	}
+}
+
+// WithoutPredefinedActions stops NewStateMachine from registering the built-in side quest
+// actions, ReturnToPreviousStateActionName and PushCurrentStateActionName, in the registry.
+// Creating the machine fails if the workflow uses one of them and the registry has no
+// action under that name.
+func WithoutPredefinedActions() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.predefinedActionsDisabled = true
+	}
+}
+
+// predefinedActions are the built-in side quest actions, keyed by the name they are registered under
+var predefinedActions = []struct {
+	name   string
+	action ActionFunc
+}{
+	{ReturnToPreviousStateActionName, ReturnToPreviousStateAction},
+	{PushCurrentStateActionName, PushCurrentStateAction},
+}
+
+// registerPredefinedActions registers the built-in side quest actions that the registry
+// doesn't have yet. With WithoutPredefinedActions it instead checks that the workflow
+// doesn't depend on a built-in action that is missing.
+func (sm *StateMachine) registerPredefinedActions() error {
+	for _, predefined := range predefinedActions {
+		if !sm.predefinedActionsDisabled {
+			// Checked and inserted under the registry lock, as machines sharing a registry
+			// may be created concurrently
+			sm.registry.registerBuiltinAction(predefined.name, predefined.action)
+			continue
+		}
+
+		if _, err := sm.registry.GetAction(predefined.name); err == nil {
+			continue
+		}
+
+		if where, used := sm.definition.findAction(predefined.name); used {
+			return fmt.Errorf("%s uses predefined action %s, which is disabled", where, predefined.name)
+		}
+	}
+	return nil
+}
+
+// PushCurrentStateAction is a predefined action that pushes the transition's source
+// state onto the WorkflowStack, so that a side quest can later return to it with
+// ReturnToPreviousStateAction
+func PushCurrentStateAction(ctx context.Context, data map[string]any) (map[string]any, error) {
+	sourceState, ok := FromStateFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("could not determine source state")
+	}
+
+	return PushWorkflowStack(ctx, data, sourceState)
+}
+
+// PushWorkflowStack returns action results that push state onto the WorkflowStack in data.
+// It enforces the limit set by WithMaxStackDepth when called from within Trigger.
+func PushWorkflowStack(ctx context.Context, data map[string]any, state string) (map[string]any, error) {
+	stack, _ := data[WorkflowStackKey].([]string)
+
+	// Copy so the caller's payload is never modified through a shared backing array
+	newStack := make([]string, len(stack), len(stack)+1)
+	copy(newStack, stack)
+	newStack = append(newStack, state)
+
+	if maxDepth, ok := ctx.Value(maxStackDepthKey).(int); ok {
+		if err := checkStackDepth(newStack, maxDepth); err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]any{
+		WorkflowStackKey: newStack,
+	}, nil
+}
+
+// PeekPreviousState returns the state on top of the WorkflowStack in data without popping it
+func PeekPreviousState(data map[string]any) (string, bool) {
+	stack, ok := data[WorkflowStackKey].([]string)
+	if !ok || len(stack) == 0 {
+		return "", false
+	}
+	return stack[len(stack)-1], true
+}
+
+// checkStackDepth returns an error if the stack is deeper than maxDepth.
+// A maxDepth of zero or less means no limit.
+func checkStackDepth(stack []string, maxDepth int) error {
+	if maxDepth > 0 && len(stack) > maxDepth {
+		return fmt.Errorf("workflow stack depth exceeded (max %d)", maxDepth)
+	}
+	return nil
+}
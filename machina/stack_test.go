@@ -0,0 +1,320 @@
+package machina
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sideQuestDefinition returns a workflow where "main" can repeatedly detour into "sideQuest"
+func sideQuestDefinition(pushAction string) *WorkflowDefinition {
+	return &WorkflowDefinition{
+		States: map[string]State{
+			"main": {
+				Name: "main",
+				Transitions: []Transition{
+					{
+						Event:   "detour",
+						Target:  "sideQuest",
+						Actions: []string{pushAction},
+					},
+				},
+			},
+			"sideQuest": {
+				Name:        "sideQuest",
+				IsSideQuest: true,
+				Transitions: []Transition{
+					{
+						Event:   "detour",
+						Target:  "sideQuest",
+						Actions: []string{pushAction},
+					},
+					{
+						Event:   "return",
+						Target:  "",
+						Actions: []string{ReturnToPreviousStateActionName},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPushCurrentStateAction_RoundTrip(t *testing.T) {
+	fsm := NewStateMachine(sideQuestDefinition(PushCurrentStateActionName), NewRegistry(), nil)
+	ctx := context.Background()
+
+	result, err := fsm.Trigger(ctx, "main", "detour", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if previous, ok := PeekPreviousState(result.PersistenceData); !ok || previous != "main" {
+		t.Errorf("Expected 'main' on top of the stack, got '%s' (ok=%v)", previous, ok)
+	}
+
+	result, err = fsm.Trigger(ctx, result.NewState, "return", result.PersistenceData)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.NewState != "main" {
+		t.Errorf("Expected to return to 'main', got '%s'", result.NewState)
+	}
+}
+
+func TestWithMaxStackDepth(t *testing.T) {
+	customPush := func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		stack, _ := data[WorkflowStackKey].([]string)
+		return map[string]any{WorkflowStackKey: append(stack, "sideQuest")}, nil
+	}
+
+	tests := []struct {
+		name       string
+		pushAction string
+	}{
+		{
+			name:       "BuiltinPush",
+			pushAction: PushCurrentStateActionName,
+		},
+		{
+			name:       "CustomPush",
+			pushAction: "customPush",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.RegisterAction("customPush", customPush)
+			fsm := NewStateMachine(sideQuestDefinition(tt.pushAction), registry, nil, WithMaxStackDepth(2))
+
+			ctx := context.Background()
+			state, data := "main", map[string]any{}
+			for i := 0; i < 2; i++ {
+				result, err := fsm.Trigger(ctx, state, "detour", data)
+				if err != nil {
+					t.Fatalf("Expected push %d to succeed, got %v", i+1, err)
+				}
+				state, data = result.NewState, result.PersistenceData
+			}
+
+			_, err := fsm.Trigger(ctx, state, "detour", data)
+			if err == nil {
+				t.Fatal("Expected error when exceeding max stack depth, got nil")
+			}
+
+			if !strings.Contains(err.Error(), "workflow stack depth exceeded (max 2)") {
+				t.Errorf("Expected stack depth error, got '%s'", err.Error())
+			}
+		})
+	}
+}
+
+func TestPushWorkflowStack_DoesNotModifyInput(t *testing.T) {
+	original := make([]string, 1, 4)
+	original[0] = "A"
+	data := map[string]any{WorkflowStackKey: original}
+
+	result, err := PushWorkflowStack(context.Background(), data, "B")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stack := result[WorkflowStackKey].([]string)
+	if len(stack) != 2 || stack[0] != "A" || stack[1] != "B" {
+		t.Errorf("Expected stack [A B], got %v", stack)
+	}
+
+	if extended := original[:2]; extended[1] != "" {
+		t.Errorf("Expected input backing array to be untouched, got %v", extended)
+	}
+}
+
+func TestPeekPreviousState(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          map[string]any
+		expectedState string
+		expectedOK    bool
+	}{
+		{
+			name:          "NonEmptyStack",
+			data:          map[string]any{WorkflowStackKey: []string{"A", "B"}},
+			expectedState: "B",
+			expectedOK:    true,
+		},
+		{
+			name:       "EmptyStack",
+			data:       map[string]any{WorkflowStackKey: []string{}},
+			expectedOK: false,
+		},
+		{
+			name:       "MissingStack",
+			data:       map[string]any{},
+			expectedOK: false,
+		},
+		{
+			name:       "WrongType",
+			data:       map[string]any{WorkflowStackKey: "A"},
+			expectedOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, ok := PeekPreviousState(tt.data)
+			if state != tt.expectedState || ok != tt.expectedOK {
+				t.Errorf("Expected ('%s', %v), got ('%s', %v)", tt.expectedState, tt.expectedOK, state, ok)
+			}
+		})
+	}
+
+	// Peeking never pops
+	data := map[string]any{WorkflowStackKey: []string{"A"}}
+	PeekPreviousState(data)
+	if len(data[WorkflowStackKey].([]string)) != 1 {
+		t.Error("Expected PeekPreviousState to leave the stack untouched")
+	}
+}
+
+func TestWithStrictSideQuests(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"main": {
+				Name: "main",
+				Transitions: []Transition{
+					{
+						Event:   "return",
+						Target:  "",
+						Actions: []string{ReturnToPreviousStateActionName},
+					},
+				},
+			},
+			"sideQuest": {
+				Name:        "sideQuest",
+				IsSideQuest: true,
+				Transitions: []Transition{
+					{
+						Event:   "return",
+						Target:  "",
+						Actions: []string{ReturnToPreviousStateActionName},
+					},
+				},
+			},
+		},
+	}
+	data := map[string]any{WorkflowStackKey: []string{"main"}}
+
+	t.Run("RejectsReturnFromNormalState", func(t *testing.T) {
+		fsm := NewStateMachine(definition, NewRegistry(), nil, WithStrictSideQuests())
+
+		_, err := fsm.Trigger(context.Background(), "main", "return", data)
+		if err == nil {
+			t.Fatal("Expected error for return from a normal state, got nil")
+		}
+
+		if err.Error() != "state main is not a side quest and cannot return to a previous state" {
+			t.Errorf("Unexpected error message '%s'", err.Error())
+		}
+	})
+
+	t.Run("AllowsReturnFromSideQuest", func(t *testing.T) {
+		fsm := NewStateMachine(definition, NewRegistry(), nil, WithStrictSideQuests())
+
+		result, err := fsm.Trigger(context.Background(), "sideQuest", "return", data)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if result.NewState != "main" {
+			t.Errorf("Expected new state to be 'main', got '%s'", result.NewState)
+		}
+	})
+
+	t.Run("LenientByDefault", func(t *testing.T) {
+		fsm := NewStateMachine(definition, NewRegistry(), nil)
+
+		if _, err := fsm.Trigger(context.Background(), "main", "return", data); err != nil {
+			t.Errorf("Expected no error without strict mode, got %v", err)
+		}
+	})
+}
+
+func TestWithoutPredefinedActions(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {Name: "start", Transitions: []Transition{{Event: "next", Target: "end"}}},
+			"end":   {Name: "end"},
+		},
+	}
+
+	registry := NewRegistry()
+	if _, err := NewStateMachineE(definition, registry, nil, WithoutPredefinedActions()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, name := range []string{ReturnToPreviousStateActionName, PushCurrentStateActionName} {
+		if _, err := registry.GetAction(name); err == nil {
+			t.Errorf("Expected %s not to be registered", name)
+		}
+	}
+
+	// A workflow relying on a disabled predefined action is rejected up front
+	_, err := NewStateMachineE(sideQuestDefinition(PushCurrentStateActionName), NewRegistry(), nil, WithoutPredefinedActions())
+	expected := "state sideQuest transition for event return uses predefined action __RETURN_TO_PREVIOUS_STATE__, which is disabled"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+}
+
+func TestPredefinedActions_ConcurrentMachines(t *testing.T) {
+	registry := NewRegistry()
+
+	// Machines created at the same time from one registry all see the built-in actions as
+	// registered, whichever of them registers them
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := NewStateMachineE(sideQuestDefinition(PushCurrentStateActionName), registry, nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+}
+
+func TestPredefinedActions_KeepExistingRegistration(t *testing.T) {
+	registry := NewRegistry()
+	var called bool
+	registry.RegisterAction(PushCurrentStateActionName, func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		called = true
+		return nil, nil
+	})
+
+	// The registry can be shared between machines without registration errors
+	for i := 0; i < 2; i++ {
+		if _, err := NewStateMachineE(sideQuestDefinition(PushCurrentStateActionName), registry, nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	fsm := NewStateMachine(sideQuestDefinition(PushCurrentStateActionName), registry, nil)
+	if _, err := fsm.Trigger(context.Background(), "main", "detour", map[string]any{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !called {
+		t.Error("Expected the caller's registration to be kept")
+	}
+}
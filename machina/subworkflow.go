@@ -0,0 +1,110 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+)
+
+// SubWorkflow embeds a reusable workflow, such as a KYC check, in a state of its parent.
+// Entering the state runs the child from its initial state to a terminal state, and the
+// outcome mapped to that terminal state becomes the parent's AutoEvent.
+//
+// The child starts with a copy of the parent's persistence data, and the parent continues
+// with the child's final persistence data.
+type SubWorkflow struct {
+	// Workflow names the child machine in the MachineRegistry passed to WithSubWorkflows
+	Workflow string `yaml:"workflow" json:"workflow"`
+	// StartEvent is triggered in the child's initial state. The child must reach a terminal
+	// state through auto events, so it is usually created with WithAutoEventChaining.
+	StartEvent string `yaml:"startEvent" json:"startEvent"`
+	// Outcomes maps each terminal state of the child to the event the parent fires next
+	Outcomes map[string]string `yaml:"outcomes" json:"outcomes"`
+}
+
+// WithSubWorkflows sets the machines that states declaring a SubWorkflow run, by workflow name
+func WithSubWorkflows(machines *MachineRegistry) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.subWorkflows = machines
+	}
+}
+
+// validate checks that the sub-workflow names its child, start event and outcomes, and that
+// the state handles every outcome event
+func (sw *SubWorkflow) validate(state *State) error {
+	if sw.Workflow == "" {
+		return fmt.Errorf("subWorkflow must name a workflow")
+	}
+	if sw.StartEvent == "" {
+		return fmt.Errorf("subWorkflow %s must have a startEvent", sw.Workflow)
+	}
+	if len(sw.Outcomes) == 0 {
+		return fmt.Errorf("subWorkflow %s must map at least one outcome", sw.Workflow)
+	}
+
+	for _, terminal := range sortedKeys(sw.Outcomes) {
+		if !state.acceptsEvent(sw.Outcomes[terminal]) {
+			return fmt.Errorf("subWorkflow %s outcome %s: event %s has no matching transition", sw.Workflow, terminal, sw.Outcomes[terminal])
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy of the sub-workflow
+func (sw *SubWorkflow) clone() *SubWorkflow {
+	if sw == nil {
+		return nil
+	}
+	clone := *sw
+	clone.Outcomes = maps.Clone(sw.Outcomes)
+	return &clone
+}
+
+// subWorkflowMachine looks up the child machine of a sub-workflow
+func (sm *StateMachine) subWorkflowMachine(name string) (*StateMachine, error) {
+	if sm.subWorkflows == nil {
+		return nil, fmt.Errorf("sub-workflow %s cannot run: no machines configured; use WithSubWorkflows", name)
+	}
+	return sm.subWorkflows.Get(name)
+}
+
+// runSubWorkflow runs the child workflow of the entered state to completion, replacing the
+// contents of persistenceData with the child's final data, and returns the parent event
+// mapped to the child's terminal state
+func (sm *StateMachine) runSubWorkflow(ctx context.Context, logger *slog.Logger, currentState, event, targetState string, sw *SubWorkflow, persistenceData map[string]any) (string, error) {
+	child, err := sm.subWorkflowMachine(sw.Workflow)
+	if err != nil {
+		sm.recordTransitionError(currentState, event, "subworkflow_not_found", err)
+		return "", err
+	}
+
+	initialState := child.definition.InitialState
+	if initialState == "" {
+		err := fmt.Errorf("sub-workflow %s has no initialState", sw.Workflow)
+		sm.recordTransitionError(currentState, event, "subworkflow_error", err)
+		return "", err
+	}
+
+	logger.Debug("Running sub-workflow", "state", targetState, "workflow", sw.Workflow)
+	result, err := child.Trigger(ctx, initialState, sw.StartEvent, maps.Clone(persistenceData))
+	if err != nil {
+		err = withKind(fmt.Errorf("sub-workflow %s failed: %w", sw.Workflow, err), errStepFailed)
+		sm.recordTransitionError(currentState, event, "subworkflow_error", err)
+		return "", err
+	}
+
+	outcome, ok := sw.Outcomes[result.NewState]
+	if !ok {
+		err := withKind(fmt.Errorf("sub-workflow %s ended in state %s, which has no outcome; expected one of %v",
+			sw.Workflow, result.NewState, sortedKeys(sw.Outcomes)), errStepFailed)
+		sm.recordTransitionError(currentState, event, "subworkflow_error", err)
+		return "", err
+	}
+
+	clear(persistenceData)
+	maps.Copy(persistenceData, result.PersistenceData)
+
+	logger.Debug("Sub-workflow completed", "state", targetState, "workflow", sw.Workflow, "terminal", result.NewState, "outcome", outcome)
+	return outcome, nil
+}
@@ -0,0 +1,169 @@
+package machina
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+// kycMachines returns a registry holding a two-state KYC child workflow that approves
+// customers with a document and rejects the rest
+func kycMachines(t *testing.T) *MachineRegistry {
+	t.Helper()
+
+	child := &WorkflowDefinition{
+		InitialState: "checking",
+		States: map[string]State{
+			"checking": {
+				Name: "checking",
+				Transitions: []Transition{
+					{Event: "begin", Target: "approved", Conditions: []string{"hasDocument"}, Actions: []string{"recordCheck"}},
+					{Event: "begin", Target: "rejected"},
+				},
+			},
+			"approved": {Name: "approved", IsFinal: true},
+			"rejected": {Name: "rejected", IsFinal: true},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("hasDocument", func(ctx context.Context, data map[string]any) (bool, error) {
+		_, ok := data["document"]
+		return ok, nil
+	})
+	registry.RegisterAction("recordCheck", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return map[string]any{"kycChecked": true}, nil
+	})
+
+	machines := NewMachineRegistry()
+	if err := machines.Register("kyc", NewStateMachine(child, registry, slog.Default())); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return machines
+}
+
+func TestStateMachine_Trigger_SubWorkflow(t *testing.T) {
+	parent := &WorkflowDefinition{
+		States: map[string]State{
+			"applied": {
+				Name:        "applied",
+				Transitions: []Transition{{Event: "submit", Target: "verifying"}},
+			},
+			"verifying": {
+				Name: "verifying",
+				SubWorkflow: &SubWorkflow{
+					Workflow:   "kyc",
+					StartEvent: "begin",
+					Outcomes:   map[string]string{"approved": "pass", "rejected": "fail"},
+				},
+				Transitions: []Transition{
+					{Event: "pass", Target: "active"},
+					{Event: "fail", Target: "declined"},
+				},
+			},
+			"active":   {Name: "active"},
+			"declined": {Name: "declined"},
+		},
+	}
+
+	machines := kycMachines(t)
+
+	tests := []struct {
+		name          string
+		payload       map[string]any
+		expectedEvent string
+		expectedFinal string
+	}{
+		{"Approved", map[string]any{"document": "passport"}, "pass", "active"},
+		{"Rejected", map[string]any{}, "fail", "declined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := NewStateMachine(parent, NewRegistry(), slog.Default(), WithSubWorkflows(machines))
+
+			result, err := fsm.Trigger(context.Background(), "applied", "submit", tt.payload)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.NewState != "verifying" || result.AutoEvent != tt.expectedEvent {
+				t.Errorf("Expected verifying with auto event %s, got %s with %q", tt.expectedEvent, result.NewState, result.AutoEvent)
+			}
+			if tt.expectedEvent == "pass" && result.PersistenceData["kycChecked"] != true {
+				t.Errorf("Expected the child's data to be returned, got %v", result.PersistenceData)
+			}
+			if _, exists := tt.payload["kycChecked"]; exists {
+				t.Error("Expected the caller's payload not to be modified")
+			}
+
+			chained := NewStateMachine(parent, NewRegistry(), slog.Default(), WithSubWorkflows(machines), WithAutoEventChaining(5))
+			result, err = chained.Trigger(context.Background(), "applied", "submit", tt.payload)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.NewState != tt.expectedFinal {
+				t.Errorf("Expected the chain to end in %s, got %s", tt.expectedFinal, result.NewState)
+			}
+		})
+	}
+}
+
+func TestStateMachine_Trigger_SubWorkflowErrors(t *testing.T) {
+	parent := &WorkflowDefinition{
+		States: map[string]State{
+			"applied": {
+				Name:        "applied",
+				Transitions: []Transition{{Event: "submit", Target: "verifying"}},
+			},
+			"verifying": {
+				Name: "verifying",
+				SubWorkflow: &SubWorkflow{
+					Workflow:   "kyc",
+					StartEvent: "begin",
+					Outcomes:   map[string]string{"approved": "pass"},
+				},
+				Transitions: []Transition{{Event: "pass", Target: "active"}},
+			},
+			"active": {Name: "active"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		opts        []StateMachineOption
+		expectedErr string
+	}{
+		{
+			name:        "NoMachines",
+			expectedErr: "sub-workflow kyc cannot run: no machines configured; use WithSubWorkflows",
+		},
+		{
+			name:        "UnmappedTerminalState",
+			opts:        []StateMachineOption{WithSubWorkflows(kycMachines(t))},
+			expectedErr: "sub-workflow kyc ended in state rejected, which has no outcome; expected one of [approved]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := NewStateMachine(parent, NewRegistry(), slog.Default(), tt.opts...)
+
+			result, err := fsm.Trigger(context.Background(), "applied", "submit", map[string]any{})
+
+			var rolledBack *ErrEnterRolledBack
+			if !errors.As(err, &rolledBack) {
+				t.Fatalf("Expected *ErrEnterRolledBack, got %v", err)
+			}
+			if rolledBack.Err.Error() != tt.expectedErr {
+				t.Errorf("Expected %q, got %q", tt.expectedErr, rolledBack.Err.Error())
+			}
+			if result.NewState != "applied" {
+				t.Errorf("Expected to remain in applied, got %s", result.NewState)
+			}
+			if err := fsm.Verify(); tt.name == "NoMachines" && err == nil {
+				t.Error("Expected Verify to report the missing sub-workflow")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package machina
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimeoutStartKey is the persistence data key WatchTimeout reads the time an instance
+// entered its state from. Store a time.Time under it so a watch started later, for
+// example after a restart, only waits for the remaining time. The RFC 3339 string a
+// time.Time becomes in JSON is accepted as well.
+const TimeoutStartKey = "TimeoutStart"
+
+// WatchTimeout waits for the timeout declared on the state to elapse and then sends its
+// TimeoutEvent on the returned channel, for the caller to pass to Trigger. The timeout
+// is measured from data[TimeoutStartKey] if set, otherwise from now, and waited for with
+// the machine's clock, so a FakeClock fires it when advanced. The channel is closed after
+// the event is sent, or without sending if ctx is done first.
+func (sm *StateMachine) WatchTimeout(ctx context.Context, state string, data map[string]any) (<-chan string, error) {
+	stateDef, err := sm.getStateDefinition(state)
+	if err != nil {
+		return nil, err
+	}
+
+	if stateDef.Timeout <= 0 || stateDef.TimeoutEvent == "" {
+		return nil, fmt.Errorf("state %s has no timeout", state)
+	}
+
+	wait := stateDef.Timeout
+	start, ok, err := timeoutStart(data)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		wait -= sm.clock.Now().Sub(start)
+	}
+
+	// Registered before returning so that a clock advanced right after the call fires it
+	elapsed := sm.clock.After(wait)
+	events := make(chan string, 1)
+	go func() {
+		defer close(events)
+
+		select {
+		case <-elapsed:
+			sm.logger.Info("State timed out", "state", state, "event", stateDef.TimeoutEvent, "timeout", stateDef.Timeout)
+			events <- stateDef.TimeoutEvent
+		case <-ctx.Done():
+		}
+	}()
+
+	return events, nil
+}
+
+// CheckDwell reports whether an instance that entered state at enteredAt has stayed longer
+// than the state's MaxDwell, and if so the TimeoutEvent to trigger. It returns false for
+// unknown states and states without MaxDwell. The current time comes from WithClock.
+func (sm *StateMachine) CheckDwell(state string, enteredAt time.Time) (event string, expired bool) {
+	stateDef, err := sm.getStateDefinition(state)
+	if err != nil || stateDef.MaxDwell <= 0 {
+		return "", false
+	}
+
+	if sm.clock.Now().Sub(enteredAt) <= stateDef.MaxDwell {
+		return "", false
+	}
+	return stateDef.TimeoutEvent, true
+}
+
+// timeoutStart reads data[TimeoutStartKey] as a time.Time or an RFC 3339 string, and
+// reports false if it is not set
+func timeoutStart(data map[string]any) (time.Time, bool, error) {
+	switch start := data[TimeoutStartKey].(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return start, true, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, start)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s: %w", TimeoutStartKey, err)
+		}
+		return parsed, true, nil
+	default:
+		return time.Time{}, false, fmt.Errorf("invalid %s: expected a time or an RFC 3339 string, got %T", TimeoutStartKey, start)
+	}
+}
@@ -0,0 +1,190 @@
+package machina
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// timeoutTestMachine returns a machine whose "waiting" state times out after the given duration
+func timeoutTestMachine(timeout time.Duration, opts ...StateMachineOption) *StateMachine {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"waiting": {
+				Name:         "waiting",
+				Timeout:      timeout,
+				TimeoutEvent: "timeout",
+				Transitions: []Transition{
+					{Event: "timeout", Target: "expired"},
+				},
+			},
+			"expired": {
+				Name: "expired",
+			},
+		},
+	}
+	return NewStateMachine(definition, NewRegistry(), nil, opts...)
+}
+
+func TestStateMachine_WatchTimeout(t *testing.T) {
+	fsm := timeoutTestMachine(20 * time.Millisecond)
+
+	events, err := fsm.WatchTimeout(context.Background(), "waiting", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event != "timeout" {
+			t.Fatalf("Expected timeout event, got %q", event)
+		}
+		result, err := fsm.Trigger(context.Background(), "waiting", event, map[string]any{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.NewState != "expired" {
+			t.Errorf("Expected state expired, got %s", result.NewState)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected timeout event within a second")
+	}
+
+	if _, open := <-events; open {
+		t.Error("Expected channel to be closed after the event")
+	}
+}
+
+func TestStateMachine_WatchTimeout_Cancelled(t *testing.T) {
+	fsm := timeoutTestMachine(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := fsm.WatchTimeout(ctx, "waiting", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cancel()
+
+	select {
+	case event, open := <-events:
+		if open {
+			t.Errorf("Expected channel to close without an event, got %q", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected channel to close after cancellation")
+	}
+}
+
+func TestStateMachine_WatchTimeout_ResumesFromStart(t *testing.T) {
+	fsm := timeoutTestMachine(time.Hour)
+
+	// The instance entered the state long enough ago that the timeout has already elapsed
+	data := map[string]any{TimeoutStartKey: time.Now().Add(-2 * time.Hour)}
+	events, err := fsm.WatchTimeout(context.Background(), "waiting", data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event != "timeout" {
+			t.Errorf("Expected timeout event, got %q", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected elapsed timeout to fire immediately")
+	}
+}
+
+func TestStateMachine_WatchTimeout_ResumesFromJSONStart(t *testing.T) {
+	fsm := timeoutTestMachine(time.Hour)
+
+	// A start time that went through JSON is an RFC 3339 string
+	data := map[string]any{TimeoutStartKey: time.Now().Add(-2 * time.Hour).Format(time.RFC3339Nano)}
+	events, err := fsm.WatchTimeout(context.Background(), "waiting", data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event != "timeout" {
+			t.Errorf("Expected timeout event, got %q", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected elapsed timeout to fire immediately")
+	}
+
+	_, err = fsm.WatchTimeout(context.Background(), "waiting", map[string]any{TimeoutStartKey: "yesterday"})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid TimeoutStart: ") {
+		t.Errorf("Expected an invalid TimeoutStart error, got %v", err)
+	}
+}
+
+func TestStateMachine_WatchTimeout_Errors(t *testing.T) {
+	fsm := timeoutTestMachine(time.Minute)
+
+	if _, err := fsm.WatchTimeout(context.Background(), "expired", nil); err == nil || err.Error() != "state expired has no timeout" {
+		t.Errorf("Expected no timeout error, got %v", err)
+	}
+
+	if _, err := fsm.WatchTimeout(context.Background(), "missing", nil); err == nil {
+		t.Error("Expected error for unknown state, got nil")
+	}
+}
+
+func TestState_TimeoutYAML(t *testing.T) {
+	var state State
+	if err := yaml.Unmarshal([]byte("name: waiting\ntimeout: 90s\ntimeoutEvent: timeout\n"), &state); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if state.Timeout != 90*time.Second || state.TimeoutEvent != "timeout" {
+		t.Errorf("Expected 90s timeout with event timeout, got %v %q", state.Timeout, state.TimeoutEvent)
+	}
+}
+
+func TestStateMachine_CheckDwell(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"awaitingPayment": {
+				Name:         "awaitingPayment",
+				MaxDwell:     48 * time.Hour,
+				TimeoutEvent: "expire",
+				Transitions:  []Transition{{Event: "expire", Target: "expired"}},
+			},
+			"expired": {Name: "expired"},
+		},
+	}
+
+	enteredAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(enteredAt)
+	fsm := NewStateMachine(definition, NewRegistry(), nil, WithClock(clock))
+
+	tests := []struct {
+		name          string
+		state         string
+		elapsed       time.Duration
+		expectedEvent string
+		expectExpired bool
+	}{
+		{"WithinBudget", "awaitingPayment", 47 * time.Hour, "", false},
+		{"AtBudget", "awaitingPayment", 48 * time.Hour, "", false},
+		{"OverBudget", "awaitingPayment", 49 * time.Hour, "expire", true},
+		{"NoMaxDwell", "expired", 1000 * time.Hour, "", false},
+		{"UnknownState", "missing", 1000 * time.Hour, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.Set(enteredAt.Add(tt.elapsed))
+
+			event, expired := fsm.CheckDwell(tt.state, enteredAt)
+			if event != tt.expectedEvent || expired != tt.expectExpired {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.expectedEvent, tt.expectExpired, event, expired)
+			}
+		})
+	}
+}
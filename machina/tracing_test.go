@@ -0,0 +1,71 @@
+package machina
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStateMachine_Trigger_SpanEvents(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:    "start",
+				OnLeave: []string{"leaveAction"},
+				Transitions: []Transition{
+					{Event: "proceed", Target: "end", Conditions: []string{"alwaysTrue"}, Actions: []string{"updateAction"}},
+				},
+			},
+			"end": {
+				Name:    "end",
+				OnEnter: []string{"enterAction"},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("alwaysTrue", MockTrueCondition)
+	registry.RegisterAction("updateAction", MockUpdateAction)
+	registry.RegisterAction("leaveAction", MockNoOpAction)
+	registry.RegisterAction("enterAction", MockNoOpAction)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	fsm := NewStateMachine(definition, registry, nil, WithTracer(provider.Tracer("test")))
+
+	if _, err := fsm.Trigger(context.Background(), "start", "proceed", map[string]any{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	var got []string
+	for _, event := range spans[0].Events() {
+		attrs := attribute.NewSet(event.Attributes...)
+		if _, ok := attrs.Value(attribute.Key(event.Name + ".duration_seconds")); !ok {
+			t.Errorf("Expected event %s to carry a duration, got %v", event.Name, event.Attributes)
+		}
+		switch event.Name {
+		case "fsm.condition":
+			name, _ := attrs.Value("fsm.condition.name")
+			result, _ := attrs.Value("fsm.condition.result")
+			got = append(got, "condition:"+name.AsString()+":"+result.AsString())
+		case "fsm.action":
+			phase, _ := attrs.Value("fsm.action.phase")
+			name, _ := attrs.Value("fsm.action.name")
+			got = append(got, phase.AsString()+":"+name.AsString())
+		}
+	}
+
+	expected := []string{"condition:alwaysTrue:pass", "transition:updateAction", "onleave:leaveAction", "onenter:enterAction"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected span events %v, got %v", expected, got)
+	}
+}
@@ -98,3 +98,31 @@ func TestMergeData_BothEmpty(t *testing.T) {
 		t.Errorf("Expected empty map, got map with %d elements", len(result))
 	}
 }
+
+func TestMergeData_Delete(t *testing.T) {
+	sm := &StateMachine{}
+
+	original := map[string]any{
+		"key1": "value1",
+		"key2": "value2",
+	}
+	updates := map[string]any{
+		"key1": Delete,
+		"key3": Delete,
+	}
+
+	result := sm.mergeData(original, updates)
+
+	if _, ok := result["key1"]; ok {
+		t.Errorf("Expected key1 to be removed, got '%v'", result["key1"])
+	}
+	if _, ok := result["key3"]; ok {
+		t.Error("Expected key3 not to be added")
+	}
+	if result["key2"] != "value2" {
+		t.Errorf("Expected key2 to be 'value2', got '%v'", result["key2"])
+	}
+	if original["key1"] != "value1" {
+		t.Error("Expected original map not to be modified")
+	}
+}
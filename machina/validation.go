@@ -2,9 +2,12 @@ package machina
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 )
 
-// Validate checks if the workflow definition is valid
+// Validate checks if the workflow definition is valid.
+// States are checked in name order, so the same definition always reports the same error.
 func (wd *WorkflowDefinition) Validate() error {
 	if len(wd.States) == 0 {
 		return fmt.Errorf("workflow must have at least one state")
@@ -17,8 +20,15 @@ func (wd *WorkflowDefinition) Validate() error {
 		}
 	}
 
-	// Validate each state
-	for name, state := range wd.States {
+	if wd.ErrorState != "" {
+		if _, exists := wd.States[wd.ErrorState]; !exists {
+			return fmt.Errorf("errorState %s not found in states", wd.ErrorState)
+		}
+	}
+
+	// Validate each state in name order so the reported error is deterministic
+	for _, name := range wd.sortedStateNames() {
+		state := wd.States[name]
 		if name != state.Name {
 			return fmt.Errorf("state key %s does not match state name %s", name, state.Name)
 		}
@@ -28,6 +38,28 @@ func (wd *WorkflowDefinition) Validate() error {
 		}
 	}
 
+	return wd.validateAutoEvents()
+}
+
+// validateAutoEvents checks that every auto event is handled by the state its transition
+// enters, so auto-event chains cannot dead-end. Dynamic and unknown targets are skipped.
+func (wd *WorkflowDefinition) validateAutoEvents() error {
+	for _, name := range wd.sortedStateNames() {
+		for _, transition := range wd.States[name].Transitions {
+			if transition.AutoEvent == "" {
+				continue
+			}
+			target, exists := wd.States[transition.Target]
+			if !exists {
+				continue
+			}
+			if !target.acceptsEvent(transition.AutoEvent) {
+				return fmt.Errorf("state %s: transition to %s sets autoEvent %s but state %s has no transition for %s",
+					name, transition.Target, transition.AutoEvent, transition.Target, transition.AutoEvent)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -37,11 +69,68 @@ func (s *State) Validate() error {
 		return fmt.Errorf("state must have a name")
 	}
 
+	if s.IsFinal && len(s.Transitions) > 0 {
+		return fmt.Errorf("final state must not have transitions")
+	}
+
+	if err := s.validateTimeout(); err != nil {
+		return err
+	}
+
+	if err := s.validateDeferredEvents(); err != nil {
+		return err
+	}
+
+	if s.SubWorkflow != nil {
+		if s.IsFinal {
+			return fmt.Errorf("final state must not have a subWorkflow")
+		}
+		if err := s.SubWorkflow.validate(s); err != nil {
+			return err
+		}
+	}
+
 	// Validate transitions
 	for _, transition := range s.Transitions {
 		if err := transition.Validate(); err != nil {
 			return fmt.Errorf("invalid transition for event %s: %w", transition.Event, err)
 		}
+		if transition.Internal && transition.Target != s.Name {
+			return fmt.Errorf("internal transition for event %s must target state %s", transition.Event, s.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateTimeout checks that a timeout or maximum dwell time has a positive duration and
+// an event the state handles
+func (s *State) validateTimeout() error {
+	if s.Timeout == 0 && s.MaxDwell == 0 && s.TimeoutEvent == "" {
+		return nil
+	}
+
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout must be positive when set")
+	}
+
+	if s.Timeout == 0 && s.MaxDwell == 0 {
+		return fmt.Errorf("timeout must be positive when timeoutEvent is set")
+	}
+
+	if s.MaxDwell < 0 {
+		return fmt.Errorf("maxDwell must be positive when set")
+	}
+
+	if s.TimeoutEvent == "" {
+		if s.Timeout == 0 {
+			return fmt.Errorf("timeoutEvent is required when maxDwell is set")
+		}
+		return fmt.Errorf("timeoutEvent is required when timeout is set")
+	}
+
+	if !s.acceptsEvent(s.TimeoutEvent) {
+		return fmt.Errorf("timeout event %s has no matching transition", s.TimeoutEvent)
 	}
 
 	return nil
@@ -53,8 +142,226 @@ func (t *Transition) Validate() error {
 		return fmt.Errorf("transition must have an event")
 	}
 
+	if err := t.validateMatch(); err != nil {
+		return err
+	}
+
+	if err := t.validateWebhooks(); err != nil {
+		return err
+	}
+
+	if t.RateLimit != nil {
+		if err := t.RateLimit.validate(); err != nil {
+			return err
+		}
+	}
+
+	if t.Weight < 0 {
+		return fmt.Errorf("weight must not be negative")
+	}
+
+	for i, group := range t.Parallel {
+		if len(group) == 0 {
+			return fmt.Errorf("parallel group %d must list at least one action", i)
+		}
+	}
+
+	if len(t.AutoEventConditions) > 0 && t.AutoEvent == "" {
+		return fmt.Errorf("autoEventConditions require an autoEvent")
+	}
+
+	for conditionName := range t.ConditionArgs {
+		if !slices.Contains(t.Conditions, conditionName) && !slices.Contains(t.Preconditions, conditionName) && !slices.Contains(t.AutoEventConditions, conditionName) {
+			return fmt.Errorf("arguments given for unlisted condition %s", conditionName)
+		}
+	}
+
 	// Target can be empty for dynamic transitions that will be determined at runtime
-	// by actions that return a __next_state_override value
+	// by actions that call SetNextState
 
 	return nil
 }
+
+// ValidateStrict runs Validate and also rejects empty and repeated action and condition
+// names. Listing the same action twice is usually a copy-paste mistake that would run it
+// twice, but Validate allows it for workflows that repeat entries on purpose.
+func (t *Transition) ValidateStrict() error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	if err := validateNames("action", t.Actions); err != nil {
+		return err
+	}
+
+	if err := validateNames("condition", t.Conditions); err != nil {
+		return err
+	}
+
+	return validateNames("precondition", t.Preconditions)
+}
+
+// validateNames checks that a list of names has no empty or repeated entries
+func validateNames(kind string, names []string) error {
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		if name == "" {
+			return fmt.Errorf("%s %d has an empty name", kind, i)
+		}
+		if seen[name] {
+			return fmt.Errorf("%s %s is listed more than once", kind, name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+// ValidateStrict runs Validate, rejects states with no transitions that are not marked
+// IsFinal, as such dead ends are usually bugs, and applies Transition.ValidateStrict to
+// every transition
+func (wd *WorkflowDefinition) ValidateStrict() error {
+	if err := wd.Validate(); err != nil {
+		return err
+	}
+
+	for _, name := range wd.sortedStateNames() {
+		state := wd.States[name]
+		if len(state.Transitions) == 0 && !state.IsFinal {
+			return fmt.Errorf("state %s has no transitions but is not marked isFinal", name)
+		}
+
+		for _, transition := range state.Transitions {
+			if err := transition.ValidateStrict(); err != nil {
+				return fmt.Errorf("state %s: invalid transition for event %s: %w", name, transition.Event, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateTargets checks that every non-empty transition target refers to a declared state.
+// Empty targets are allowed since they are resolved at runtime by dynamic transitions.
+func (wd *WorkflowDefinition) ValidateTargets() error {
+	for _, name := range wd.sortedStateNames() {
+		for _, transition := range wd.States[name].Transitions {
+			if transition.Target == "" {
+				continue
+			}
+			if _, exists := wd.States[transition.Target]; !exists {
+				return fmt.Errorf("state %s: transition for event %s targets unknown state %s", name, transition.Event, transition.Target)
+			}
+		}
+	}
+
+	return nil
+}
+
+// UnreachableStates returns the names of states that cannot be reached from the initial state
+// by following declared transition targets. The error state, when it exists, is treated as a
+// second starting point since Trigger can move there from any state. It returns nil if no
+// initial state is set.
+func (wd *WorkflowDefinition) UnreachableStates() []string {
+	if wd.InitialState == "" {
+		return nil
+	}
+
+	visited := map[string]bool{wd.InitialState: true}
+	queue := []string{wd.InitialState}
+	if _, exists := wd.States[wd.ErrorState]; exists && !visited[wd.ErrorState] {
+		visited[wd.ErrorState] = true
+		queue = append(queue, wd.ErrorState)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, transition := range wd.States[current].Transitions {
+			if transition.Target == "" || visited[transition.Target] {
+				continue
+			}
+			if _, exists := wd.States[transition.Target]; !exists {
+				continue
+			}
+			visited[transition.Target] = true
+			queue = append(queue, transition.Target)
+		}
+	}
+
+	var unreachable []string
+	for name := range wd.States {
+		if !visited[name] {
+			unreachable = append(unreachable, name)
+		}
+	}
+	sort.Strings(unreachable)
+
+	return unreachable
+}
+
+// ValidateRegistry checks that every action, condition and payload validator referenced by
+// the workflow is registered in the given registry. Built-in actions are always considered known.
+func (wd *WorkflowDefinition) ValidateRegistry(registry *Registry) error {
+	for _, name := range wd.sortedStateNames() {
+		state := wd.States[name]
+		if err := validateActionNames(registry, state.OnEnter); err != nil {
+			return fmt.Errorf("state %s onEnter: %w", name, err)
+		}
+		if err := validateActionNames(registry, state.OnLeave); err != nil {
+			return fmt.Errorf("state %s onLeave: %w", name, err)
+		}
+		if err := validateActionNames(registry, state.OnReenter); err != nil {
+			return fmt.Errorf("state %s onReenter: %w", name, err)
+		}
+
+		for _, transition := range state.Transitions {
+			for _, conditionName := range transition.Conditions {
+				if _, err := registry.GetCondition(conditionName); err != nil {
+					return fmt.Errorf("state %s transition for event %s: %w", name, transition.Event, err)
+				}
+			}
+			for _, conditionName := range transition.Preconditions {
+				if _, err := registry.GetCondition(conditionName); err != nil {
+					return fmt.Errorf("state %s transition for event %s preconditions: %w", name, transition.Event, err)
+				}
+			}
+			for _, conditionName := range transition.AutoEventConditions {
+				if _, err := registry.GetCondition(conditionName); err != nil {
+					return fmt.Errorf("state %s transition for event %s autoEventConditions: %w", name, transition.Event, err)
+				}
+			}
+			for _, validatorName := range transition.Validators {
+				if _, err := registry.GetPayloadValidator(validatorName); err != nil {
+					return fmt.Errorf("state %s transition for event %s: %w", name, transition.Event, err)
+				}
+			}
+			if err := validateActionNames(registry, transition.Actions); err != nil {
+				return fmt.Errorf("state %s transition for event %s: %w", name, transition.Event, err)
+			}
+			for i, group := range transition.Parallel {
+				if err := validateActionNames(registry, group); err != nil {
+					return fmt.Errorf("state %s transition for event %s parallel group %d: %w", name, transition.Event, i, err)
+				}
+			}
+			if err := validateActionNames(registry, transition.OnError); err != nil {
+				return fmt.Errorf("state %s transition for event %s onError: %w", name, transition.Event, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateActionNames checks that each of the named actions is registered or built in
+func validateActionNames(registry *Registry, actions []string) error {
+	for _, actionName := range actions {
+		if actionName == ReturnToPreviousStateActionName || actionName == PushCurrentStateActionName {
+			continue
+		}
+		if _, err := registry.GetAction(actionName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
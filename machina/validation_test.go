@@ -2,6 +2,7 @@ package machina
 
 import (
 	"testing"
+	"time"
 )
 
 func TestWorkflowDefinition_Validate(t *testing.T) {
@@ -79,6 +80,58 @@ func TestWorkflowDefinition_Validate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "initialState nonexistent not found in states",
 		},
+		{
+			name: "HandledAutoEvent",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name:        "start",
+						Transitions: []Transition{{Event: "submit", Target: "processOrder", AutoEvent: "process"}},
+					},
+					"processOrder": {
+						Name:        "processOrder",
+						Transitions: []Transition{{Event: "process", Target: "end"}},
+					},
+					"end": {Name: "end"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "AutoEventHandledByWildcard",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name:        "start",
+						Transitions: []Transition{{Event: "submit", Target: "processOrder", AutoEvent: "process"}},
+					},
+					"processOrder": {
+						Name:        "processOrder",
+						Transitions: []Transition{{Event: WildcardEvent, Target: "end"}},
+					},
+					"end": {Name: "end"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "DanglingAutoEvent",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name:        "start",
+						Transitions: []Transition{{Event: "submit", Target: "processOrder", AutoEvent: "proces"}},
+					},
+					"processOrder": {
+						Name:        "processOrder",
+						Transitions: []Transition{{Event: "process", Target: "end"}},
+					},
+					"end": {Name: "end"},
+				},
+			},
+			expectError: true,
+			errorMsg:    "state start: transition to processOrder sets autoEvent proces but state processOrder has no transition for proces",
+		},
 	}
 
 	for _, tt := range tests {
@@ -99,6 +152,21 @@ func TestWorkflowDefinition_Validate(t *testing.T) {
 	}
 }
 
+func TestWorkflowDefinition_ValidateDeterministic(t *testing.T) {
+	definition := &WorkflowDefinition{States: map[string]State{}}
+	for _, name := range []string{"d", "b", "e", "a", "c"} {
+		// Every state is invalid, so the error must come from the first in name order
+		definition.States[name] = State{Name: name, Transitions: []Transition{{Target: "a"}}}
+	}
+
+	for i := 0; i < 20; i++ {
+		err := definition.Validate()
+		if err == nil || err.Error() != "invalid state a: invalid transition for event : transition must have an event" {
+			t.Fatalf("Expected error for state a, got %v", err)
+		}
+	}
+}
+
 func TestState_Validate(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -106,6 +174,114 @@ func TestState_Validate(t *testing.T) {
 		expectError bool
 		errorMsg    string
 	}{
+		{
+			name: "ValidTimeout",
+			state: &State{
+				Name:         "waiting",
+				Timeout:      time.Minute,
+				TimeoutEvent: "timeout",
+				Transitions:  []Transition{{Event: "timeout", Target: "expired"}},
+			},
+			expectError: false,
+		},
+		{
+			name: "InternalTransitionToOtherState",
+			state: &State{
+				Name:        "editing",
+				Transitions: []Transition{{Event: "save", Target: "saved", Internal: true}},
+			},
+			expectError: true,
+			errorMsg:    "internal transition for event save must target state editing",
+		},
+		{
+			name: "DeferredEventWithTransition",
+			state: &State{
+				Name:           "paying",
+				DeferredEvents: []string{"ship"},
+				Transitions:    []Transition{{Event: "ship", Target: "shipped"}},
+			},
+			expectError: true,
+			errorMsg:    "deferred event ship has a matching transition",
+		},
+		{
+			name: "TimeoutEventWithoutTransition",
+			state: &State{
+				Name:         "waiting",
+				Timeout:      time.Minute,
+				TimeoutEvent: "timeout",
+				Transitions:  []Transition{{Event: "proceed", Target: "end"}},
+			},
+			expectError: true,
+			errorMsg:    "timeout event timeout has no matching transition",
+		},
+		{
+			name: "TimeoutWithoutEvent",
+			state: &State{
+				Name:    "waiting",
+				Timeout: time.Minute,
+			},
+			expectError: true,
+			errorMsg:    "timeoutEvent is required when timeout is set",
+		},
+		{
+			name: "TimeoutEventWithoutDuration",
+			state: &State{
+				Name:         "waiting",
+				TimeoutEvent: "timeout",
+				Transitions:  []Transition{{Event: "timeout", Target: "expired"}},
+			},
+			expectError: true,
+			errorMsg:    "timeout must be positive when timeoutEvent is set",
+		},
+		{
+			name: "NegativeTimeoutWithoutEvent",
+			state: &State{
+				Name:    "waiting",
+				Timeout: -time.Minute,
+			},
+			expectError: true,
+			errorMsg:    "timeout must be positive when set",
+		},
+		{
+			name: "ValidMaxDwell",
+			state: &State{
+				Name:         "waiting",
+				MaxDwell:     time.Hour,
+				TimeoutEvent: "timeout",
+				Transitions:  []Transition{{Event: "timeout", Target: "expired"}},
+			},
+			expectError: false,
+		},
+		{
+			name: "SubWorkflowOutcomeWithoutTransition",
+			state: &State{
+				Name:        "verifying",
+				SubWorkflow: &SubWorkflow{Workflow: "kyc", StartEvent: "begin", Outcomes: map[string]string{"approved": "pass"}},
+				Transitions: []Transition{{Event: "fail", Target: "declined"}},
+			},
+			expectError: true,
+			errorMsg:    "subWorkflow kyc outcome approved: event pass has no matching transition",
+		},
+		{
+			name: "MaxDwellWithoutEvent",
+			state: &State{
+				Name:     "waiting",
+				MaxDwell: time.Hour,
+			},
+			expectError: true,
+			errorMsg:    "timeoutEvent is required when maxDwell is set",
+		},
+		{
+			name: "MaxDwellEventWithoutTransition",
+			state: &State{
+				Name:         "waiting",
+				MaxDwell:     time.Hour,
+				TimeoutEvent: "timeout",
+				Transitions:  []Transition{{Event: "proceed", Target: "end"}},
+			},
+			expectError: true,
+			errorMsg:    "timeout event timeout has no matching transition",
+		},
 		{
 			name: "ValidState",
 			state: &State{
@@ -133,6 +309,29 @@ func TestState_Validate(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "ValidFinalState",
+			state: &State{
+				Name:    "end",
+				IsFinal: true,
+			},
+			expectError: false,
+		},
+		{
+			name: "FinalStateWithTransitions",
+			state: &State{
+				Name:    "end",
+				IsFinal: true,
+				Transitions: []Transition{
+					{
+						Event:  "restart",
+						Target: "start",
+					},
+				},
+			},
+			expectError: true,
+			errorMsg:    "final state must not have transitions",
+		},
 		{
 			name: "StateWithNoName",
 			state: &State{
@@ -205,6 +404,95 @@ func TestTransition_Validate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "transition must have an event",
 		},
+		{
+			name: "ValidRegexMatch",
+			transition: &Transition{
+				Event:  `order\.(created|updated)`,
+				Match:  MatchRegex,
+				Target: "end",
+			},
+			expectError: false,
+		},
+		{
+			name: "InvalidRegexMatch",
+			transition: &Transition{
+				Event:  "order.(created",
+				Match:  MatchRegex,
+				Target: "end",
+			},
+			expectError: true,
+			errorMsg:    "invalid event pattern order.(created: error parsing regexp: missing closing ): `^(?:order.(created)$`",
+		},
+		{
+			name: "RelativeWebhookURL",
+			transition: &Transition{
+				Event:    "proceed",
+				Target:   "end",
+				Webhooks: []string{"/hooks/proceed"},
+			},
+			expectError: true,
+			errorMsg:    "webhook /hooks/proceed must be an absolute http or https URL",
+		},
+		{
+			name: "UnknownMatchMode",
+			transition: &Transition{
+				Event:  "proceed",
+				Match:  "glob",
+				Target: "end",
+			},
+			expectError: true,
+			errorMsg:    "unknown match mode glob",
+		},
+		{
+			name: "ConditionArgsForListedCondition",
+			transition: &Transition{
+				Event:         "proceed",
+				Target:        "end",
+				Conditions:    []string{"amountGreaterThan"},
+				ConditionArgs: map[string]map[string]any{"amountGreaterThan": {"min": 100}},
+			},
+			expectError: false,
+		},
+		{
+			name: "ConditionArgsForUnlistedCondition",
+			transition: &Transition{
+				Event:         "proceed",
+				Target:        "end",
+				ConditionArgs: map[string]map[string]any{"amountGreaterThan": {"min": 100}},
+			},
+			expectError: true,
+			errorMsg:    "arguments given for unlisted condition amountGreaterThan",
+		},
+		{
+			name: "NegativeWeight",
+			transition: &Transition{
+				Event:  "route",
+				Target: "canary",
+				Weight: -1,
+			},
+			expectError: true,
+			errorMsg:    "weight must not be negative",
+		},
+		{
+			name: "RateLimitWithoutPeriod",
+			transition: &Transition{
+				Event:     "retry",
+				Target:    "processing",
+				RateLimit: &RateLimit{Max: 3},
+			},
+			expectError: true,
+			errorMsg:    "rateLimit per must be positive",
+		},
+		{
+			name: "AutoEventConditionsWithoutAutoEvent",
+			transition: &Transition{
+				Event:               "declined",
+				Target:              "failed",
+				AutoEventConditions: []string{"attemptsBelow"},
+			},
+			expectError: true,
+			errorMsg:    "autoEventConditions require an autoEvent",
+		},
 	}
 
 	for _, tt := range tests {
@@ -225,6 +513,155 @@ func TestTransition_Validate(t *testing.T) {
 	}
 }
 
+func TestTransition_ValidateStrict(t *testing.T) {
+	tests := []struct {
+		name        string
+		transition  *Transition
+		expectError bool
+		errorMsg    string
+	}{
+		{
+			name: "DistinctNames",
+			transition: &Transition{
+				Event:      "pay",
+				Target:     "paid",
+				Actions:    []string{"reserve", "charge"},
+				Conditions: []string{"hasCard", "withinLimit"},
+			},
+			expectError: false,
+		},
+		{
+			name: "DuplicateAction",
+			transition: &Transition{
+				Event:   "pay",
+				Target:  "paid",
+				Actions: []string{"charge", "notify", "charge"},
+			},
+			expectError: true,
+			errorMsg:    "action charge is listed more than once",
+		},
+		{
+			name: "DuplicateCondition",
+			transition: &Transition{
+				Event:      "pay",
+				Target:     "paid",
+				Conditions: []string{"hasCard", "hasCard"},
+			},
+			expectError: true,
+			errorMsg:    "condition hasCard is listed more than once",
+		},
+		{
+			name: "EmptyActionName",
+			transition: &Transition{
+				Event:   "pay",
+				Target:  "paid",
+				Actions: []string{"charge", ""},
+			},
+			expectError: true,
+			errorMsg:    "action 1 has an empty name",
+		},
+		{
+			name: "EmptyConditionName",
+			transition: &Transition{
+				Event:      "pay",
+				Target:     "paid",
+				Conditions: []string{""},
+			},
+			expectError: true,
+			errorMsg:    "condition 0 has an empty name",
+		},
+		{
+			name: "BasicChecksStillApply",
+			transition: &Transition{
+				Target:  "paid",
+				Actions: []string{"charge"},
+			},
+			expectError: true,
+			errorMsg:    "transition must have an event",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validate itself stays lenient about repeated names
+			if tt.errorMsg != "transition must have an event" {
+				if err := tt.transition.Validate(); err != nil {
+					t.Fatalf("Expected Validate to accept the transition, got %v", err)
+				}
+			}
+
+			err := tt.transition.ValidateStrict()
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				} else if err.Error() != tt.errorMsg {
+					t.Errorf("Expected error message '%s', got '%s'", tt.errorMsg, err.Error())
+				}
+			} else {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+			}
+		})
+	}
+
+	definition := &WorkflowDefinition{
+		InitialState: "start",
+		States: map[string]State{
+			"start": {Name: "start", Transitions: []Transition{
+				{Event: "pay", Target: "paid", Actions: []string{"charge", "charge"}},
+			}},
+			"paid": {Name: "paid", IsFinal: true},
+		},
+	}
+	if err := definition.Validate(); err != nil {
+		t.Fatalf("Expected Validate to accept the definition, got %v", err)
+	}
+	expected := "state start: invalid transition for event pay: action charge is listed more than once"
+	if err := definition.ValidateStrict(); err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got %v", expected, err)
+	}
+}
+
+func TestWorkflowDefinition_ValidateStrict_DeadEnds(t *testing.T) {
+	tests := []struct {
+		name        string
+		isFinal     bool
+		expectError bool
+		errorMsg    string
+	}{
+		{name: "FinalDeadEnd", isFinal: true},
+		{name: "UnflaggedDeadEnd", expectError: true, errorMsg: "state working has no transitions but is not marked isFinal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition := &WorkflowDefinition{
+				InitialState: "start",
+				States: map[string]State{
+					"start":   {Name: "start", Transitions: []Transition{{Event: "work", Target: "working"}}},
+					"working": {Name: "working", IsFinal: tt.isFinal},
+				},
+			}
+
+			if err := definition.Validate(); err != nil {
+				t.Fatalf("Expected Validate to accept dead ends, got %v", err)
+			}
+
+			err := definition.ValidateStrict()
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				} else if err.Error() != tt.errorMsg {
+					t.Errorf("Expected error message '%s', got '%s'", tt.errorMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
 func TestWorkflowDefinition_InitialState(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -290,4 +727,192 @@ func TestWorkflowDefinition_InitialState(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestWorkflowDefinition_ValidateTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		definition  *WorkflowDefinition
+		expectError bool
+		errorMsg    string
+	}{
+		{
+			name: "ValidTargets",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name: "start",
+						Transitions: []Transition{
+							{
+								Event:  "proceed",
+								Target: "end",
+							},
+						},
+					},
+					"end": {
+						Name: "end",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "DynamicTarget",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"sideQuest": {
+						Name: "sideQuest",
+						Transitions: []Transition{
+							{
+								Event:   "return",
+								Target:  "",
+								Actions: []string{ReturnToPreviousStateActionName},
+							},
+						},
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "UnknownTarget",
+			definition: &WorkflowDefinition{
+				States: map[string]State{
+					"start": {
+						Name: "start",
+						Transitions: []Transition{
+							{
+								Event:  "proceed",
+								Target: "missing",
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+			errorMsg:    "state start: transition for event proceed targets unknown state missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.definition.ValidateTargets()
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				} else if err.Error() != tt.errorMsg {
+					t.Errorf("Expected error message '%s', got '%s'", tt.errorMsg, err.Error())
+				}
+			} else {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkflowDefinition_UnreachableStates(t *testing.T) {
+	definition := &WorkflowDefinition{
+		InitialState: "start",
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:  "proceed",
+						Target: "middle",
+					},
+				},
+			},
+			"middle": {
+				Name: "middle",
+				Transitions: []Transition{
+					{
+						Event:  "proceed",
+						Target: "end",
+					},
+				},
+			},
+			"end": {
+				Name: "end",
+			},
+			"orphanB": {
+				Name: "orphanB",
+			},
+			"orphanA": {
+				Name: "orphanA",
+				Transitions: []Transition{
+					{
+						Event:  "proceed",
+						Target: "end",
+					},
+				},
+			},
+		},
+	}
+
+	unreachable := definition.UnreachableStates()
+	if len(unreachable) != 2 || unreachable[0] != "orphanA" || unreachable[1] != "orphanB" {
+		t.Errorf("Expected unreachable states [orphanA orphanB], got %v", unreachable)
+	}
+
+	definition.ErrorState = "orphanA"
+	unreachable = definition.UnreachableStates()
+	if len(unreachable) != 1 || unreachable[0] != "orphanB" {
+		t.Errorf("Expected unreachable states [orphanB] with orphanA as error state, got %v", unreachable)
+	}
+
+	definition.InitialState = ""
+	if unreachable := definition.UnreachableStates(); unreachable != nil {
+		t.Errorf("Expected nil without an initial state, got %v", unreachable)
+	}
+}
+
+func TestWorkflowDefinition_ValidateRegistry(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:    "start",
+				OnLeave: []string{"leaveAction"},
+				Transitions: []Transition{
+					{
+						Event:      "proceed",
+						Target:     "end",
+						Conditions: []string{"isReady"},
+						Actions:    []string{"doWork", ReturnToPreviousStateActionName},
+					},
+				},
+			},
+			"end": {
+				Name:    "end",
+				OnEnter: []string{"enterAction"},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterAction("leaveAction", MockNoOpAction)
+	registry.RegisterAction("doWork", MockNoOpAction)
+	registry.RegisterCondition("isReady", MockTrueCondition)
+
+	err := definition.ValidateRegistry(registry)
+	if err == nil {
+		t.Fatal("Expected error for unregistered OnEnter action, got nil")
+	}
+	if err.Error() != "state end onEnter: action enterAction not found" {
+		t.Errorf("Unexpected error message '%s'", err.Error())
+	}
+
+	registry.RegisterAction("enterAction", MockNoOpAction)
+	if err := definition.ValidateRegistry(registry); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	missingCondition := NewRegistry()
+	missingCondition.RegisterAction("leaveAction", MockNoOpAction)
+	missingCondition.RegisterAction("doWork", MockNoOpAction)
+	missingCondition.RegisterAction("enterAction", MockNoOpAction)
+	if err := definition.ValidateRegistry(missingCondition); err == nil {
+		t.Error("Expected error for unregistered condition, got nil")
+	}
+}
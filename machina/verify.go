@@ -0,0 +1,86 @@
+package machina
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Verify checks that the machine is sound, for example behind a readiness probe: the
+// definition is valid, every transition target and the initial state exist, and every
+// action, condition and payload validator the workflow references can be resolved from
+// the registry or resolver. Sub-workflows must be available with the states their outcomes
+// name. Unlike construction, it reports every problem found, joined into one error.
+func (sm *StateMachine) Verify() error {
+	var errs []error
+	if err := sm.definition.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid workflow definition: %w", err))
+	}
+	if err := sm.definition.ValidateTargets(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Diagnostics use the same wording as ValidateRegistry.
+	checkActions := func(where string, actions []string) {
+		for _, actionName := range actions {
+			if _, err := sm.getAction(actionName); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", where, err))
+			}
+		}
+	}
+	checkConditions := func(where string, conditions []string) {
+		for _, conditionName := range conditions {
+			if _, err := sm.getCondition(conditionName); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", where, err))
+			}
+		}
+	}
+
+	for _, name := range sm.definition.sortedStateNames() {
+		state := sm.definition.States[name]
+		checkActions("state "+name+" onEnter", state.OnEnter)
+		checkActions("state "+name+" onLeave", state.OnLeave)
+		checkActions("state "+name+" onReenter", state.OnReenter)
+		if state.SubWorkflow != nil {
+			errs = append(errs, sm.verifySubWorkflow(name, state.SubWorkflow)...)
+		}
+
+		for _, transition := range state.Transitions {
+			where := fmt.Sprintf("state %s transition for event %s", name, transition.Event)
+			checkConditions(where, transition.Conditions)
+			checkConditions(where+" preconditions", transition.Preconditions)
+			checkConditions(where+" autoEventConditions", transition.AutoEventConditions)
+			for _, validatorName := range transition.Validators {
+				if _, err := sm.registry.GetPayloadValidator(validatorName); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", where, err))
+				}
+			}
+			checkActions(where, transition.Actions)
+			for i, group := range transition.Parallel {
+				checkActions(fmt.Sprintf("%s parallel group %d", where, i), group)
+			}
+			checkActions(where+" onError", transition.OnError)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// verifySubWorkflow checks that the child machine of a sub-workflow is available, has an
+// initial state and declares every terminal state the outcomes map
+func (sm *StateMachine) verifySubWorkflow(name string, sw *SubWorkflow) []error {
+	child, err := sm.subWorkflowMachine(sw.Workflow)
+	if err != nil {
+		return []error{fmt.Errorf("state %s subWorkflow: %w", name, err)}
+	}
+
+	var errs []error
+	if child.definition.InitialState == "" {
+		errs = append(errs, fmt.Errorf("state %s subWorkflow: sub-workflow %s has no initialState", name, sw.Workflow))
+	}
+	for _, terminal := range sortedKeys(sw.Outcomes) {
+		if _, exists := child.definition.States[terminal]; !exists {
+			errs = append(errs, fmt.Errorf("state %s subWorkflow: outcome state %s not found in sub-workflow %s", name, terminal, sw.Workflow))
+		}
+	}
+	return errs
+}
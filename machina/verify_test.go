@@ -0,0 +1,75 @@
+package machina
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestStateMachine_Verify(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name: "start",
+				Transitions: []Transition{
+					{
+						Event:      "proceed",
+						Target:     "end",
+						Conditions: []string{"isReady"},
+						Actions:    []string{"doWork"},
+					},
+				},
+			},
+			"end": {
+				Name:    "end",
+				OnEnter: []string{"enterAction"},
+			},
+		},
+	}
+
+	registry := NewRegistry()
+	registry.RegisterCondition("isReady", MockCondition)
+	registry.RegisterAction("enterAction", MockUpdateAction)
+
+	sm := NewStateMachine(definition, registry, slog.Default())
+
+	err := sm.Verify()
+	if err == nil {
+		t.Fatal("Expected an error for the missing action, got nil")
+	}
+	expected := "state start transition for event proceed: action doWork not found"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+
+	registry.RegisterAction("doWork", MockUpdateAction)
+	if err := sm.Verify(); err != nil {
+		t.Errorf("Expected no error once the action is registered, got %v", err)
+	}
+}
+
+func TestStateMachine_Verify_ReportsAllProblems(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"start": {
+				Name:    "start",
+				OnLeave: []string{"leaveAction"},
+				Transitions: []Transition{
+					{
+						Event:         "proceed",
+						Target:        "end",
+						Preconditions: []string{"hasStock"},
+					},
+				},
+			},
+			"end": {Name: "end"},
+		},
+	}
+
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default())
+
+	expected := "state start onLeave: action leaveAction not found\n" +
+		"state start transition for event proceed preconditions: condition hasStock not found"
+	if err := sm.Verify(); err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,78 @@
+package machina
+
+import (
+	"fmt"
+	"maps"
+)
+
+// VisitsKey is the persistence data key under which WithVisitCounting records how many
+// times each state has been entered, as a map[string]int keyed by state name
+const VisitsKey = "__visits"
+
+// WithVisitCounting makes the machine count state entries in VisitsKey. The count of a
+// state is incremented before its OnEnter actions run, so they and later conditions can
+// branch on it, for example to give up after a number of retries. Internal transitions
+// don't enter their state and are not counted, and neither is restoring the original
+// state after an OnEnter action failed. A transition fails if VisitsKey holds something
+// other than visit counts, so existing counts are never silently reset.
+func WithVisitCounting() StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.visitCounting = true
+	}
+}
+
+// Visits returns how many times state has been entered according to VisitsKey in data.
+// Counts decoded from JSON, as a map[string]any of numbers, are read as well.
+func Visits(data map[string]any, state string) int {
+	visits, _ := visitCounts(data[VisitsKey])
+	return visits[state]
+}
+
+// recordVisit increments the visit count of state in persistenceData. The map is copied
+// so that the caller's payload is never modified. It fails rather than resetting the
+// counts if the value under VisitsKey cannot be read.
+func recordVisit(persistenceData map[string]any, state string) error {
+	visits, err := visitCounts(persistenceData[VisitsKey])
+	if err != nil {
+		return err
+	}
+	visits = maps.Clone(visits)
+	if visits == nil {
+		visits = make(map[string]int, 1)
+	}
+	visits[state]++
+	persistenceData[VisitsKey] = visits
+	return nil
+}
+
+// visitCounts converts the value stored under VisitsKey to a map[string]int. Besides the
+// map[string]int written by the engine it accepts a map[string]any of whole numbers, the
+// form the counts take after a round trip through JSON.
+func visitCounts(value any) (map[string]int, error) {
+	switch visits := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]int:
+		return visits, nil
+	case map[string]any:
+		counts := make(map[string]int, len(visits))
+		for state, count := range visits {
+			switch n := count.(type) {
+			case int:
+				counts[state] = n
+			case int64:
+				counts[state] = int(n)
+			case float64:
+				if n != float64(int(n)) {
+					return nil, fmt.Errorf("visit count of %s is not a whole number: %v", state, n)
+				}
+				counts[state] = int(n)
+			default:
+				return nil, fmt.Errorf("visit count of %s is %T, not a number", state, count)
+			}
+		}
+		return counts, nil
+	default:
+		return nil, fmt.Errorf("expected a map of visit counts, got %T", value)
+	}
+}
@@ -0,0 +1,203 @@
+package machina
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStateMachine_Trigger_VisitCounting(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"processOrder": {
+				Name:    "processOrder",
+				OnEnter: []string{"recordAttempt"},
+				Transitions: []Transition{
+					{Event: "retry", Target: "failed", Conditions: []string{"retriesExhausted"}},
+					{Event: "retry", Target: "processOrder"},
+					{Event: "note", Target: "processOrder", Internal: true},
+				},
+			},
+			"failed": {Name: "failed"},
+		},
+	}
+
+	var attempts []int
+	registry := NewRegistry()
+	registry.RegisterAction("recordAttempt", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		attempts = append(attempts, Visits(data, "processOrder"))
+		return nil, nil
+	})
+	registry.RegisterCondition("retriesExhausted", func(ctx context.Context, data map[string]any) (bool, error) {
+		return Visits(data, "processOrder") >= 3, nil
+	})
+
+	sm := NewStateMachine(definition, registry, slog.Default(), WithVisitCounting())
+
+	data := map[string]any{"orderID": "42"}
+	state := "processOrder"
+	for i := 0; i < 4; i++ {
+		result, err := sm.Trigger(context.Background(), state, "retry", data)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if i == 0 {
+			if _, exists := data[VisitsKey]; exists {
+				t.Error("Expected the caller's payload not to be modified")
+			}
+			result, err = sm.Trigger(context.Background(), result.NewState, "note", result.PersistenceData)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		state, data = result.NewState, result.PersistenceData
+	}
+
+	if state != "failed" {
+		t.Errorf("Expected failed after three visits, got %s", state)
+	}
+	if got := Visits(data, "processOrder"); got != 3 {
+		t.Errorf("Expected 3 visits to processOrder, got %d", got)
+	}
+	if got := Visits(data, "failed"); got != 1 {
+		t.Errorf("Expected 1 visit to failed, got %d", got)
+	}
+	if !slices.Equal(attempts, []int{1, 2, 3}) {
+		t.Errorf("Expected OnEnter to see visits [1 2 3], got %v", attempts)
+	}
+}
+
+func TestStateMachine_Trigger_VisitCountingDisabled(t *testing.T) {
+	sm := NewStateMachine(routingDefinition(), NewRegistry(), slog.Default())
+
+	result, err := sm.Trigger(context.Background(), "start", "route", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, exists := result.PersistenceData[VisitsKey]; exists {
+		t.Error("Expected no visit counts without WithVisitCounting")
+	}
+}
+
+func TestStateMachine_Trigger_VisitCountingNilPayload(t *testing.T) {
+	var seen int
+	registry := NewRegistry()
+	registry.RegisterAction("noOpAction", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return nil, nil
+	})
+	registry.RegisterAction("recordAttempt", func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		seen = Visits(data, "end")
+		return nil, nil
+	})
+
+	definition := routingDefinition()
+	end := definition.States["end"]
+	end.OnEnter = []string{"recordAttempt"}
+	definition.States["end"] = end
+
+	sm := NewStateMachine(definition, registry, slog.Default(), WithVisitCounting())
+
+	for _, event := range []string{"route", "process"} {
+		result, err := sm.Trigger(context.Background(), "start", event, nil)
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", event, err)
+		}
+		if got := Visits(result.PersistenceData, "end"); got != 1 {
+			t.Errorf("Expected 1 visit to end for %s, got %d", event, got)
+		}
+		if seen != 1 {
+			t.Errorf("Expected OnEnter to see 1 visit for %s, got %d", event, seen)
+		}
+	}
+}
+
+func TestStateMachine_Trigger_VisitCountingAfterSnapshot(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"processOrder": {
+				Name:        "processOrder",
+				Transitions: []Transition{{Event: "retry", Target: "processOrder"}},
+			},
+		},
+	}
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default(), WithVisitCounting())
+
+	result, err := sm.Trigger(context.Background(), "processOrder", "retry", map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	encoded, err := json.Marshal(SnapshotFrom(result.NewState, result.PersistenceData))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var snapshot MachineSnapshot
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := snapshot.Data[VisitsKey].(map[string]int); !ok {
+		t.Errorf("Expected visits as map[string]int, got %#v", snapshot.Data[VisitsKey])
+	}
+
+	result, err = sm.Resume(context.Background(), snapshot, "retry")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := Visits(result.PersistenceData, "processOrder"); got != 2 {
+		t.Errorf("Expected 2 visits after the resume, got %d", got)
+	}
+
+	// Counts decoded generically from JSON are read as numbers
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(`{"__visits":{"processOrder":3}}`), &decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := Visits(decoded, "processOrder"); got != 3 {
+		t.Errorf("Expected 3 visits from float64 counts, got %d", got)
+	}
+	result, err = sm.Trigger(context.Background(), "processOrder", "retry", decoded)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := Visits(result.PersistenceData, "processOrder"); got != 4 {
+		t.Errorf("Expected 4 visits, got %d", got)
+	}
+}
+
+func TestStateMachine_Trigger_VisitCountingInvalidCounts(t *testing.T) {
+	definition := &WorkflowDefinition{
+		States: map[string]State{
+			"processOrder": {
+				Name:        "processOrder",
+				Transitions: []Transition{{Event: "retry", Target: "processOrder"}},
+			},
+		},
+	}
+	sm := NewStateMachine(definition, NewRegistry(), slog.Default(), WithVisitCounting())
+
+	tests := []struct {
+		name   string
+		visits any
+	}{
+		{name: "FractionalCount", visits: map[string]any{"processOrder": 1.5}},
+		{name: "NonNumericCount", visits: map[string]any{"processOrder": "2"}},
+		{name: "NotAMap", visits: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]any{VisitsKey: tt.visits}
+			result, err := sm.Trigger(context.Background(), "processOrder", "retry", data)
+			if err == nil {
+				t.Fatalf("Expected an error for unreadable visit counts, got result %+v", result)
+			}
+			if !strings.Contains(err.Error(), "failed to count visit to processOrder") {
+				t.Errorf("Expected a visit count error, got %v", err)
+			}
+		})
+	}
+}
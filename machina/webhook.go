@@ -0,0 +1,122 @@
+package machina
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WebhookFailurePolicy decides what happens when a transition's webhook cannot be delivered
+type WebhookFailurePolicy int
+
+const (
+	// WebhookBestEffort logs failed webhooks and keeps the transition. It is the default.
+	WebhookBestEffort WebhookFailurePolicy = iota
+	// WebhookFailTransition fails the transition when a webhook cannot be delivered. The
+	// workflow is restored to its original state as if an OnEnter action had failed.
+	// Webhooks are posted before the transition commits, so when a later webhook of the
+	// same transition fails, the earlier ones have announced a transition that was then
+	// rolled back. Receivers should treat webhooks as at-least-once notifications and
+	// check the workflow's state when it matters.
+	WebhookFailTransition
+)
+
+// defaultWebhookClient posts webhooks unless WithHTTPClient is applied. Unlike
+// http.DefaultClient it has a timeout, so a webhook that never answers cannot hold a
+// transition forever when the Trigger context has no deadline.
+var defaultWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WithHTTPClient sets the client used to post transition webhooks. It defaults to a client
+// with a 10 second timeout; the Trigger context bounds each request either way.
+func WithHTTPClient(client *http.Client) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.httpClient = client
+	}
+}
+
+// WithWebhookFailurePolicy sets how webhook delivery failures are handled
+func WithWebhookFailurePolicy(policy WebhookFailurePolicy) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.webhookFailurePolicy = policy
+	}
+}
+
+// validateWebhooks checks that every webhook is an absolute http or https URL
+func (t *Transition) validateWebhooks() error {
+	for _, rawURL := range t.Webhooks {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %s must be an absolute http or https URL", rawURL)
+		}
+	}
+	return nil
+}
+
+// postWebhooks posts persistenceData as JSON to each of the transition's webhooks in order.
+// Under WebhookBestEffort failures are only logged; under WebhookFailTransition the first
+// failure is returned and the remaining webhooks are skipped.
+func (sm *StateMachine) postWebhooks(ctx context.Context, logger *slog.Logger, currentState, event string, transition *Transition, persistenceData map[string]any) error {
+	if len(transition.Webhooks) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(persistenceData)
+	if err != nil {
+		return sm.webhookFailed(logger, currentState, event, fmt.Errorf("failed to encode webhook payload: %w", err))
+	}
+
+	for _, webhookURL := range transition.Webhooks {
+		if err := sm.postWebhook(ctx, webhookURL, body); err != nil {
+			if err := sm.webhookFailed(logger, currentState, event, fmt.Errorf("webhook %s failed: %w", webhookURL, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		logger.Debug("Webhook delivered", "url", webhookURL)
+	}
+	return nil
+}
+
+// webhookFailed applies the webhook failure policy, returning err only if it fails the transition
+func (sm *StateMachine) webhookFailed(logger *slog.Logger, currentState, event string, err error) error {
+	if sm.webhookFailurePolicy != WebhookFailTransition {
+		logger.Warn("Webhook failed", "error", err)
+		return nil
+	}
+
+	err = withKind(err, errStepFailed)
+	sm.recordTransitionError(currentState, event, "webhook_error", err)
+	return err
+}
+
+// postWebhook posts body to a single webhook, treating any non-2xx status as a failure
+func (sm *StateMachine) postWebhook(ctx context.Context, webhookURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := sm.httpClient
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package machina
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// webhookDefinition returns a workflow whose ship transition posts to the given webhooks
+func webhookDefinition(webhooks ...string) *WorkflowDefinition {
+	return &WorkflowDefinition{
+		States: map[string]State{
+			"packed": {
+				Name: "packed",
+				Transitions: []Transition{
+					{Event: "ship", Target: "shipped", Actions: []string{"updateAction"}, Webhooks: webhooks},
+				},
+			},
+			"shipped": {Name: "shipped"},
+		},
+	}
+}
+
+func TestStateMachine_Trigger_Webhooks(t *testing.T) {
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON POST, got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Expected a JSON body, got %v", err)
+		}
+		received = append(received, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	registry := NewRegistry()
+	registry.RegisterAction("updateAction", MockUpdateAction)
+	sm := NewStateMachine(webhookDefinition(server.URL+"/a", server.URL+"/b"), registry, slog.Default(), WithHTTPClient(server.Client()))
+
+	result, err := sm.Trigger(context.Background(), "packed", "ship", map[string]any{"orderID": "42"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NewState != "shipped" {
+		t.Errorf("Expected shipped, got %s", result.NewState)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 webhook calls, got %d", len(received))
+	}
+	for _, body := range received {
+		if body["orderID"] != "42" || body["updated"] != true {
+			t.Errorf("Expected the persistence data to be posted, got %v", body)
+		}
+	}
+}
+
+func TestStateMachine_Trigger_WebhookFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		policy        WebhookFailurePolicy
+		expectedState string
+		expectError   bool
+	}{
+		{"BestEffort", WebhookBestEffort, "shipped", false},
+		{"FailTransition", WebhookFailTransition, "packed", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.RegisterAction("updateAction", MockUpdateAction)
+			sm := NewStateMachine(webhookDefinition(server.URL), registry, slog.Default(),
+				WithHTTPClient(server.Client()), WithWebhookFailurePolicy(tt.policy))
+
+			result, err := sm.Trigger(context.Background(), "packed", "ship", map[string]any{"orderID": "42"})
+
+			if !tt.expectError {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+			} else {
+				var rolledBack *ErrEnterRolledBack
+				if !errors.As(err, &rolledBack) {
+					t.Fatalf("Expected *ErrEnterRolledBack, got %v", err)
+				}
+				expected := "webhook " + server.URL + " failed: unexpected status 500 Internal Server Error"
+				if rolledBack.Err.Error() != expected {
+					t.Errorf("Expected %q, got %q", expected, rolledBack.Err.Error())
+				}
+				if _, exists := result.PersistenceData["updated"]; exists {
+					t.Error("Expected the restored data not to include the action's updates")
+				}
+			}
+
+			if result.NewState != tt.expectedState {
+				t.Errorf("Expected %s, got %s", tt.expectedState, result.NewState)
+			}
+		})
+	}
+}
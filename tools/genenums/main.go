@@ -0,0 +1,191 @@
+// Command genenums generates typed constants for the state and event names of a workflow,
+// so code driving the workflow doesn't repeat them as string literals.
+//
+// Usage:
+//
+//	genenums [-package name] [-out file] workflow.yaml
+//
+// It is meant to be run with go:generate from the package using the workflow:
+//
+//	//go:generate go run github.com/rahulpahuja/go-machina/tools/genenums -out workflow_names.go workflow.yaml
+//
+// A state named payment_pending becomes StatePaymentPending and an event named proceed
+// becomes EventProceed. The wildcard event and the patterns of prefix and regex transitions
+// are skipped.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/rahulpahuja/go-machina/machina"
+)
+
+// Constant is a generated name constant
+type Constant struct {
+	Name  string
+	Value string
+}
+
+// File is the data the generated file is rendered from
+type File struct {
+	Source  string
+	Package string
+	States  []Constant
+	Events  []Constant
+}
+
+const enumTemplate = `// Code generated by genenums from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+// State names declared by the workflow
+const (
+{{- range .States}}
+	{{.Name}} = {{printf "%q" .Value}}
+{{- end}}
+)
+{{if .Events}}
+// Event names handled by the workflow
+const (
+{{- range .Events}}
+	{{.Name}} = {{printf "%q" .Value}}
+{{- end}}
+)
+{{end}}`
+
+func main() {
+	packageName := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated file; defaults to $GOPACKAGE, set by go generate")
+	out := flag.String("out", "", "file to write; defaults to standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-package name] [-out file] workflow.yaml\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *packageName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *packageName, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "genenums: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the constants for the workflow at path and writes them to out
+func run(path, packageName, out string) error {
+	definition, err := machina.LoadWorkflowDefinition(path)
+	if err != nil {
+		return err
+	}
+
+	source, err := generate(definition, packageName, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	return os.WriteFile(out, source, 0o644)
+}
+
+// generate renders the gofmt-formatted source declaring the definition's state and event names
+func generate(definition *machina.WorkflowDefinition, packageName, source string) ([]byte, error) {
+	var events []string
+	for _, state := range definition.States {
+		for _, transition := range state.Transitions {
+			// Prefix and regex transitions hold a pattern, not an event name
+			if transition.Match == "" || transition.Match == machina.MatchExact {
+				events = append(events, transition.Event)
+			}
+			if transition.AutoEvent != "" {
+				events = append(events, transition.AutoEvent)
+			}
+		}
+		if state.TimeoutEvent != "" {
+			events = append(events, state.TimeoutEvent)
+		}
+	}
+	events = slices.DeleteFunc(events, func(event string) bool { return event == machina.WildcardEvent })
+
+	states := make([]string, 0, len(definition.States))
+	for name := range definition.States {
+		states = append(states, name)
+	}
+
+	var err error
+	file := File{Source: source, Package: packageName}
+	if file.States, err = constants("State", states); err != nil {
+		return nil, err
+	}
+	if file.Events, err = constants("Event", events); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("enums").Parse(enumTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, file); err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid source: %w", err)
+	}
+	return formatted, nil
+}
+
+// constants sorts and deduplicates the values and names each with the prefix followed by
+// the value in camel case. Two values mapping to the same name are an error.
+func constants(prefix string, values []string) ([]Constant, error) {
+	slices.Sort(values)
+	values = slices.Compact(values)
+
+	seen := make(map[string]string, len(values))
+	result := make([]Constant, 0, len(values))
+	for _, value := range values {
+		name := prefix + identifier(value)
+		if other, exists := seen[name]; exists {
+			return nil, fmt.Errorf("%s names %q and %q both map to %s", strings.ToLower(prefix), other, value, name)
+		}
+		seen[name] = value
+		result = append(result, Constant{Name: name, Value: value})
+	}
+
+	return result, nil
+}
+
+// identifier converts a name such as payment_pending or check-out into PaymentPending or
+// CheckOut, dropping characters that cannot appear in a Go identifier
+func identifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rahulpahuja/go-machina/machina"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerate_Golden(t *testing.T) {
+	definition, err := machina.LoadWorkflowDefinition(filepath.Join("testdata", "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got, err := generate(definition, "orders", "workflow.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	golden := filepath.Join("testdata", "workflow_names.go.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if string(got) != string(expected) {
+		t.Errorf("Generated source does not match %s:\n%s", golden, got)
+	}
+
+	// The generated file must compile on its own
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "workflow_names.go", got, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse generated source: %v", err)
+	}
+	if _, err := new(types.Config).Check("orders", fset, []*ast.File{file}, nil); err != nil {
+		t.Errorf("Generated source does not type-check: %v", err)
+	}
+}
+
+func TestGenerate_NameCollision(t *testing.T) {
+	definition := &machina.WorkflowDefinition{
+		States: map[string]machina.State{
+			"check_out": {Name: "check_out"},
+			"check-out": {Name: "check-out"},
+		},
+	}
+
+	_, err := generate(definition, "orders", "workflow.yaml")
+	expected := `state names "check-out" and "check_out" both map to StateCheckOut`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got %v", expected, err)
+	}
+}